@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/router"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// acceptPaused is set while new remote connections are refused, with
+// -accept-paused or the accept.pause admin procedure.
+var acceptPaused atomic.Bool
+
+var errAcceptPaused = errors.New("accepting new connections is paused")
+
+// acceptGate returns a handler refusing WebSocket upgrades with 503 Service
+// Unavailable while accepting is paused.
+func acceptGate(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if acceptPaused.Load() {
+			http.Error(w, errAcceptPaused.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// pausableRouter closes RawSocket clients instead of attaching them while
+// accepting is paused.  As with throttledRouter, nexus has already accepted
+// and handshaked the connection by then.
+type pausableRouter struct {
+	router.Router
+}
+
+func (r pausableRouter) Attach(client wamp.Peer) error {
+	if acceptPaused.Load() {
+		client.Close()
+		return errAcceptPaused
+	}
+	return r.Router.Attach(client)
+}
+
+// adminAccept returns the handler of <admin-prefix>.accept.pause or
+// <admin-prefix>.accept.resume.  Existing sessions are not affected.  It
+// returns whether accepting is paused.
+func adminAccept(pause bool) client.InvocationHandler {
+	return func(ctx context.Context, inv *wamp.Invocation) client.InvokeResult {
+		if acceptPaused.Swap(pause) != pause {
+			if pause {
+				logger.Printf("accepting new connections paused\n")
+			} else {
+				logger.Printf("accepting new connections resumed\n")
+			}
+		}
+		return client.InvokeResult{Args: wamp.List{pause}}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+)
+
+func TestAcceptPause(t *testing.T) {
+	savedRole := anonRole
+	t.Cleanup(func() {
+		anonRole = savedRole
+		acceptPaused.Store(false)
+	})
+	anonRole = adminRole
+
+	url := startTestRouter(t)
+	for name, handler := range map[string]client.InvocationHandler{
+		".accept.pause":  adminAccept(true),
+		".accept.resume": adminAccept(false),
+	} {
+		if err := createLocalCallee(getLocalClient(), adminPrefix+name, handler); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ws := httptest.NewServer(acceptGate(newWebsocketServer(wsRouter)))
+	defer ws.Close()
+	wsURL := "ws" + strings.TrimPrefix(ws.URL, "http")
+	rs, rsURL, err := startRawSocket(newRawSocketServer(pausableRouter{wsRouter}), rawSocketListener{"tcp", "127.0.0.1:0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+	connect := func(url string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		c, err := client.ConnectNet(ctx, url, client.Config{Realm: realm, Logger: logger})
+		if err == nil {
+			c.Close()
+		}
+		return err
+	}
+
+	admin := connectTestClient(t, url, realm)
+	if _, err = testCall(admin, adminPrefix+".accept.pause", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.Get(ws.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 while paused, got %d", res.StatusCode)
+	}
+	for _, u := range []string{wsURL, rsURL} {
+		if err = connect(u); err == nil {
+			t.Errorf("%s: expected the connection to be refused while paused", u)
+		}
+	}
+	// The session connected before the pause is untouched.
+	if _, err = testCall(admin, adminPrefix+".accept.resume", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []string{wsURL, rsURL} {
+		if err = connect(u); err != nil {
+			t.Errorf("%s: expected the connection to be accepted after resume: %s", u, err)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// errNoRealm is wrapped by closeRealm when the realm cannot be joined because
+// it does not exist.
+var errNoRealm = errors.New("no such realm")
+
+// isAdminURI reports whether calling procedure requires the admin authrole.
+// Besides the router's own admin procedures this covers the session meta
+// procedures that disconnect or modify other sessions.
+func isAdminURI(procedure wamp.URI) bool {
+	return strings.HasPrefix(string(procedure), adminPrefix+".") ||
+		strings.HasPrefix(string(procedure), "wamp.session.kill") ||
+		procedure == wamp.MetaProcSessionModifyDetails
+}
+
+// closeRealm disconnects every session of the realm with the given reason and
+// removes the realm from the router.  It returns the number of sessions that
+// were disconnected.
+func closeRealm(ctx context.Context, uri wamp.URI, reason wamp.URI, message string) (int, error) {
+	if isLocalRealm(uri) {
+		return 0, errors.New("cannot close a realm of a local client")
+	}
+	c, err := client.ConnectLocal(wsRouter, client.Config{Realm: string(uri), Logger: logger})
+	if err != nil {
+		return 0, fmt.Errorf("%w %q: %s", errNoRealm, uri, err)
+	}
+	res, err := c.Call(ctx, string(wamp.MetaProcSessionKillAll), nil, nil, wamp.Dict{
+		"reason":  reason,
+		"message": message,
+	}, nil)
+	c.Close()
+	if err != nil {
+		return 0, fmt.Errorf("failed to kill sessions of %q: %s", uri, err)
+	}
+	count, _ := wamp.AsInt64(res.Arguments[0])
+	wsRouter.RemoveRealm(uri)
+	forgetRealm(uri)
+	logger.Printf("closed realm %s, disconnected %d sessions\n", uri, count)
+	return int(count), nil
+}
+
+// adminRealmClose handles <admin-prefix>.realm.close.  It takes the realm URI
+// and accepts optional reason, message and drain kwargs.
+func adminRealmClose(ctx context.Context, inv *wamp.Invocation) client.InvokeResult {
+	if len(inv.Arguments) == 0 {
+		return client.InvokeResult{Err: wamp.ErrInvalidArgument, Args: wamp.List{"missing realm"}}
+	}
+	uri, ok := wamp.AsURI(inv.Arguments[0])
+	if !ok {
+		return client.InvokeResult{Err: wamp.ErrInvalidArgument, Args: wamp.List{"realm must be a string"}}
+	}
+	if isLocalRealm(uri) {
+		return client.InvokeResult{Err: wamp.ErrInvalidArgument, Args: wamp.List{"cannot close a realm of a local client"}}
+	}
+	reason, _ := wamp.AsURI(inv.ArgumentsKw["reason"])
+	if reason == "" {
+		reason = wamp.CloseRealm
+	}
+	message, _ := wamp.AsString(inv.ArgumentsKw["message"])
+	stop, err := drainRealm(ctx, uri, inv.ArgumentsKw, reason, message)
+	if err != nil {
+		return realmCallError(err)
+	}
+	defer stop()
+	count, err := closeRealm(ctx, uri, reason, message)
+	if err != nil {
+		return realmCallError(err)
+	}
+	return client.InvokeResult{Args: wamp.List{count}}
+}
+
+// clearRealm disconnects every remote session of the realm with the given
+// reason, keeping the realm and its local clients.  It returns the number of
+// sessions that were disconnected.
+func clearRealm(ctx context.Context, uri wamp.URI, reason wamp.URI, message string) (int, error) {
+	c, err := realmClient(uri)
+	if err != nil {
+		return 0, fmt.Errorf("%w %q: %s", errNoRealm, uri, err)
+	}
+	ids, err := realmSessionIDs(ctx, uri)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list sessions of %q: %s", uri, err)
+	}
+	count := 0
+	for id := range ids {
+		res, err := c.Call(ctx, string(wamp.MetaProcSessionGet), nil, wamp.List{id}, nil, nil)
+		if err != nil {
+			// The session left since it was listed.
+			continue
+		}
+		if details, _ := wamp.AsDict(res.Arguments[0]); details["authmethod"] == "local" {
+			continue
+		}
+		_, err = c.Call(ctx, string(wamp.MetaProcSessionKill), nil, wamp.List{id}, wamp.Dict{
+			"reason":  reason,
+			"message": message,
+		}, nil)
+		if err == nil {
+			count++
+		}
+	}
+	logger.Printf("cleared realm %s, disconnected %d sessions\n", uri, count)
+	return count, nil
+}
+
+// adminRealmClear handles <admin-prefix>.realm.clear.  It takes the realm URI
+// and accepts optional reason, message and drain kwargs.
+func adminRealmClear(ctx context.Context, inv *wamp.Invocation) client.InvokeResult {
+	if len(inv.Arguments) == 0 {
+		return client.InvokeResult{Err: wamp.ErrInvalidArgument, Args: wamp.List{"missing realm"}}
+	}
+	uri, ok := wamp.AsURI(inv.Arguments[0])
+	if !ok {
+		return client.InvokeResult{Err: wamp.ErrInvalidArgument, Args: wamp.List{"realm must be a string"}}
+	}
+	reason, _ := wamp.AsURI(inv.ArgumentsKw["reason"])
+	if reason == "" {
+		reason = wamp.CloseNormal
+	}
+	message, _ := wamp.AsString(inv.ArgumentsKw["message"])
+	stop, err := drainRealm(ctx, uri, inv.ArgumentsKw, reason, message)
+	if err != nil {
+		return realmCallError(err)
+	}
+	defer stop()
+	count, err := clearRealm(ctx, uri, reason, message)
+	if err != nil {
+		return realmCallError(err)
+	}
+	return client.InvokeResult{Args: wamp.List{count}}
+}
+
+// realmCallError returns the result of a realm.close or realm.clear call
+// failing with err.
+func realmCallError(err error) client.InvokeResult {
+	uri := errInternal
+	switch {
+	case errors.Is(err, errNoRealm):
+		uri = wamp.ErrNoSuchRealm
+	case errors.Is(err, errBadDrain):
+		uri = wamp.ErrInvalidArgument
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		uri = wamp.ErrCanceled
+	}
+	return client.InvokeResult{Err: uri, Args: wamp.List{err.Error()}}
+}
+
+// adminPublish handles <admin-prefix>.publish.  It takes a topic, optionally
+// followed by the args list and kwargs dict of the event, and publishes it in
+// the realm of the local client.
+func adminPublish(ctx context.Context, inv *wamp.Invocation) client.InvokeResult {
+	if len(inv.Arguments) == 0 {
+		return client.InvokeResult{Err: wamp.ErrInvalidArgument, Args: wamp.List{"missing topic"}}
+	}
+	topic, ok := wamp.AsString(inv.Arguments[0])
+	if !ok || !wamp.URI(topic).ValidURI(false, "") {
+		return client.InvokeResult{Err: wamp.ErrInvalidURI, Args: wamp.List{fmt.Sprintf("invalid topic %v", inv.Arguments[0])}}
+	}
+	var args wamp.List
+	var kwargs wamp.Dict
+	if len(inv.Arguments) > 1 {
+		if args, ok = wamp.AsList(inv.Arguments[1]); !ok {
+			return client.InvokeResult{Err: wamp.ErrInvalidArgument, Args: wamp.List{"args must be a list"}}
+		}
+	}
+	if len(inv.Arguments) > 2 {
+		if kwargs, ok = wamp.AsDict(inv.Arguments[2]); !ok {
+			return client.InvokeResult{Err: wamp.ErrInvalidArgument, Args: wamp.List{"kwargs must be a dict"}}
+		}
+	}
+	if err := getLocalClient().Publish(topic, wamp.Dict{wamp.OptAcknowledge: true}, args, kwargs); err != nil {
+		return client.InvokeResult{Err: errInternal, Args: wamp.List{err.Error()}}
+	}
+	logger.Printf("admin publish on %s\n", topic)
+	return client.InvokeResult{}
+}
+
+// adminSessionsGet handles <admin-prefix>.sessions.get.  It takes a session ID
+// and an optional realm kwarg, defaulting to the -realm realm, and returns the
+// session details with the number of subscriptions and registrations of the
+// session added.
+func adminSessionsGet(ctx context.Context, inv *wamp.Invocation) client.InvokeResult {
+	if len(inv.Arguments) == 0 {
+		return client.InvokeResult{Err: wamp.ErrInvalidArgument, Args: wamp.List{"missing session ID"}}
+	}
+	sid, ok := wamp.AsID(inv.Arguments[0])
+	if !ok {
+		return client.InvokeResult{Err: wamp.ErrInvalidArgument, Args: wamp.List{"session ID must be an integer"}}
+	}
+	uri, _ := wamp.AsURI(inv.ArgumentsKw["realm"])
+	if uri == "" {
+		uri = wamp.URI(realm)
+	}
+	c, err := realmClient(uri)
+	if err != nil {
+		return client.InvokeResult{Err: wamp.ErrNoSuchRealm, Args: wamp.List{fmt.Sprintf("%s %q: %s", errNoRealm, uri, err)}}
+	}
+	res, err := c.Call(ctx, string(wamp.MetaProcSessionGet), nil, wamp.List{sid}, nil, nil)
+	if err = translateCallError(err); errors.Is(err, errCallNotFound) {
+		return client.InvokeResult{Err: wamp.ErrNoSuchSession, Args: wamp.List{fmt.Sprintf("no session %d in realm %s", sid, uri)}}
+	}
+	if err != nil {
+		return client.InvokeResult{Err: errInternal, Args: wamp.List{err.Error()}}
+	}
+	details, _ := wamp.AsDict(res.Arguments[0])
+	subs, err := sessionMemberships(ctx, c, sid, wamp.MetaProcSubList, wamp.MetaProcSubListSubscribers)
+	if err != nil {
+		return client.InvokeResult{Err: errInternal, Args: wamp.List{err.Error()}}
+	}
+	regs, err := sessionMemberships(ctx, c, sid, wamp.MetaProcRegList, wamp.MetaProcRegListCallees)
+	if err != nil {
+		return client.InvokeResult{Err: errInternal, Args: wamp.List{err.Error()}}
+	}
+	details["realm"] = uri
+	details["subscriptions"] = subs
+	details["registrations"] = regs
+	if in, out, ok := sessionByteCount(sid); ok {
+		details["bytes_in"], details["bytes_out"] = in, out
+	}
+	return client.InvokeResult{Args: wamp.List{details}}
+}
+
+// sessionMemberships counts the subscriptions or registrations of the session
+// by listing them with the list meta procedure and checking the members of
+// each with the members meta procedure.
+func sessionMemberships(ctx context.Context, c *client.Client, sid wamp.ID, list, members wamp.URI) (int, error) {
+	res, err := c.Call(ctx, string(list), nil, nil, nil, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to call %s: %s", list, err)
+	}
+	byMatch, _ := wamp.AsDict(res.Arguments[0])
+	count := 0
+	for _, ids := range byMatch {
+		ids, _ := wamp.AsList(ids)
+		for _, id := range ids {
+			res, err := c.Call(ctx, string(members), nil, wamp.List{id}, nil, nil)
+			if err != nil {
+				// Removed since it was listed.
+				continue
+			}
+			sids, _ := wamp.AsList(res.Arguments[0])
+			for _, s := range sids {
+				if s, _ := wamp.AsID(s); s == sid {
+					count++
+					break
+				}
+			}
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestAdminRealmClose(t *testing.T) {
+	saved := anonRole
+	defer func() { anonRole = saved }()
+	anonRole = adminRole
+
+	url := startTestRouter(t, "closed", "open")
+	if err := createLocalCallee(getLocalClient(), adminPrefix+".realm.close", adminRealmClose); err != nil {
+		t.Fatal(err)
+	}
+	admin := connectTestClient(t, url, realm)
+	closed := connectTestClient(t, url, "closed")
+	open := connectTestClient(t, url, "open")
+
+	res, err := testCall(admin, adminPrefix+".realm.close", wamp.List{"closed"}, wamp.Dict{"message": "bye"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := wamp.AsInt64(res.Arguments[0]); count != 1 {
+		t.Errorf("expected 1 disconnected session, got %v", res.Arguments[0])
+	}
+	select {
+	case <-closed.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("session of the closed realm was not disconnected")
+	}
+	if !open.Connected() {
+		t.Error("session of the other realm was disconnected")
+	}
+	if _, err = testCall(open, string(wamp.MetaProcSessionCount), nil, nil); err != nil {
+		t.Errorf("other realm stopped working: %s", err)
+	}
+
+	_, err = testCall(admin, adminPrefix+".realm.close", wamp.List{"closed"}, nil)
+	if uri := errorURI(err); uri != wamp.ErrNoSuchRealm {
+		t.Errorf("expected %s for a removed realm, got %v", wamp.ErrNoSuchRealm, err)
+	}
+	_, err = testCall(admin, adminPrefix+".realm.close", wamp.List{realm}, nil)
+	if uri := errorURI(err); uri != wamp.ErrInvalidArgument {
+		t.Errorf("expected %s for the local client realm, got %v", wamp.ErrInvalidArgument, err)
+	}
+}
+
+func TestAdminRealmClear(t *testing.T) {
+	saved := anonRole
+	defer func() { anonRole = saved }()
+	anonRole = adminRole
+
+	url := startTestRouter(t, "tenant")
+	if err := createLocalCallee(getLocalClient(), adminPrefix+".realm.clear", adminRealmClear); err != nil {
+		t.Fatal(err)
+	}
+	if err := connectExtraLocalClient("tenant"); err != nil {
+		t.Fatal(err)
+	}
+	admin := connectTestClient(t, url, realm)
+	sessions := []*client.Client{connectTestClient(t, url, "tenant"), connectTestClient(t, url, "tenant")}
+
+	res, err := testCall(admin, adminPrefix+".realm.clear", wamp.List{"tenant"}, wamp.Dict{"message": "maintenance"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := wamp.AsInt64(res.Arguments[0]); count != 2 {
+		t.Errorf("expected 2 disconnected sessions, got %v", res.Arguments[0])
+	}
+	for _, c := range sessions {
+		select {
+		case <-c.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("session of the cleared realm was not disconnected")
+		}
+	}
+	if !extraLocalClients["tenant"].Connected() {
+		t.Error("local client of the cleared realm was disconnected")
+	}
+	rejoined := connectTestClient(t, url, "tenant")
+	if _, err = testCall(rejoined, string(wamp.MetaProcSessionCount), nil, nil); err != nil {
+		t.Errorf("cleared realm stopped working: %s", err)
+	}
+
+	_, err = testCall(admin, adminPrefix+".realm.clear", wamp.List{"missing"}, nil)
+	if uri := errorURI(err); uri != wamp.ErrNoSuchRealm {
+		t.Errorf("expected %s for an unknown realm, got %v", wamp.ErrNoSuchRealm, err)
+	}
+}
+
+func TestAdminPublish(t *testing.T) {
+	savedRole := anonRole
+	defer func() { anonRole = savedRole }()
+	anonRole = adminRole
+
+	url := startTestRouter(t)
+	if err := createLocalCallee(getLocalClient(), adminPrefix+".publish", adminPublish); err != nil {
+		t.Fatal(err)
+	}
+	admin := connectTestClient(t, url, realm)
+	subscriber := connectTestClient(t, url, realm)
+	events := make(chan *wamp.Event, 1)
+	if err := subscriber.SubscribeChan("test.notice", events, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := testCall(admin, adminPrefix+".publish", wamp.List{"test.notice", wamp.List{"maintenance"}, wamp.Dict{"at": "22:00"}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-events:
+		if len(event.Arguments) != 1 || event.Arguments[0] != "maintenance" || event.ArgumentsKw["at"] != "22:00" {
+			t.Errorf("unexpected event %v %v", event.Arguments, event.ArgumentsKw)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("admin publish did not reach the subscriber")
+	}
+
+	_, err := testCall(admin, adminPrefix+".publish", wamp.List{"not a topic!"}, nil)
+	if uri := errorURI(err); uri != wamp.ErrInvalidURI {
+		t.Errorf("expected %s for an invalid topic, got %v", wamp.ErrInvalidURI, err)
+	}
+
+	anonRole = savedRole
+	url = startTestRouter(t)
+	if err = createLocalCallee(getLocalClient(), adminPrefix+".publish", adminPublish); err != nil {
+		t.Fatal(err)
+	}
+	_, err = testCall(connectTestClient(t, url, realm), adminPrefix+".publish", wamp.List{"test.notice"}, nil)
+	if uri := errorURI(err); uri != wamp.ErrNotAuthorized {
+		t.Errorf("expected %s without the admin role, got %v", wamp.ErrNotAuthorized, err)
+	}
+}
+
+func TestAdminRole(t *testing.T) {
+	url := startTestRouter(t, "other")
+	if err := createLocalCallee(getLocalClient(), adminPrefix+".realm.close", adminRealmClose); err != nil {
+		t.Fatal(err)
+	}
+	_, err := testCall(connectTestClient(t, url, realm), adminPrefix+".realm.close", wamp.List{"other"}, nil)
+	if uri := errorURI(err); uri != wamp.ErrNotAuthorized {
+		t.Errorf("expected %s without the admin role, got %v", wamp.ErrNotAuthorized, err)
+	}
+}
+
+func TestAdminPrefix(t *testing.T) {
+	saved := adminPrefix
+	defer func() { adminPrefix = saved }()
+	adminPrefix = "ops.admin"
+
+	if !isAdminURI("ops.admin.realm.close") || isAdminURI("nexus.admin.realm.close") {
+		t.Error("admin URIs should follow the configured prefix")
+	}
+
+	url := startTestRouter(t)
+	noop := func(context.Context, *wamp.Invocation) client.InvokeResult { return client.InvokeResult{} }
+	for _, procedure := range []string{"ops.admin.test", "nexus.admin.test"} {
+		if err := createLocalCallee(getLocalClient(), procedure, noop); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := connectTestClient(t, url, realm)
+	if _, err := testCall(c, "ops.admin.test", nil, nil); errorURI(err) != wamp.ErrNotAuthorized {
+		t.Errorf("expected %s under the custom prefix, got %v", wamp.ErrNotAuthorized, err)
+	}
+	if _, err := testCall(c, "nexus.admin.test", nil, nil); err != nil {
+		t.Errorf("expected the default prefix to be unrestricted, got %v", err)
+	}
+}
+
+func TestAdminSessionsGet(t *testing.T) {
+	savedRole := anonRole
+	defer func() { anonRole = savedRole }()
+	anonRole = adminRole
+
+	url := startTestRouter(t)
+	if err := createLocalCallee(getLocalClient(), adminPrefix+".sessions.get", adminSessionsGet); err != nil {
+		t.Fatal(err)
+	}
+	admin := connectTestClient(t, url, realm)
+	target := connectTestClient(t, url, realm)
+	for _, topic := range []string{"test.a", "test.b"} {
+		if err := target.Subscribe(topic, func(*wamp.Event) {}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := target.Register("test.proc", func(context.Context, *wamp.Invocation) client.InvokeResult {
+		return client.InvokeResult{}
+	}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := testCall(admin, adminPrefix+".sessions.get", wamp.List{target.ID()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	details, _ := wamp.AsDict(res.Arguments[0])
+	if id, _ := wamp.AsID(details["session"]); id != target.ID() {
+		t.Errorf("expected session %d, got %v", target.ID(), details["session"])
+	}
+	if details["authrole"] != adminRole || details["transport_type"] != "websocket" {
+		t.Errorf("unexpected details %v", details)
+	}
+	if _, err := time.Parse(time.RFC3339, fmt.Sprint(details["connected_at"])); err != nil {
+		t.Errorf("expected an RFC 3339 connected_at, got %v", details["connected_at"])
+	}
+	subs, _ := wamp.AsInt64(details["subscriptions"])
+	regs, _ := wamp.AsInt64(details["registrations"])
+	if subs != 2 || regs != 1 {
+		t.Errorf("expected 2 subscriptions and 1 registration, got %d and %d", subs, regs)
+	}
+
+	_, err = testCall(admin, adminPrefix+".sessions.get", wamp.List{wamp.ID(1)}, nil)
+	if uri := errorURI(err); uri != wamp.ErrNoSuchSession {
+		t.Errorf("expected %s for an unknown session, got %v", wamp.ErrNoSuchSession, err)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// pageIDs returns a page of the IDs in ascending order and the continuation
+// token of the next page, empty for the last one.  The page starts after the
+// ID of the next kwarg, skips offset IDs and holds up to limit IDs, capped to
+// -admin-page-size.
+func pageIDs(ids []wamp.ID, kwargs wamp.Dict) ([]wamp.ID, string, error) {
+	limit := int64(adminPage)
+	if v, ok := kwargs["limit"]; ok {
+		if n, ok := wamp.AsInt64(v); !ok || n < 1 {
+			return nil, "", fmt.Errorf("limit must be a positive integer, got %v", v)
+		} else if n < limit {
+			limit = n
+		}
+	}
+	var offset int64
+	if v, ok := kwargs["offset"]; ok {
+		if offset, ok = wamp.AsInt64(v); !ok || offset < 0 {
+			return nil, "", fmt.Errorf("offset must be a non-negative integer, got %v", v)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	if v, ok := kwargs["next"]; ok {
+		s, _ := wamp.AsString(v)
+		after, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid continuation token %v", v)
+		}
+		ids = ids[sort.Search(len(ids), func(i int) bool { return ids[i] > wamp.ID(after) }):]
+	}
+	if offset > int64(len(ids)) {
+		offset = int64(len(ids))
+	}
+	ids = ids[offset:]
+	if int64(len(ids)) <= limit {
+		return ids, "", nil
+	}
+	ids = ids[:limit]
+	return ids, strconv.FormatUint(uint64(ids[len(ids)-1]), 10), nil
+}
+
+// adminSessionsList handles <admin-prefix>.sessions.list.  It returns a page
+// of the details of the remote sessions of the realm kwarg, defaulting to the
+// -realm realm.
+func adminSessionsList(ctx context.Context, inv *wamp.Invocation) client.InvokeResult {
+	return adminListPage(ctx, inv, "sessions", wamp.MetaProcSessionGet, func(ctx context.Context, uri wamp.URI, c *client.Client) ([]wamp.ID, error) {
+		joined, err := realmSessionIDs(ctx, uri)
+		ids := make([]wamp.ID, 0, len(joined))
+		for id := range joined {
+			ids = append(ids, id)
+		}
+		return ids, err
+	})
+}
+
+// adminRegistrationsList handles <admin-prefix>.registrations.list.  It
+// returns a page of the details of the registrations of the realm kwarg,
+// whatever their match policy.
+func adminRegistrationsList(ctx context.Context, inv *wamp.Invocation) client.InvokeResult {
+	return adminListPage(ctx, inv, "registrations", wamp.MetaProcRegGet, func(ctx context.Context, uri wamp.URI, c *client.Client) ([]wamp.ID, error) {
+		return metaIDs(ctx, c, wamp.MetaProcRegList)
+	})
+}
+
+// adminListPage lists the IDs of the realm, then returns the details of the
+// page from the get meta procedure under key, with the total count and the
+// next continuation token.  Entries removed since they were listed are left
+// out of the page.
+func adminListPage(ctx context.Context, inv *wamp.Invocation, key string, get wamp.URI, list func(context.Context, wamp.URI, *client.Client) ([]wamp.ID, error)) client.InvokeResult {
+	uri, _ := wamp.AsURI(inv.ArgumentsKw["realm"])
+	if uri == "" {
+		uri = wamp.URI(realm)
+	}
+	c, err := realmClient(uri)
+	if err != nil {
+		return client.InvokeResult{Err: wamp.ErrNoSuchRealm, Args: wamp.List{fmt.Sprintf("%s %q: %s", errNoRealm, uri, err)}}
+	}
+	ids, err := list(ctx, uri, c)
+	if err != nil {
+		return client.InvokeResult{Err: errInternal, Args: wamp.List{err.Error()}}
+	}
+	total := len(ids)
+	page, next, err := pageIDs(ids, inv.ArgumentsKw)
+	if err != nil {
+		return client.InvokeResult{Err: wamp.ErrInvalidArgument, Args: wamp.List{err.Error()}}
+	}
+	entries := make(wamp.List, 0, len(page))
+	for _, id := range page {
+		res, err := c.Call(ctx, string(get), nil, wamp.List{id}, nil, nil)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, res.Arguments[0])
+	}
+	result := wamp.Dict{key: entries, "total": total}
+	if next != "" {
+		result["next"] = next
+	}
+	return client.InvokeResult{Args: wamp.List{result}}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestPageIDs(t *testing.T) {
+	saved := adminPage
+	defer func() { adminPage = saved }()
+	adminPage = 3
+	ids := []wamp.ID{5, 1, 4, 2, 3}
+
+	page, next, err := pageIDs(ids, wamp.Dict{"limit": 10})
+	if err != nil || len(page) != 3 || page[0] != 1 || page[2] != 3 || next != "3" {
+		t.Errorf("expected the page size to cap the limit, got %v %q %v", page, next, err)
+	}
+	page, next, err = pageIDs(ids, wamp.Dict{"next": next, "offset": 1})
+	if err != nil || len(page) != 1 || page[0] != 5 || next != "" {
+		t.Errorf("expected the last page, got %v %q %v", page, next, err)
+	}
+	if page, _, err = pageIDs(ids, wamp.Dict{"offset": 10}); err != nil || len(page) != 0 {
+		t.Errorf("expected an empty page past the end, got %v %v", page, err)
+	}
+	for _, kwargs := range []wamp.Dict{{"limit": 0}, {"limit": "a"}, {"offset": -1}, {"next": "x"}} {
+		if _, _, err := pageIDs(ids, kwargs); err == nil {
+			t.Errorf("%v: expected an error", kwargs)
+		}
+	}
+}
+
+func TestAdminListPaging(t *testing.T) {
+	savedRole := anonRole
+	defer func() { anonRole = savedRole }()
+	anonRole = adminRole
+
+	url := startTestRouter(t)
+	for proc, handler := range map[string]client.InvocationHandler{
+		adminPrefix + ".sessions.list":      adminSessionsList,
+		adminPrefix + ".registrations.list": adminRegistrationsList,
+	} {
+		if err := createLocalCallee(getLocalClient(), proc, handler); err != nil {
+			t.Fatal(err)
+		}
+	}
+	admin := connectTestClient(t, url, realm)
+	want := map[wamp.ID]bool{admin.ID(): true}
+	for i := 0; i < 4; i++ {
+		c := connectTestClient(t, url, realm)
+		want[c.ID()] = true
+		if err := c.Register("test.proc."+string(rune('a'+i)), func(context.Context, *wamp.Invocation) client.InvokeResult {
+			return client.InvokeResult{}
+		}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// pageAll pages through the list procedure and returns the entries.
+	pageAll := func(proc, key string) []wamp.Dict {
+		var entries []wamp.Dict
+		kwargs := wamp.Dict{"limit": 2}
+		for pages := 0; ; pages++ {
+			if pages > 50 {
+				t.Fatalf("%s: paging does not end", proc)
+			}
+			res, err := testCall(admin, proc, nil, kwargs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, _ := wamp.AsDict(res.Arguments[0])
+			page, _ := wamp.AsList(result[key])
+			next, more := result["next"]
+			if len(page) > 2 || (more && len(page) != 2) {
+				t.Errorf("%s: unexpected page %v", proc, result)
+			}
+			for _, e := range page {
+				e, _ := wamp.AsDict(e)
+				entries = append(entries, e)
+			}
+			if !more {
+				return entries
+			}
+			kwargs["next"] = next
+		}
+	}
+
+	sessions := pageAll(adminPrefix+".sessions.list", "sessions")
+	got := map[wamp.ID]bool{}
+	for _, s := range sessions {
+		id, _ := wamp.AsID(s["session"])
+		got[id] = true
+	}
+	if len(sessions) != len(want) || len(got) != len(want) {
+		t.Errorf("expected the %d sessions once each, got %v", len(want), sessions)
+	}
+	for id := range want {
+		if !got[id] {
+			t.Errorf("session %d not listed", id)
+		}
+	}
+
+	procs := map[string]bool{}
+	for _, r := range pageAll(adminPrefix+".registrations.list", "registrations") {
+		uri, _ := wamp.AsString(r["uri"])
+		procs[uri] = true
+	}
+	for _, proc := range []string{"test.proc.a", "test.proc.d", adminPrefix + ".sessions.list"} {
+		if !procs[proc] {
+			t.Errorf("registration of %s not listed in %v", proc, procs)
+		}
+	}
+
+	_, err := testCall(admin, adminPrefix+".sessions.list", nil, wamp.Dict{"next": "bogus"})
+	if uri := errorURI(err); uri != wamp.ErrInvalidArgument {
+		t.Errorf("expected %s for an invalid token, got %v", wamp.ErrInvalidArgument, err)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+var (
+	authCountsMu sync.Mutex
+	// authCounts holds the number of successful and failed authentications
+	// per auth method.
+	authCounts = map[string]*authCount{}
+)
+
+type authCount struct {
+	Successes uint64
+	Failures  uint64
+}
+
+// countAuth counts the outcome of an authentication with method.
+func countAuth(method string, err error) {
+	authCountsMu.Lock()
+	defer authCountsMu.Unlock()
+	count := authCounts[method]
+	if count == nil {
+		count = &authCount{}
+		authCounts[method] = count
+	}
+	if err != nil {
+		count.Failures++
+	} else {
+		count.Successes++
+	}
+}
+
+// authStats returns the authentication counts as published in the stats.
+func authStats() wamp.Dict {
+	authCountsMu.Lock()
+	defer authCountsMu.Unlock()
+	stats := wamp.Dict{}
+	for method, count := range authCounts {
+		stats[method] = wamp.Dict{"successes": count.Successes, "failures": count.Failures}
+	}
+	return stats
+}
+
+// anonymousAuth authenticates anonymous sessions with a configurable authid
+// and authrole.  An empty authid generates a unique one per session, as the
+// nexus default authenticator does.  Joins are rejected while the realm is
+// overloaded, or when the client lacks a -required-client-features role.  The
+// transport of the session is added to its details, where callees can look it
+// up with wamp.session.get, and authextra is filtered.  Sessions are killed
+// after -max-session-lifetime.  The WELCOME advertises agent and carries the
+// extra details.
+type anonymousAuth struct {
+	realm    wamp.URI
+	authID   string
+	authRole string
+	agent    string
+	extra    wamp.Dict
+}
+
+func (a *anonymousAuth) AuthMethod() string {
+	return "anonymous"
+}
+
+func (a *anonymousAuth) Authenticate(sid wamp.ID, details wamp.Dict, client wamp.Peer) (*wamp.Welcome, error) {
+	filterAuthExtra(details)
+	err := checkJoin(a.realm, sid, details)
+	if err == nil {
+		err = admitSession(a.realm, sid)
+	}
+	countAuth(a.AuthMethod(), err)
+	if err != nil {
+		return nil, err
+	}
+	authid := a.authID
+	if authid == "" {
+		authid = strconv.FormatInt(int64(wamp.GlobalID()), 16)
+	}
+	expireSession(a.realm, sid)
+	return newWelcome(authid, a.authRole, "static", a.AuthMethod(), details, a.agent, a.extra), nil
+}
+
+// checkJoin returns an error for a HELLO to a draining realm, from a client
+// agent that is not allowed, missing a required client feature or using a
+// serializer the realm does not allow.
+func checkJoin(uri wamp.URI, sid wamp.ID, details wamp.Dict) error {
+	if err := checkDraining(uri); err != nil {
+		return err
+	}
+	if err := checkClientAgent(uri, sid, details); err != nil {
+		return err
+	}
+	if err := checkClientFeatures(details); err != nil {
+		return err
+	}
+	return checkRealmSerializer(uri, details)
+}
+
+// newWelcome returns the WELCOME of a session authenticated with method,
+// advertising agent and carrying the extra details.
+func newWelcome(authid, authrole, provider, method string, details wamp.Dict, agent string, extra wamp.Dict) *wamp.Welcome {
+	welcome := wamp.Dict{
+		"authid":         authid,
+		"authrole":       authrole,
+		"authprovider":   provider,
+		"authmethod":     method,
+		"transport_type": transportType(details),
+		"transport_tls":  false,
+		"connected_at":   time.Now().UTC().Format(time.RFC3339),
+		"agent":          agent,
+	}
+	for k, v := range extra {
+		welcome[k] = v
+	}
+	return &wamp.Welcome{Details: welcome}
+}
+
+// welcomeReserved are the WELCOME details set by the router, which
+// -welcome-details cannot replace.
+var welcomeReserved = []string{"authid", "authrole", "authprovider", "authmethod", "transport_type", "transport_tls", "connected_at", "agent", "roles"}
+
+// parseWelcomeDetails parses a comma separated list of key=value pairs of
+// string details added to the WELCOME.
+func parseWelcomeDetails(s string) (wamp.Dict, error) {
+	details := wamp.Dict{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid welcome detail %q, expected key=value", pair)
+		}
+		for _, r := range welcomeReserved {
+			if k == r {
+				return nil, fmt.Errorf("welcome detail %q is set by the router", k)
+			}
+		}
+		details[k] = strings.TrimSpace(v)
+	}
+	return details, nil
+}
+
+// defaultAgent returns the agent advertised when -agent is not set.
+func defaultAgent() string {
+	if v, _ := buildVersion(); v != "" && v != "(devel)" {
+		return "nexus-simple-router/" + v
+	}
+	return "nexus-simple-router"
+}
+
+// transportType returns the transport a session joined over, from its HELLO
+// details.  Only the WebSocket server provides transport details.  Neither
+// transport is served over TLS.
+func transportType(details wamp.Dict) string {
+	if _, ok := details["transport"]; ok {
+		return "websocket"
+	}
+	return "rawsocket"
+}
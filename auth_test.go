@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestAnonymousAuth(t *testing.T) {
+	savedID, savedRole := anonAuthID, anonRole
+	defer func() { anonAuthID, anonRole = savedID, savedRole }()
+
+	for _, tc := range []struct {
+		authID, authRole string
+	}{
+		{"", "anonymous"},
+		{"guest", "visitor"},
+	} {
+		anonAuthID, anonRole = tc.authID, tc.authRole
+		t.Run(tc.authRole, func(t *testing.T) {
+			url := startTestRouter(t)
+			c := connectTestClient(t, url, realm)
+
+			res, err := testCall(getLocalClient(), string(wamp.MetaProcSessionGet), wamp.List{c.ID()}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			details, _ := wamp.AsDict(res.Arguments[0])
+			authid, _ := wamp.AsString(details["authid"])
+			if tc.authID == "" && authid == "" || tc.authID != "" && authid != tc.authID {
+				t.Errorf("expected authid %q, got %q", tc.authID, authid)
+			}
+			if details["authrole"] != tc.authRole {
+				t.Errorf("expected authrole %q, got %v", tc.authRole, details["authrole"])
+			}
+			if details["authmethod"] != "anonymous" {
+				t.Errorf("expected authmethod anonymous, got %v", details["authmethod"])
+			}
+		})
+	}
+}
+
+func TestTransportDetails(t *testing.T) {
+	url := startTestRouter(t)
+	rs, err := newRawSocketServer(wsRouter).ListenAndServe("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+	rsURL := "tcp://" + rs.(net.Listener).Addr().String()
+
+	callee := connectTestClient(t, url, realm)
+	err = callee.Register("test.transport", func(ctx context.Context, inv *wamp.Invocation) client.InvokeResult {
+		res, err := callee.Call(ctx, string(wamp.MetaProcSessionGet), nil, wamp.List{inv.Details["caller"]}, nil, nil)
+		if err != nil {
+			return client.InvokeResult{Err: wamp.ErrInvalidArgument, Args: wamp.List{err.Error()}}
+		}
+		details, _ := wamp.AsDict(res.Arguments[0])
+		return client.InvokeResult{Args: wamp.List{details["transport_type"], details["transport_tls"]}}
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for transport, url := range map[string]string{"websocket": url, "rawsocket": rsURL} {
+		caller := connectTestClient(t, url, realm)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		res, err := caller.Call(ctx, "test.transport", wamp.Dict{wamp.OptDiscloseMe: true}, nil, nil, nil)
+		cancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Arguments[0] != transport || res.Arguments[1] != false {
+			t.Errorf("expected %s without TLS, got %v", transport, res.Arguments)
+		}
+	}
+}
+
+func TestAuthCounts(t *testing.T) {
+	saved := maxSessions
+	defer func() { maxSessions = saved }()
+	maxSessions = 1
+
+	url := startTestRouter(t)
+	connectTestClient(t, url, realm)
+	if _, err := client.ConnectNet(context.Background(), url, client.Config{Realm: realm, Logger: logger}); err == nil {
+		t.Fatal("expected the join over the limit to fail")
+	}
+	counts, _ := wamp.AsDict(authStats()["anonymous"])
+	if successes, _ := wamp.AsInt64(counts["successes"]); successes != 1 {
+		t.Errorf("expected 1 success, got %v", counts["successes"])
+	}
+	if failures, _ := wamp.AsInt64(counts["failures"]); failures != 1 {
+		t.Errorf("expected 1 failure, got %v", counts["failures"])
+	}
+}
+
+func TestWelcomeDetails(t *testing.T) {
+	savedAgent, savedExtra := agent, welcomeExtra
+	defer func() { agent, welcomeExtra = savedAgent, savedExtra }()
+	agent = "test-agent/1.0"
+	var err error
+	if welcomeExtra, err = parseWelcomeDetails("region=eu-west, tier=gold"); err != nil {
+		t.Fatal(err)
+	}
+
+	url := startTestRouter(t)
+	details := connectTestClient(t, url, realm).RealmDetails()
+	if details["agent"] != "test-agent/1.0" {
+		t.Errorf("expected the configured agent, got %v", details["agent"])
+	}
+	if details["region"] != "eu-west" || details["tier"] != "gold" {
+		t.Errorf("expected the custom welcome details, got %v", details)
+	}
+
+	for _, s := range []string{"region", "=eu", "authrole=admin", "agent=x"} {
+		if _, err := parseWelcomeDetails(s); err == nil {
+			t.Errorf("expected an error for %q", s)
+		}
+	}
+}
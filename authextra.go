@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+var (
+	// authextraAll keeps every authextra key a client sends in its HELLO but
+	// the denied ones, which is the default.
+	authextraAll = true
+	// authextraKeys holds the authextra keys kept unless authextraAll.
+	authextraKeys = map[string]bool{}
+	// authextraDeny holds the authextra keys always stripped.
+	authextraDeny = map[string]bool{}
+)
+
+// parseAuthExtra parses the comma separated authextra keys kept in session
+// details, * for all and empty for none, and the keys stripped from them.
+func parseAuthExtra(keep, deny string) error {
+	authextraAll = false
+	for _, key := range strings.Split(keep, ",") {
+		key = strings.TrimSpace(key)
+		switch {
+		case key == "":
+		case key == "*":
+			authextraAll = true
+		default:
+			authextraKeys[key] = true
+		}
+	}
+	if authextraAll && len(authextraKeys) != 0 {
+		return fmt.Errorf("invalid authextra keys %q, expected * or a list of keys", keep)
+	}
+	for _, key := range strings.Split(deny, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			authextraDeny[key] = true
+		}
+	}
+	return nil
+}
+
+// filterAuthExtra strips the authextra keys that are not kept from the HELLO
+// details, which become the session details once authenticated.  An
+// authextra that is not a dict is stripped.
+func filterAuthExtra(details wamp.Dict) {
+	if _, ok := details["authextra"]; !ok {
+		return
+	}
+	extra, ok := wamp.AsDict(details["authextra"])
+	if !ok {
+		delete(details, "authextra")
+		return
+	}
+	kept := wamp.Dict{}
+	for k, v := range extra {
+		if (authextraAll || authextraKeys[k]) && !authextraDeny[k] {
+			kept[k] = v
+		}
+	}
+	if len(kept) == 0 {
+		delete(details, "authextra")
+		return
+	}
+	details["authextra"] = kept
+}
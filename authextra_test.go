@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestParseAuthExtra(t *testing.T) {
+	savedAll, savedKeys, savedDeny := authextraAll, authextraKeys, authextraDeny
+	defer func() { authextraAll, authextraKeys, authextraDeny = savedAll, savedKeys, savedDeny }()
+
+	authextraKeys, authextraDeny = map[string]bool{}, map[string]bool{}
+	if err := parseAuthExtra("", ""); err != nil || authextraAll {
+		t.Errorf("expected authextra to be stripped, got %v", err)
+	}
+	if err := parseAuthExtra("*, device", ""); err == nil {
+		t.Error("expected an error for * with keys")
+	}
+}
+
+func TestAuthExtra(t *testing.T) {
+	savedAll, savedKeys, savedDeny := authextraAll, authextraKeys, authextraDeny
+	defer func() { authextraAll, authextraKeys, authextraDeny = savedAll, savedKeys, savedDeny }()
+	url := startTestRouter(t)
+
+	// join returns the authextra of the session details of a client joining
+	// with extra.
+	join := func(extra interface{}) (wamp.Dict, bool) {
+		c, err := client.ConnectNet(context.Background(), url, client.Config{
+			Realm:        realm,
+			Logger:       logger,
+			HelloDetails: wamp.Dict{"authextra": extra},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		res, err := testCall(getLocalClient(), string(wamp.MetaProcSessionGet), wamp.List{c.ID()}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		details, _ := wamp.AsDict(res.Arguments[0])
+		if _, ok := details["authextra"]; !ok {
+			return nil, false
+		}
+		got, _ := wamp.AsDict(details["authextra"])
+		return got, true
+	}
+	extra := wamp.Dict{"device": "sensor", "tenant": "acme", "token": "secret"}
+
+	for _, tc := range []struct {
+		keep, deny string
+		want       []string
+	}{
+		{"*", "", []string{"device", "tenant", "token"}},
+		{"*", "token, password", []string{"device", "tenant"}},
+		{"device,token", "token", []string{"device"}},
+		{"", "", nil},
+	} {
+		authextraKeys, authextraDeny = map[string]bool{}, map[string]bool{}
+		if err := parseAuthExtra(tc.keep, tc.deny); err != nil {
+			t.Fatal(err)
+		}
+		got, ok := join(extra)
+		if tc.want == nil {
+			if ok {
+				t.Errorf("%q: expected authextra to be stripped, got %v", tc.keep, got)
+			}
+			continue
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("%q -%q: expected keys %v, got %v", tc.keep, tc.deny, tc.want, got)
+		}
+		for _, k := range tc.want {
+			if got[k] != extra[k] {
+				t.Errorf("%q -%q: expected %s to be kept, got %v", tc.keep, tc.deny, k, got)
+			}
+		}
+	}
+
+	if _, ok := join("not a dict"); ok {
+		t.Error("expected an authextra that is not a dict to be stripped")
+	}
+}
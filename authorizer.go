@@ -0,0 +1,209 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// messageCount is the number of messages received from remote sessions.
+var messageCount uint64
+
+var (
+	realmCountsMu sync.Mutex
+	// realmCounts holds the traffic counters of each configured realm.
+	realmCounts = map[wamp.URI]*realmCount{}
+)
+
+// realmCount counts the calls and publishes remote sessions sent to a realm.
+type realmCount struct {
+	calls     uint64
+	publishes uint64
+}
+
+// newRealmCount returns the counters of the realm, creating them if needed.
+func newRealmCount(uri wamp.URI) *realmCount {
+	realmCountsMu.Lock()
+	defer realmCountsMu.Unlock()
+	count := realmCounts[uri]
+	if count == nil {
+		count = &realmCount{}
+		realmCounts[uri] = count
+	}
+	return count
+}
+
+// realmStats returns the counters of each realm as published in the stats.
+func realmStats() wamp.Dict {
+	realmCountsMu.Lock()
+	defer realmCountsMu.Unlock()
+	stats := wamp.Dict{}
+	for uri, count := range realmCounts {
+		stats[string(uri)] = wamp.Dict{
+			"calls":     atomic.LoadUint64(&count.calls),
+			"publishes": atomic.LoadUint64(&count.publishes),
+		}
+	}
+	if countBytes {
+		for uri, total := range realmByteCounts() {
+			if s, ok := stats[string(uri)].(wamp.Dict); ok {
+				s["bytes_in"], s["bytes_out"] = total[0], total[1]
+			}
+		}
+	}
+	return stats
+}
+
+// authorizer is called by the router for every message sent by a remote
+// session.
+type authorizer struct {
+	// uriPrefix, if set, restricts topics and procedures to those under it.
+	// Meta API URIs and calls to the router's own procedures are exempt.
+	uriPrefix string
+	// adminRole is the authrole required to call admin procedures.
+	adminRole string
+	// publishChecks can veto individual publishes.
+	publishChecks []publishCheck
+	// counts holds the traffic counters of the realm.
+	counts *realmCount
+	// subLimit and regLimit, if set, cap the subscriptions and registrations
+	// of each session.
+	subLimit *sessionLimit
+	regLimit *sessionLimit
+	// matches, if set, restricts the match policies of subscriptions and
+	// registrations.
+	matches *realmMatchPolicy
+	// prefixes, if set, rejects overlapping prefix registrations.
+	prefixes *prefixGuard
+	// quota, if set, limits the calls and publishes of the realm per window.
+	quota *realmQuota
+	// disclose makes the router disclose every caller and publisher.
+	disclose bool
+}
+
+// publishCheck inspects a publish and rejects it by returning an error.  The
+// error message is sent to the publisher, with the
+// wamp.error.authorization_failed URI, if it asked for an acknowledgement.
+type publishCheck func(topic wamp.URI, args wamp.List, kwargs wamp.Dict) error
+
+func (a *authorizer) Authorize(sess *wamp.Session, msg wamp.Message) (bool, error) {
+	atomic.AddUint64(&messageCount, 1)
+	switch msg.(type) {
+	case *wamp.Call:
+		atomic.AddUint64(&a.counts.calls, 1)
+	case *wamp.Publish:
+		atomic.AddUint64(&a.counts.publishes, 1)
+	}
+	checkMessageSize(sess, msg)
+	logDebugMessage(sess, msg)
+	logMessage(sess, msg)
+	if !allowedMeta(sess, msg) {
+		return false, nil
+	}
+	if uri, ok := messageURI(msg); ok && !a.allowURI(uri) && !routerCall(msg) && !routerTopic(msg) {
+		return false, nil
+	}
+	if err := checkMaintenance(msg); err != nil {
+		return false, err
+	}
+	if err := checkDisabled(msg); err != nil {
+		return false, err
+	}
+	if err := checkSerializerSize(sess, msg); err != nil {
+		return false, err
+	}
+	if pub, ok := msg.(*wamp.Publish); ok {
+		for _, check := range a.publishChecks {
+			if err := check(pub.Topic, pub.Arguments, pub.ArgumentsKw); err != nil {
+				return false, err
+			}
+		}
+	}
+	if err := a.matches.check(msg); err != nil {
+		return false, err
+	}
+	if err := a.prefixes.check(msg); err != nil {
+		return false, err
+	}
+	if _, ok := msg.(*wamp.Subscribe); ok && a.subLimit != nil {
+		if err := a.subLimit.admit(sess.ID); err != nil {
+			return false, err
+		}
+	}
+	if _, ok := msg.(*wamp.Register); ok && a.regLimit != nil {
+		if err := a.regLimit.admit(sess.ID); err != nil {
+			return false, err
+		}
+	}
+	if call, ok := msg.(*wamp.Call); ok {
+		if isAdminURI(call.Procedure) {
+			if authrole, _ := wamp.AsString(sess.Details["authrole"]); authrole != a.adminRole {
+				return false, nil
+			}
+		}
+		if !allowedCaller(sess, call.Procedure) {
+			return false, nil
+		}
+		limitCallTimeout(call)
+		// The caller cannot pass its own identity to nexus.whoami.
+		if whoamiOn && call.Procedure == whoamiProc {
+			call.Arguments, call.ArgumentsKw = nil, a.whoami(sess)
+		}
+	}
+	if err := a.quota.admit(msg); err != nil {
+		return false, err
+	}
+	if a.disclose {
+		forceDisclose(msg)
+	}
+	return true, nil
+}
+
+// allowURI reports whether uri is inside the configured prefix.
+func (a *authorizer) allowURI(uri wamp.URI) bool {
+	if a.uriPrefix == "" || strings.HasPrefix(string(uri), "wamp.") {
+		return true
+	}
+	return string(uri) == a.uriPrefix || strings.HasPrefix(string(uri), a.uriPrefix+".")
+}
+
+// routerCall reports whether msg calls a procedure provided by the router
+// itself.  Optional procedures such as dev.echo and proxy procedures are not
+// included and have to be inside the prefix to be callable.
+func routerCall(msg wamp.Message) bool {
+	call, ok := msg.(*wamp.Call)
+	if !ok {
+		return false
+	}
+	switch call.Procedure {
+	case "nexus.info", "nexus.features", "nexus.util.multipublish":
+		return true
+	case whoamiProc:
+		return whoamiOn
+	}
+	return strings.HasPrefix(string(call.Procedure), adminPrefix+".")
+}
+
+// routerTopic reports whether msg subscribes to a topic the router itself
+// publishes on in every realm.
+func routerTopic(msg wamp.Message) bool {
+	sub, ok := msg.(*wamp.Subscribe)
+	return ok && sub.Topic == drainTopic
+}
+
+// messageURI returns the topic or procedure a message refers to.
+func messageURI(msg wamp.Message) (wamp.URI, bool) {
+	switch msg := msg.(type) {
+	case *wamp.Publish:
+		return msg.Topic, true
+	case *wamp.Subscribe:
+		return msg.Topic, true
+	case *wamp.Register:
+		return msg.Procedure, true
+	case *wamp.Call:
+		return msg.Procedure, true
+	}
+	return "", false
+}
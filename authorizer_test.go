@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestURIPrefix(t *testing.T) {
+	saved := uriPrefix
+	defer func() { uriPrefix = saved }()
+	uriPrefix = "app"
+
+	url := startTestRouter(t, "other=svc")
+	if err := createLocalCallee(getLocalClient(), "nexus.info", nexusInfo); err != nil {
+		t.Fatal(err)
+	}
+	noop := func(context.Context, *wamp.Invocation) client.InvokeResult { return client.InvokeResult{} }
+
+	c := connectTestClient(t, url, realm)
+	if err := c.Register("app.proc", noop, nil); err != nil {
+		t.Errorf("expected app.proc to be allowed in %s: %s", realm, err)
+	}
+	if err := c.Register("svc.proc", noop, nil); err == nil {
+		t.Errorf("expected svc.proc to be denied in %s", realm)
+	}
+	if err := c.Subscribe("svc.topic", func(*wamp.Event) {}, nil); err == nil {
+		t.Errorf("expected svc.topic to be denied in %s", realm)
+	}
+	if _, err := testCall(c, "nexus.info", nil, nil); err != nil {
+		t.Errorf("expected nexus.info to be exempt from the prefix: %s", err)
+	}
+	if _, err := testCall(c, string(wamp.MetaProcSessionCount), nil, nil); err != nil {
+		t.Errorf("expected the meta API to be exempt from the prefix: %s", err)
+	}
+	if err := c.Subscribe(drainTopic, func(*wamp.Event) {}, nil); err != nil {
+		t.Errorf("expected %s to be exempt from the prefix: %s", drainTopic, err)
+	}
+
+	other := connectTestClient(t, url, "other")
+	if err := other.Register("svc.proc", noop, nil); err != nil {
+		t.Errorf("expected svc.proc to be allowed in other: %s", err)
+	}
+	if err := other.Register("app.proc2", noop, nil); err == nil {
+		t.Error("expected app.proc2 to be denied in other")
+	}
+}
+
+func TestStrictURI(t *testing.T) {
+	saved := strictURI
+	defer func() { strictURI = saved }()
+
+	for _, strictURI = range []bool{false, true} {
+		url := startTestRouter(t)
+		c := connectTestClient(t, url, realm)
+		subErr := c.Subscribe("App.Topic-1", func(*wamp.Event) {}, nil)
+		regErr := c.Register("App.Proc-1", func(context.Context, *wamp.Invocation) client.InvokeResult {
+			return client.InvokeResult{}
+		}, nil)
+		for kind, err := range map[string]error{"subscribe": subErr, "register": regErr} {
+			if rejected := err != nil && strings.Contains(err.Error(), string(wamp.ErrInvalidURI)); rejected != strictURI {
+				t.Errorf("strict %t: unexpected %s result %v", strictURI, kind, err)
+			}
+		}
+	}
+}
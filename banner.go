@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// startupSummary returns the effective configuration of the started router.
+// Proxy mapping URLs are left out, as they can carry credentials.
+func startupSummary(realms []wamp.URI) wamp.Dict {
+	methods := []string{"anonymous"}
+	if len(trustedNets) != 0 {
+		methods = append(methods, "trusted-header")
+	}
+	if tickets != nil {
+		methods = append(methods, "ticket")
+	}
+	if extAuthSock != "" {
+		methods = append(methods, "external")
+	}
+	return wamp.Dict{
+		"transports":   transports,
+		"realms":       realms,
+		"local_realms": localRealms,
+		"auth_methods": methods,
+		"anon_role":    anonRole,
+		"log_level":    logLevel,
+		"features":     features(),
+		"limits":       limits(),
+	}
+}
+
+// logStartup logs the startup summary as a single JSON entry, followed by the
+// ready line and lifecycle event.
+func logStartup(realms []wamp.URI) {
+	summary, err := json.Marshal(startupSummary(realms))
+	if err != nil {
+		logger.Printf("failed to encode startup summary: %s\n", err)
+	} else {
+		logger.Printf("startup %s\n", summary)
+	}
+	logger.Printf("ready\n")
+	emitLifecycle(lifecycleReady, wamp.Dict{"transports": transports})
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestLogStartup(t *testing.T) {
+	savedLogger, savedTransports := logger, transports
+	defer func() { logger, transports = savedLogger, savedTransports }()
+	var buf bytes.Buffer
+	logger = log.New(&buf, "", 0)
+	transports = wamp.List{"ws://localhost:8951", "tcp://127.0.0.1:8952"}
+
+	logStartup([]wamp.URI{"default", "tenant"})
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || lines[1] != "ready" {
+		t.Fatalf("expected the summary and the ready line, got %q", buf.String())
+	}
+	var summary struct {
+		Transports []string `json:"transports"`
+		Realms     []string `json:"realms"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[0], "startup ")), &summary); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(summary.Transports, " ") != "ws://localhost:8951 tcp://127.0.0.1:8952" {
+		t.Errorf("unexpected transports %v", summary.Transports)
+	}
+	if strings.Join(summary.Realms, " ") != "default tenant" {
+		t.Errorf("unexpected realms %v", summary.Realms)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gammazero/nexus/v3/router"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// byteCount counts the bytes read from and written to a WebSocket connection,
+// as sent on the wire.
+type byteCount struct {
+	in, out atomic.Uint64
+
+	// realm and session are set once the session of the connection joined,
+	// under bytesMu, and closed once the connection is closed.
+	realm   wamp.URI
+	session wamp.ID
+	closed  bool
+}
+
+var (
+	bytesMu sync.Mutex
+	// connBytes holds the counts of the open connections by remote address.
+	connBytes = map[string]*byteCount{}
+	// sessionBytes holds the counts of the joined sessions.
+	sessionBytes = map[wamp.ID]*byteCount{}
+	// realmBytes holds the bytes of the closed sessions of each realm, as
+	// in and out.
+	realmBytes = map[wamp.URI]*[2]uint64{}
+)
+
+// countingListener counts the bytes of the connections it accepts, with
+// -count-bytes.
+type countingListener struct {
+	net.Listener
+}
+
+func (l countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	c := &countingConn{Conn: conn, addr: conn.RemoteAddr().String(), count: &byteCount{}}
+	bytesMu.Lock()
+	connBytes[c.addr] = c.count
+	bytesMu.Unlock()
+	return c, nil
+}
+
+// countingConn is a connection accepted by countingListener.  It stays the
+// same connection once upgraded to WebSocket.
+type countingConn struct {
+	net.Conn
+	addr      string
+	count     *byteCount
+	closeOnce sync.Once
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.count.in.Add(uint64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.count.out.Add(uint64(n))
+	return n, err
+}
+
+// Close moves the bytes of the session of the connection to its realm's
+// total.
+func (c *countingConn) Close() error {
+	c.closeOnce.Do(func() {
+		bytesMu.Lock()
+		defer bytesMu.Unlock()
+		delete(connBytes, c.addr)
+		c.count.closed = true
+		if c.count.session == 0 {
+			return
+		}
+		delete(sessionBytes, c.count.session)
+		total := realmBytes[c.count.realm]
+		if total == nil {
+			total = &[2]uint64{}
+			realmBytes[c.count.realm] = total
+		}
+		total[0] += c.count.in.Load()
+		total[1] += c.count.out.Load()
+	})
+	return c.Conn.Close()
+}
+
+// countingRouter links the WebSocket clients attached to the router to the
+// byte counts of their connections, by the remote address of the captured
+// upgrade request.  RawSocket clients are attached without the request and
+// are not counted.
+type countingRouter struct {
+	router.Router
+}
+
+func (r countingRouter) AttachClient(client wamp.Peer, transportDetails wamp.Dict) error {
+	auth, _ := wamp.AsDict(transportDetails["auth"])
+	if req, ok := auth["request"].(*http.Request); ok {
+		bytesMu.Lock()
+		count := connBytes[req.RemoteAddr]
+		bytesMu.Unlock()
+		if count != nil {
+			client = newCountingPeer(client, count)
+		}
+	}
+	return r.Router.AttachClient(client, transportDetails)
+}
+
+// countingPeer records the realm of the HELLO and the session ID of the
+// WELCOME of a peer in its byte count.
+type countingPeer struct {
+	wamp.Peer
+	count     *byteCount
+	realm     atomic.Value
+	recv      chan wamp.Message
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newCountingPeer(p wamp.Peer, count *byteCount) *countingPeer {
+	cp := &countingPeer{Peer: p, count: count, recv: make(chan wamp.Message), done: make(chan struct{})}
+	go func() {
+		defer close(cp.recv)
+		for msg := range p.Recv() {
+			if hello, ok := msg.(*wamp.Hello); ok {
+				cp.realm.Store(hello.Realm)
+			}
+			select {
+			case cp.recv <- msg:
+			case <-cp.done:
+				return
+			}
+		}
+	}()
+	return cp
+}
+
+func (p *countingPeer) Send(msg wamp.Message) error {
+	if welcome, ok := msg.(*wamp.Welcome); ok {
+		realm, _ := p.realm.Load().(wamp.URI)
+		bytesMu.Lock()
+		if !p.count.closed {
+			p.count.realm, p.count.session = realm, welcome.ID
+			sessionBytes[welcome.ID] = p.count
+		}
+		bytesMu.Unlock()
+	}
+	return p.Peer.Send(msg)
+}
+
+func (p *countingPeer) Recv() <-chan wamp.Message {
+	return p.recv
+}
+
+func (p *countingPeer) Close() {
+	p.closeOnce.Do(func() { close(p.done) })
+	p.Peer.Close()
+}
+
+// sessionByteCount returns the bytes received from and sent to a session, or
+// false if its bytes are not counted.
+func sessionByteCount(sid wamp.ID) (uint64, uint64, bool) {
+	bytesMu.Lock()
+	defer bytesMu.Unlock()
+	count := sessionBytes[sid]
+	if count == nil {
+		return 0, 0, false
+	}
+	return count.in.Load(), count.out.Load(), true
+}
+
+// realmByteCounts returns the bytes received from and sent to the sessions of
+// each realm since the router started, as in and out.
+func realmByteCounts() map[wamp.URI][2]uint64 {
+	bytesMu.Lock()
+	defer bytesMu.Unlock()
+	totals := make(map[wamp.URI][2]uint64, len(realmBytes))
+	for uri, total := range realmBytes {
+		totals[uri] = *total
+	}
+	for _, count := range sessionBytes {
+		total := totals[count.realm]
+		total[0] += count.in.Load()
+		total[1] += count.out.Load()
+		totals[count.realm] = total
+	}
+	return totals
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestCountBytes(t *testing.T) {
+	saved := countBytes
+	defer func() { countBytes = saved }()
+	countBytes = true
+
+	startTestRouter(t)
+	err := createLocalCallee(getLocalClient(), "test.echo", func(_ context.Context, inv *wamp.Invocation) client.InvokeResult {
+		return client.InvokeResult{Args: inv.Arguments}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	closer, addr, err := listenWebsocket(newWebsocketServer(countingRouter{wsRouter}), "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+	c := connectTestClient(t, "ws://"+addr.String(), realm)
+
+	in, out, ok := sessionByteCount(c.ID())
+	if !ok || in == 0 || out == 0 {
+		t.Fatalf("expected the bytes of the handshake and join, got %d in and %d out", in, out)
+	}
+	payload := strings.Repeat("x", 1000)
+	if _, err = testCall(c, "test.echo", wamp.List{payload}, nil); err != nil {
+		t.Fatal(err)
+	}
+	in2, out2, _ := sessionByteCount(c.ID())
+	if in2 < in+1000 || out2 < out+1000 {
+		t.Errorf("expected the call and its result to add 1000 bytes each way, got %d->%d in and %d->%d out", in, in2, out, out2)
+	}
+
+	res := adminSessionsGet(context.Background(), &wamp.Invocation{Arguments: wamp.List{c.ID()}})
+	if res.Err != "" {
+		t.Fatal(res.Err, res.Args)
+	}
+	details, _ := wamp.AsDict(res.Args[0])
+	if got, _ := wamp.AsInt64(details["bytes_in"]); got < int64(in2) {
+		t.Errorf("expected bytes_in of at least %d, got %v", in2, details["bytes_in"])
+	}
+
+	// The bytes of a closed session stay in the realm total.
+	c.Close()
+	removed := waitFor(t, 5*time.Second, func() bool {
+		_, _, ok := sessionByteCount(c.ID())
+		return !ok
+	})
+	if !removed {
+		t.Fatal("session count not removed after disconnect")
+	}
+	if total := realmByteCounts()[wamp.URI(realm)]; total[0] < in2 || total[1] < out2 {
+		t.Errorf("expected the realm total to keep the session bytes, got %v", total)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// Kinds of call failures returned by localCall, to check with errors.Is.
+var (
+	// errCallNotFound is returned for a missing procedure, or a missing
+	// session, realm, registration or subscription named in the call.
+	errCallNotFound = errors.New("not found")
+	// errCallNotAuthorized is returned for calls the router refuses.
+	errCallNotAuthorized = errors.New("not authorized")
+	// errCallTimeout is returned for calls canceled by a timeout.
+	errCallTimeout = errors.New("timeout")
+	// errCallCallee is returned for any other error of the callee.
+	errCallCallee = errors.New("callee error")
+)
+
+// callErrorKinds maps WAMP error URIs to the kind of call failure.  Error URIs
+// missing from it are callee errors.
+var callErrorKinds = map[wamp.URI]error{
+	wamp.ErrNoSuchProcedure:     errCallNotFound,
+	wamp.ErrNoSuchSession:       errCallNotFound,
+	wamp.ErrNoSuchRealm:         errCallNotFound,
+	wamp.ErrNoSuchRegistration:  errCallNotFound,
+	wamp.ErrNoSuchSubscription:  errCallNotFound,
+	wamp.ErrNotAuthorized:       errCallNotAuthorized,
+	wamp.ErrAuthorizationFailed: errCallNotAuthorized,
+	wamp.ErrCanceled:            errCallTimeout,
+}
+
+// callError is a failed call with the kind of its failure.  It keeps the WAMP
+// error, if there was one, for the details.
+type callError struct {
+	kind error
+	err  error
+	// rpc is the WAMP error of the call, nil for failures of the client.
+	rpc *wamp.Error
+}
+
+func (e *callError) Error() string { return e.err.Error() }
+
+func (e *callError) Unwrap() error { return e.kind }
+
+// translateCallError returns the error of a client call as a *callError.  Errors that
+// are not call failures, such as a disconnected client, are returned as they
+// are.
+func translateCallError(err error) error {
+	var rpcErr client.RPCError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &rpcErr):
+		kind, ok := callErrorKinds[rpcErr.Err.Error]
+		if !ok {
+			kind = errCallCallee
+		}
+		return &callError{kind, err, rpcErr.Err}
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, client.ErrReplyTimeout):
+		return &callError{errCallTimeout, err, nil}
+	}
+	return err
+}
+
+// localCall calls the procedure with the local client, returning failures as
+// a *callError of the kinds above.
+func localCall(ctx context.Context, procedure wamp.URI, args wamp.List, kwargs wamp.Dict) (*wamp.Result, error) {
+	res, err := getLocalClient().Call(ctx, string(procedure), nil, args, kwargs, nil)
+	return res, translateCallError(err)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestTranslateCallError(t *testing.T) {
+	for uri, kind := range map[wamp.URI]error{
+		wamp.ErrNoSuchProcedure:     errCallNotFound,
+		wamp.ErrNoSuchSession:       errCallNotFound,
+		wamp.ErrNoSuchRealm:         errCallNotFound,
+		wamp.ErrNoSuchRegistration:  errCallNotFound,
+		wamp.ErrNoSuchSubscription:  errCallNotFound,
+		wamp.ErrNotAuthorized:       errCallNotAuthorized,
+		wamp.ErrAuthorizationFailed: errCallNotAuthorized,
+		wamp.ErrCanceled:            errCallTimeout,
+		wamp.ErrInvalidArgument:     errCallCallee,
+		"com.example.error.custom":  errCallCallee,
+	} {
+		err := translateCallError(client.RPCError{Err: &wamp.Error{Error: uri}, Procedure: "test.proc"})
+		if !errors.Is(err, kind) {
+			t.Errorf("%s: expected %q, got %v", uri, kind, err)
+		}
+		var callErr *callError
+		if !errors.As(err, &callErr) || callErr.rpc.Error != uri {
+			t.Errorf("%s: expected the WAMP error to be kept, got %#v", uri, err)
+		}
+	}
+	if err := translateCallError(context.DeadlineExceeded); !errors.Is(err, errCallTimeout) {
+		t.Errorf("expected a timeout for an expired context, got %v", err)
+	}
+	if err := translateCallError(client.ErrNotConn); err != client.ErrNotConn {
+		t.Errorf("expected client errors to be returned as they are, got %v", err)
+	}
+	if translateCallError(nil) != nil {
+		t.Error("expected no error for a successful call")
+	}
+}
+
+func TestLocalCall(t *testing.T) {
+	startTestRouter(t)
+	err := createLocalCallee(getLocalClient(), "test.fail", func(context.Context, *wamp.Invocation) client.InvokeResult {
+		return client.InvokeResult{Err: "com.example.error.custom", Args: wamp.List{"failed"}}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = createLocalCallee(getLocalClient(), "test.slow", func(ctx context.Context, _ *wamp.Invocation) client.InvokeResult {
+		<-ctx.Done()
+		return client.InvokeResult{Err: wamp.ErrCanceled}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res, err := localCall(context.Background(), wamp.MetaProcSessionCount, nil, nil); err != nil || len(res.Arguments) != 1 {
+		t.Errorf("unexpected result %v: %v", res, err)
+	}
+	if _, err = localCall(context.Background(), "test.missing", nil, nil); !errors.Is(err, errCallNotFound) {
+		t.Errorf("expected not found, got %v", err)
+	}
+	_, err = localCall(context.Background(), "test.fail", nil, nil)
+	var callErr *callError
+	if !errors.Is(err, errCallCallee) || !errors.As(err, &callErr) || callErr.rpc.Arguments[0] != "failed" {
+		t.Errorf("expected the callee error, got %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err = localCall(ctx, "test.slow", nil, nil); !errors.Is(err, errCallTimeout) {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+}
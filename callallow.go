@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// callAllowlist maps procedures to the authids allowed to call them, set with
+// -call-allowlist.  Procedures missing from it can be called by any authid.
+var callAllowlist = map[wamp.URI]map[string]bool{}
+
+// parseCallAllowlist merges a comma separated list of procedure=authid+authid
+// entries into callAllowlist.
+func parseCallAllowlist(s string) error {
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		uri, list, ok := strings.Cut(entry, "=")
+		if !ok || !wamp.URI(uri).ValidURI(false, "") || list == "" {
+			return fmt.Errorf("invalid call allowlist %q, expected procedure=authid+authid", entry)
+		}
+		authids := callAllowlist[wamp.URI(uri)]
+		if authids == nil {
+			authids = map[string]bool{}
+			callAllowlist[wamp.URI(uri)] = authids
+		}
+		for _, authid := range strings.Split(list, "+") {
+			if authid == "" {
+				return fmt.Errorf("empty authid in call allowlist %q", entry)
+			}
+			authids[authid] = true
+		}
+	}
+	return nil
+}
+
+// allowedCaller reports whether the authid of sess may call the procedure.
+func allowedCaller(sess *wamp.Session, procedure wamp.URI) bool {
+	authids, ok := callAllowlist[procedure]
+	if !ok {
+		return true
+	}
+	authid, _ := wamp.AsString(sess.Details["authid"])
+	return authids[authid]
+}
+
+// deniedProcedures returns the allowlisted procedures sess may not call,
+// sorted.
+func deniedProcedures(sess *wamp.Session) []wamp.URI {
+	denied := map[wamp.URI]bool{}
+	for uri := range callAllowlist {
+		if !allowedCaller(sess, uri) {
+			denied[uri] = true
+		}
+	}
+	return sortedURIs(denied)
+}
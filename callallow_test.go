@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestCallAllowlist(t *testing.T) {
+	savedList, savedTickets := callAllowlist, tickets
+	defer func() { callAllowlist, tickets = savedList, savedTickets }()
+	callAllowlist = map[wamp.URI]map[string]bool{}
+	if err := parseCallAllowlist("test.payroll=alice+carol"); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"test.payroll", "bad..proc=alice", "test.payroll=alice+"} {
+		if err := parseCallAllowlist(s); err == nil {
+			t.Errorf("expected an error for %q", s)
+		}
+	}
+	tickets = ticketStore{"alice": {Ticket: "a", Role: "user"}, "bob": {Ticket: "b", Role: "user"}}
+
+	url := startTestRouter(t)
+	for _, procedure := range []string{"test.payroll", "test.open"} {
+		err := createLocalCallee(getLocalClient(), procedure, func(context.Context, *wamp.Invocation) client.InvokeResult {
+			return client.InvokeResult{}
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// join connects as authid with its ticket.
+	join := func(authid string) *client.Client {
+		c, err := client.ConnectNet(context.Background(), url, client.Config{
+			Realm:        realm,
+			Logger:       logger,
+			HelloDetails: wamp.Dict{"authid": authid},
+			AuthHandlers: map[string]client.AuthFunc{
+				"ticket": func(*wamp.Challenge) (string, wamp.Dict) { return tickets[authid].Ticket, wamp.Dict{} },
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { c.Close() })
+		return c
+	}
+	alice, bob := join("alice"), join("bob")
+
+	if _, err := testCall(alice, "test.payroll", nil, nil); err != nil {
+		t.Errorf("expected alice to call the procedure, got %v", err)
+	}
+	_, err := testCall(bob, "test.payroll", nil, nil)
+	if uri := errorURI(err); uri != wamp.ErrNotAuthorized {
+		t.Errorf("expected %s for bob, got %v", wamp.ErrNotAuthorized, err)
+	}
+	if _, err = testCall(bob, "test.open", nil, nil); err != nil {
+		t.Errorf("expected bob to call a procedure without allowlist, got %v", err)
+	}
+	// nexus.whoami lists the procedures bob may not call.
+	if denied := deniedProcedures(&wamp.Session{Details: wamp.Dict{"authid": "bob"}}); len(denied) != 1 || denied[0] != "test.payroll" {
+		t.Errorf("expected test.payroll to be denied to bob, got %v", denied)
+	}
+}
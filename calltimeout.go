@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// callTimeouts maps procedures to the maximum duration of their calls.
+var callTimeouts = map[wamp.URI]time.Duration{}
+
+// parseCallTimeouts merges a comma separated list of procedure=duration pairs
+// into callTimeouts.
+func parseCallTimeouts(s string) error {
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		procedure, duration, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("invalid call timeout %q, expected procedure=duration", pair)
+		}
+		if !wamp.URI(procedure).ValidURI(false, "") {
+			return fmt.Errorf("invalid procedure in call timeout %q", pair)
+		}
+		d, err := time.ParseDuration(duration)
+		if err != nil || d < time.Millisecond {
+			return fmt.Errorf("invalid duration in call timeout %q", pair)
+		}
+		callTimeouts[wamp.URI(procedure)] = d
+	}
+	return nil
+}
+
+// limitCallTimeout lowers the timeout option of a call to the maximum of its
+// procedure.  The dealer cancels calls running past their timeout, which the
+// caller sees as wamp.error.canceled, and interrupts the callee.  It can only
+// do so for callees announcing the call_timeout feature.
+func limitCallTimeout(call *wamp.Call) {
+	limit, ok := callTimeouts[call.Procedure]
+	if !ok {
+		return
+	}
+	ms := limit.Milliseconds()
+	if timeout, _ := wamp.AsInt64(call.Options[wamp.OptTimeout]); timeout > 0 && timeout <= ms {
+		return
+	}
+	if call.Options == nil {
+		call.Options = wamp.Dict{}
+	}
+	call.Options[wamp.OptTimeout] = ms
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestParseCallTimeouts(t *testing.T) {
+	defer func() { callTimeouts = map[wamp.URI]time.Duration{} }()
+	if err := parseCallTimeouts("app.slow=2s, app.fast=50ms"); err != nil {
+		t.Fatal(err)
+	}
+	if callTimeouts["app.slow"] != 2*time.Second || callTimeouts["app.fast"] != 50*time.Millisecond {
+		t.Errorf("unexpected timeouts %v", callTimeouts)
+	}
+	for _, s := range []string{"app.slow", "app.slow=soon", "app.slow=0s", "bad uri!=1s"} {
+		if err := parseCallTimeouts(s); err == nil {
+			t.Errorf("expected an error for %q", s)
+		}
+	}
+}
+
+func TestCallTimeout(t *testing.T) {
+	defer func() { callTimeouts = map[wamp.URI]time.Duration{} }()
+	callTimeouts = map[wamp.URI]time.Duration{"test.slow": 100 * time.Millisecond}
+
+	url := startTestRouter(t)
+	callee := connectTestClient(t, url, realm)
+	interrupted := make(chan struct{})
+	err := callee.Register("test.slow", func(ctx context.Context, inv *wamp.Invocation) client.InvokeResult {
+		select {
+		case <-ctx.Done():
+			close(interrupted)
+			return client.InvokeResult{Err: wamp.ErrCanceled}
+		case <-time.After(5 * time.Second):
+			return client.InvokeResult{}
+		}
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, err = testCall(connectTestClient(t, url, realm), "test.slow", nil, nil)
+	if uri := errorURI(err); uri != wamp.ErrCanceled {
+		t.Fatalf("expected %s, got %v", wamp.ErrCanceled, err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("call was not limited, took %s", elapsed)
+	}
+	select {
+	case <-interrupted:
+	case <-time.After(5 * time.Second):
+		t.Error("callee was not interrupted")
+	}
+
+	// Shorter timeouts of the caller are kept.
+	call := &wamp.Call{Procedure: "test.slow", Options: wamp.Dict{wamp.OptTimeout: 20}}
+	limitCallTimeout(call)
+	if timeout, _ := wamp.AsInt64(call.Options[wamp.OptTimeout]); timeout != 20 {
+		t.Errorf("expected the caller's timeout to be kept, got %v", call.Options[wamp.OptTimeout])
+	}
+}
+
+func TestCalleeGone(t *testing.T) {
+	url := startTestRouter(t)
+	callee := joinRaw(t, url)
+	callee.send(64, 1, map[string]interface{}{}, "test.crash")
+	callee.read(65)
+
+	caller := connectTestClient(t, url, realm)
+	errs := make(chan error, 1)
+	go func() {
+		_, err := testCall(caller, "test.crash", nil, nil)
+		errs <- err
+	}()
+	callee.read(68)
+	// Drop the connection without a GOODBYE, as a crashing callee would.
+	start := time.Now()
+	callee.conn.Close()
+	select {
+	case err := <-errs:
+		if uri := errorURI(err); uri != wamp.ErrCanceled {
+			t.Errorf("expected %s, got %v", wamp.ErrCanceled, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("expected the call to fail promptly, took %s", elapsed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the call to fail when the callee left")
+	}
+}
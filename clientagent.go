@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+var (
+	// agentsAllowed, if set, holds the patterns of the only client agents
+	// allowed to join.
+	agentsAllowed []string
+	// agentsDenied holds the patterns of the client agents refused.
+	agentsDenied []string
+)
+
+// parseAgentPatterns parses a comma separated list of client agent patterns,
+// where * matches any run of characters.
+func parseAgentPatterns(s string) []string {
+	var patterns []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// matchAgent returns whether the agent matches the pattern as a whole, *
+// matching any run of characters.
+func matchAgent(pattern, agent string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return agent == pattern
+	}
+	if !strings.HasPrefix(agent, parts[0]) {
+		return false
+	}
+	agent = agent[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(agent, part)
+		if i < 0 {
+			return false
+		}
+		agent = agent[i+len(part):]
+	}
+	return strings.HasSuffix(agent, last)
+}
+
+// checkClientAgent keeps the agent of the HELLO details as client_agent, as
+// the agent of the WELCOME replaces it in the session details, and returns an
+// error for an agent that is denied or missing from -client-agent-allow.
+// Clients sending no agent are matched as an empty one.
+func checkClientAgent(uri wamp.URI, sid wamp.ID, details wamp.Dict) error {
+	agent, _ := wamp.AsString(details["agent"])
+	if agent != "" {
+		details["client_agent"] = agent
+	}
+	if logAgents {
+		logger.Printf("session %d joining %s with agent %q\n", sid, uri, agent)
+	}
+	for _, p := range agentsDenied {
+		if matchAgent(p, agent) {
+			return &abortError{abortAgent, fmt.Errorf("agent_not_allowed: client agent %q is denied", agent)}
+		}
+	}
+	if agentsAllowed == nil {
+		return nil
+	}
+	for _, p := range agentsAllowed {
+		if matchAgent(p, agent) {
+			return nil
+		}
+	}
+	return &abortError{abortAgent, fmt.Errorf("agent_not_allowed: client agent %q is not allowed", agent)}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestMatchAgent(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, agent string
+		want           bool
+	}{
+		{"myapp/1.2", "myapp/1.2", true},
+		{"myapp/1.2", "myapp/1.20", false},
+		{"myapp/*", "myapp/1.2", true},
+		{"myapp/*", "otherapp/1.2", false},
+		{"*", "", true},
+		{"*-js/*", "autobahn-js/22.1", true},
+		{"a*b*c", "abbc", true},
+		{"a*b*c", "acb", false},
+		{"a*a", "a", false},
+	} {
+		if got := matchAgent(tc.pattern, tc.agent); got != tc.want {
+			t.Errorf("%q against %q: expected %v, got %v", tc.agent, tc.pattern, tc.want, got)
+		}
+	}
+	if got := parseAgentPatterns(" myapp/*, ,x "); len(got) != 2 || got[0] != "myapp/*" || got[1] != "x" {
+		t.Errorf("unexpected patterns %q", got)
+	}
+}
+
+func TestClientAgents(t *testing.T) {
+	savedAllowed, savedDenied := agentsAllowed, agentsDenied
+	defer func() { agentsAllowed, agentsDenied = savedAllowed, savedDenied }()
+	agentsAllowed, agentsDenied = []string{"myapp/*"}, []string{"myapp/1.*"}
+
+	url := startTestRouter(t)
+	// join connects to the default realm with the agent, if any.
+	join := func(agent string) (*client.Client, error) {
+		details := wamp.Dict{}
+		if agent != "" {
+			details["agent"] = agent
+		}
+		return client.ConnectNet(context.Background(), url, client.Config{Realm: realm, HelloDetails: details, Logger: logger})
+	}
+	for _, agent := range []string{"otherapp/2.0", "myapp/1.9", ""} {
+		c, err := join(agent)
+		if err == nil {
+			c.Close()
+			t.Errorf("%q: expected the join to be refused", agent)
+		} else if !strings.Contains(err.Error(), "agent_not_allowed") {
+			t.Errorf("%q: expected agent_not_allowed, got %s", agent, err)
+		}
+	}
+
+	c, err := join("myapp/2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	res, err := testCall(c, string(wamp.MetaProcSessionGet), wamp.List{c.ID()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	details, _ := wamp.AsDict(res.Arguments[0])
+	if details["client_agent"] != "myapp/2.0" {
+		t.Errorf("expected the client agent in the session details, got %v", details)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// requiredFeature is a client role, and optionally a feature of it, that
+// clients must advertise in their HELLO, set with -required-client-features.
+type requiredFeature struct {
+	role, feature string
+}
+
+// requiredFeatures holds the roles and features clients must advertise.
+var requiredFeatures []requiredFeature
+
+// parseRequiredFeatures parses a comma separated list of role or
+// role.feature entries, such as caller,callee.call_canceling.
+func parseRequiredFeatures(s string) error {
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		role, feature, _ := strings.Cut(entry, ".")
+		switch role {
+		case wamp.RolePublisher, wamp.RoleSubscriber, wamp.RoleCaller, wamp.RoleCallee:
+		default:
+			return fmt.Errorf("invalid required client feature %q, expected publisher, subscriber, caller or callee role", entry)
+		}
+		if strings.Contains(feature, ".") {
+			return fmt.Errorf("invalid required client feature %q, expected role or role.feature", entry)
+		}
+		requiredFeatures = append(requiredFeatures, requiredFeature{role, feature})
+	}
+	return nil
+}
+
+// checkClientFeatures returns an error naming the first required role or
+// feature missing from the roles of the HELLO details.
+func checkClientFeatures(details wamp.Dict) error {
+	roles := wamp.DictChild(details, "roles")
+	for _, r := range requiredFeatures {
+		// AsDict accepts a missing role as an empty dict.
+		role, ok := wamp.AsDict(roles[r.role])
+		if !ok || role == nil {
+			return &abortError{abortMissingFeature, fmt.Errorf("missing_client_feature: client does not advertise the %s role", r.role)}
+		}
+		if r.feature == "" {
+			continue
+		}
+		if enabled, _ := wamp.DictChild(role, "features")[r.feature].(bool); !enabled {
+			return &abortError{abortMissingFeature, fmt.Errorf("missing_client_feature: client does not advertise the %s feature of the %s role", r.feature, r.role)}
+		}
+	}
+	return nil
+}
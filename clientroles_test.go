@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gammazero/nexus/v3/wamp"
+	"github.com/gorilla/websocket"
+)
+
+func TestParseRequiredFeatures(t *testing.T) {
+	saved := requiredFeatures
+	defer func() { requiredFeatures = saved }()
+	requiredFeatures = nil
+	if err := parseRequiredFeatures("caller, callee.call_canceling"); err != nil {
+		t.Fatal(err)
+	}
+	if len(requiredFeatures) != 2 || requiredFeatures[1] != (requiredFeature{"callee", "call_canceling"}) {
+		t.Errorf("unexpected features %v", requiredFeatures)
+	}
+	for _, s := range []string{"dealer", "caller.a.b"} {
+		if err := parseRequiredFeatures(s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
+
+func TestRequiredClientFeatures(t *testing.T) {
+	saved := requiredFeatures
+	defer func() { requiredFeatures = saved }()
+	requiredFeatures = []requiredFeature{{"caller", ""}, {"caller", "progressive_call_results"}}
+
+	url := startTestRouter(t)
+	// hello sends a HELLO with the roles on a new connection.
+	hello := func(roles map[string]interface{}) *rawSession {
+		dialer := websocket.Dialer{Subprotocols: []string{"wamp.2.json"}}
+		conn, _, err := dialer.Dial(url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		s := &rawSession{t: t, conn: conn}
+		s.send(1, realm, map[string]interface{}{"roles": roles})
+		return s
+	}
+
+	for _, roles := range []map[string]interface{}{
+		{"subscriber": map[string]interface{}{}},
+		{"caller": map[string]interface{}{}},
+	} {
+		abort := hello(roles).read(3)
+		message, _ := abort[1].(map[string]interface{})["message"].(string)
+		if abort[2] != string(wamp.ErrAuthenticationFailed) || !strings.HasPrefix(message, "missing_client_feature: ") {
+			t.Errorf("%v: expected the join to be aborted for a missing feature, got %v", roles, abort)
+		}
+	}
+
+	// A missing role is refused by its own requirement.
+	requiredFeatures = []requiredFeature{{"caller", ""}}
+	abort := hello(map[string]interface{}{"subscriber": map[string]interface{}{}}).read(3)
+	if message, _ := abort[1].(map[string]interface{})["message"].(string); message != "missing_client_feature: client does not advertise the caller role" {
+		t.Errorf("expected the join to be aborted for the missing caller role, got %v", abort)
+	}
+	requiredFeatures = []requiredFeature{{"caller", ""}, {"caller", "progressive_call_results"}}
+
+	hello(map[string]interface{}{"caller": map[string]interface{}{
+		"features": map[string]interface{}{"progressive_call_results": true},
+	}}).read(2)
+	// The nexus client advertises the feature.
+	connectTestClient(t, url, realm)
+}
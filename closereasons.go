@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/router"
+	"github.com/gammazero/nexus/v3/router/auth"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// Reasons of the GOODBYEs sent to sessions the router kills itself.
+const (
+	// closeReauthenticate tells sessions that exceeded -max-session-lifetime
+	// to join again.
+	closeReauthenticate = wamp.URI("nexus.close.reauthenticate")
+	// closeReplaced is sent to the previous session of an authid when a
+	// new one joins with -unique-authid kick-old.
+	closeReplaced = wamp.URI("nexus.close.replaced")
+)
+
+// Reasons of the ABORTs refusing joins with -abort-reasons, instead of
+// wamp.error.authentication_failed.
+const (
+	abortSessionLimit   = wamp.URI("nexus.error.session_limit")
+	abortAuthIDInUse    = wamp.URI("nexus.error.authid_in_use")
+	abortMissingFeature = wamp.URI("nexus.error.missing_client_feature")
+	abortSerializer     = wamp.URI("nexus.error.serializer_not_allowed")
+	abortDraining       = wamp.URI("nexus.error.realm_draining")
+	abortAgent          = wamp.URI("nexus.error.agent_not_allowed")
+)
+
+// abortError refuses a join for a cause with its own ABORT reason.
+type abortError struct {
+	reason wamp.URI
+	err    error
+}
+
+func (e *abortError) Error() string {
+	return e.err.Error()
+}
+
+func (e *abortError) Unwrap() error {
+	return e.err
+}
+
+// killSession kills the session of the realm with the reason and message of
+// its GOODBYE.  It returns false for a session that already left.
+func killSession(uri wamp.URI, sid wamp.ID, reason wamp.URI, message string) (bool, error) {
+	c, err := realmClient(uri)
+	if err != nil {
+		return false, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = c.Call(ctx, string(wamp.MetaProcSessionKill), nil, wamp.List{sid}, wamp.Dict{
+		"reason":  reason,
+		"message": message,
+	}, nil)
+	var rpcErr client.RPCError
+	if errors.As(err, &rpcErr) && rpcErr.Err.Error == wamp.ErrNoSuchSession {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// reasonAuth marks the peer of a session whose join failed with an
+// abortError, so its reasonPeer sends the ABORT with the error's reason.
+type reasonAuth struct {
+	auth.Authenticator
+}
+
+func (a reasonAuth) Authenticate(sid wamp.ID, details wamp.Dict, client wamp.Peer) (*wamp.Welcome, error) {
+	welcome, err := a.Authenticator.Authenticate(sid, details, client)
+	var abortErr *abortError
+	if p, ok := client.(*reasonPeer); ok && errors.As(err, &abortErr) {
+		p.reason.Store(abortErr.reason)
+	}
+	return welcome, err
+}
+
+// reasonRouter attaches WebSocket and RawSocket clients with a reasonPeer,
+// with -abort-reasons.  The authenticators get the peer given to nexus, so
+// it has to wrap the nexus router directly.
+type reasonRouter struct {
+	router.Router
+}
+
+func (r reasonRouter) Attach(client wamp.Peer) error {
+	return r.Router.Attach(&reasonPeer{Peer: client})
+}
+
+func (r reasonRouter) AttachClient(client wamp.Peer, transportDetails wamp.Dict) error {
+	return r.Router.AttachClient(&reasonPeer{Peer: client}, transportDetails)
+}
+
+// reasonPeer replaces the wamp.error.authentication_failed reason nexus sends
+// every refused join with the reason a reasonAuth stored.
+type reasonPeer struct {
+	wamp.Peer
+	reason atomic.Value
+}
+
+func (p *reasonPeer) Send(msg wamp.Message) error {
+	if abort, ok := msg.(*wamp.Abort); ok && abort.Reason == wamp.ErrAuthenticationFailed {
+		if reason, ok := p.reason.Load().(wamp.URI); ok {
+			msg = &wamp.Abort{Reason: reason, Details: abort.Details}
+		}
+	}
+	return p.Peer.Send(msg)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gammazero/nexus/v3/wamp"
+	"github.com/gorilla/websocket"
+)
+
+// abortReasonOf sends a HELLO with the roles on a new connection to url and
+// returns the reason of the ABORT refusing it.
+func abortReasonOf(t *testing.T, url string, roles map[string]interface{}) wamp.URI {
+	t.Helper()
+	dialer := websocket.Dialer{Subprotocols: []string{"wamp.2.json"}}
+	conn, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	s := &rawSession{t: t, conn: conn}
+	s.send(1, realm, map[string]interface{}{"roles": roles})
+	reason, _ := s.read(3)[2].(string)
+	return wamp.URI(reason)
+}
+
+func TestAbortReasons(t *testing.T) {
+	savedSessions, savedReasons, savedFeatures, savedDenied := maxSessions, abortReason, requiredFeatures, agentsDenied
+	defer func() {
+		maxSessions, abortReason, requiredFeatures, agentsDenied = savedSessions, savedReasons, savedFeatures, savedDenied
+	}()
+	maxSessions = 1
+	subscriber := map[string]interface{}{"subscriber": map[string]interface{}{}}
+
+	t.Run("default", func(t *testing.T) {
+		abortReason = false
+		url := startTestRouter(t)
+		connectTestClient(t, url, realm)
+		if reason := abortReasonOf(t, url, subscriber); reason != wamp.ErrAuthenticationFailed {
+			t.Errorf("expected %s, got %s", wamp.ErrAuthenticationFailed, reason)
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		abortReason = true
+		startTestRouter(t)
+		server := httptest.NewServer(newWebsocketServer(reasonRouter{wsRouter}))
+		defer server.Close()
+		url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+		requiredFeatures = []requiredFeature{{"caller", ""}}
+		if reason := abortReasonOf(t, url, subscriber); reason != abortMissingFeature {
+			t.Errorf("expected %s, got %s", abortMissingFeature, reason)
+		}
+		requiredFeatures = nil
+		agentsDenied = []string{"*"}
+		if reason := abortReasonOf(t, url, subscriber); reason != abortAgent {
+			t.Errorf("expected %s, got %s", abortAgent, reason)
+		}
+		agentsDenied = nil
+		connectTestClient(t, url, realm)
+		if reason := abortReasonOf(t, url, subscriber); reason != abortSessionLimit {
+			t.Errorf("expected %s, got %s", abortSessionLimit, reason)
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gammazero/nexus/v3/router"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// connCounts holds the connections attached to the router by transport and
+// serializer, with -count-connections.  The labels are fixed: RawSocket
+// connections are counted under rs alone, as nexus does not tell the router
+// which serializer they negotiated.
+var connCounts = map[string]*atomic.Int64{
+	"ws/json":    {},
+	"ws/msgpack": {},
+	"ws/cbor":    {},
+	"rs":         {},
+}
+
+// connStats returns the connection counts as published in the stats.
+func connStats() wamp.Dict {
+	stats := wamp.Dict{}
+	for label, count := range connCounts {
+		stats[label] = count.Load()
+	}
+	return stats
+}
+
+// connLabel returns the label of a WebSocket client from its transport
+// details, which hold the captured upgrade request.
+func connLabel(transportDetails wamp.Dict) string {
+	req, _ := wamp.DictChild(transportDetails, "auth")["request"].(*http.Request)
+	if req == nil {
+		return ""
+	}
+	subprotocol := negotiatedSubprotocol(req)
+	for _, p := range wsSubprotocols {
+		if p.subprotocol == subprotocol {
+			return "ws/" + p.name
+		}
+	}
+	return ""
+}
+
+// connRouter counts the clients it attaches to the router, from the moment
+// they are attached until their connection is closed.  It overrides Attach as
+// the RawSocket server calls it instead of AttachClient.
+type connRouter struct {
+	router.Router
+}
+
+func (r connRouter) Attach(client wamp.Peer) error {
+	peer := countPeer(client, connCounts["rs"])
+	return peer.attached(r.Router.Attach(peer))
+}
+
+func (r connRouter) AttachClient(client wamp.Peer, transportDetails wamp.Dict) error {
+	count, ok := connCounts[connLabel(transportDetails)]
+	if !ok {
+		return r.Router.AttachClient(client, transportDetails)
+	}
+	peer := countPeer(client, count)
+	return peer.attached(r.Router.AttachClient(peer, transportDetails))
+}
+
+// connPeer is a client counted by connRouter.  nexus closes it when its
+// session ends or its join is refused, which may be before AttachClient has
+// returned.
+type connPeer struct {
+	wamp.Peer
+	count *atomic.Int64
+	once  sync.Once
+}
+
+// countPeer counts the client and returns it wrapped to be uncounted once.
+func countPeer(client wamp.Peer, count *atomic.Int64) *connPeer {
+	count.Add(1)
+	return &connPeer{Peer: client, count: count}
+}
+
+// attached uncounts the client if attaching it failed, as nexus does not
+// close every client it fails to attach, and returns err.
+func (p *connPeer) attached(err error) error {
+	if err != nil {
+		p.uncount()
+	}
+	return err
+}
+
+func (p *connPeer) uncount() {
+	p.once.Do(func() { p.count.Add(-1) })
+}
+
+func (p *connPeer) Close() {
+	p.uncount()
+	p.Peer.Close()
+}
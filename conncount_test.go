@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/transport/serialize"
+)
+
+func TestConnectionCounts(t *testing.T) {
+	saved := countConns
+	defer func() { countConns = saved }()
+	countConns = true
+
+	startTestRouter(t)
+	server := httptest.NewServer(newWebsocketServer(connRouter{wsRouter}))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	rs, err := newRawSocketServer(connRouter{wsRouter}).ListenAndServe("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+	rsURL := "tcp://" + rs.(net.Listener).Addr().String()
+
+	base := map[string]int64{}
+	for label, count := range connCounts {
+		base[label] = count.Load()
+	}
+	// waitCount waits for the count of the label to be n above its base.
+	waitCount := func(label string, n int64) {
+		t.Helper()
+		if !waitFor(t, 5*time.Second, func() bool { return connCounts[label].Load() == base[label]+n }) {
+			t.Fatalf("expected %d %s connections, got %v", base[label]+n, label, connStats())
+		}
+	}
+
+	c, err := client.ConnectNet(context.Background(), wsURL, client.Config{Realm: realm, Serialization: serialize.MSGPACK, Logger: logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitCount("ws/msgpack", 1)
+	if got := connCounts["ws/json"].Load(); got != base["ws/json"] {
+		t.Errorf("expected the json count to stay at %d, got %d", base["ws/json"], got)
+	}
+	r, err := client.ConnectNet(context.Background(), rsURL, client.Config{Realm: realm, Logger: logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitCount("rs", 1)
+
+	// Refused joins are counted until nexus closes their connection.
+	if _, err := client.ConnectNet(context.Background(), wsURL, client.Config{Realm: "missing", Logger: logger}); err == nil {
+		t.Fatal("expected the join of a missing realm to fail")
+	}
+	waitCount("ws/json", 0)
+
+	c.Close()
+	r.Close()
+	waitCount("ws/msgpack", 0)
+	waitCount("rs", 0)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/subtle"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// dashAssets holds the pages of the -dashboard.
+//
+//go:embed dashboard
+var dashAssets embed.FS
+
+// dashUser and dashPassword are the basic auth credentials of the -dashboard,
+// from -dashboard-auth or -dashboard-auth-file.
+var dashUser, dashPassword string
+
+// dashboardCredentials returns the -dashboard-auth credentials, or the
+// contents of the -dashboard-auth-file without surrounding whitespace.
+func dashboardCredentials(auth, file string) (string, error) {
+	if file == "" {
+		return auth, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// checkDashboard validates -dashboard-path and -dashboard-auth and returns the
+// user and password of the basic auth.
+func checkDashboard(path, credentials string) (string, string, error) {
+	if path == "/" || !strings.HasPrefix(path, "/") || !strings.HasSuffix(path, "/") {
+		return "", "", fmt.Errorf("invalid dashboard path %q, expected a path such as /dashboard/", path)
+	}
+	user, password, ok := strings.Cut(credentials, ":")
+	if !ok || user == "" || password == "" {
+		return "", "", fmt.Errorf("invalid dashboard credentials, expected user:password")
+	}
+	return user, password, nil
+}
+
+// dashboardMux returns a handler serving the dashboard under -dashboard-path
+// and the WebSocket handler on every other path.  The path answers 404 while
+// the dashboard is disabled rather than reaching the WebSocket server.
+func dashboardMux(ws http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", ws)
+	if !dashEnable {
+		mux.HandleFunc(dashPath, func(w http.ResponseWriter, r *http.Request) {
+			writeIndex(w, http.StatusNotFound)
+		})
+		return mux
+	}
+	assets, _ := fs.Sub(dashAssets, "dashboard")
+	pages := http.NewServeMux()
+	pages.Handle("/", http.FileServer(http.FS(assets)))
+	pages.HandleFunc("/config.json", dashboardConfig)
+	mux.Handle(dashPath, basicAuth(http.StripPrefix(strings.TrimSuffix(dashPath, "/"), pages), dashUser, dashPassword))
+	return mux
+}
+
+// dashboardConfig serves what the dashboard needs to join the router over its
+// own WebSocket.
+func dashboardConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"realm": realm, "admin_prefix": adminPrefix})
+}
+
+// basicAuth returns a handler asking for the user and password before h.
+func basicAuth(h http.Handler, user, password string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := r.BasicAuth()
+		if !ok || subtle.ConstantTimeCompare([]byte(u), []byte(user)) != 1 || subtle.ConstantTimeCompare([]byte(p), []byte(password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="nexus dashboard"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
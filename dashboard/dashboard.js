@@ -0,0 +1,172 @@
+// The dashboard joins the router over its WebSocket with the JSON
+// serializer, anonymously or with a ticket, and calls the meta and admin
+// procedures.  The controls need a session with the -admin-role.
+"use strict";
+
+const HELLO = 1, WELCOME = 2, ABORT = 3, CHALLENGE = 4, AUTHENTICATE = 5,
+  GOODBYE = 6, ERROR = 8, SUBSCRIBE = 32, SUBSCRIBED = 33, EVENT = 36,
+  CALL = 48, RESULT = 50;
+const maxEvents = 50;
+
+let config, socket, nextRequest = 1, refreshTimer;
+const pending = {}, handlers = {};
+
+function $(id) {
+  return document.getElementById(id);
+}
+
+function setStatus(text, failed) {
+  $("status").textContent = text;
+  $("status").className = failed ? "error" : "";
+}
+
+function send(msg) {
+  socket.send(JSON.stringify(msg));
+}
+
+// request sends a CALL or SUBSCRIBE and resolves with its reply.
+function request(type, ...rest) {
+  const id = nextRequest++;
+  send([type, id, ...rest]);
+  return new Promise((resolve, reject) => { pending[id] = {resolve, reject}; });
+}
+
+function call(procedure, args, kwargs) {
+  return request(CALL, {}, procedure, args || [], kwargs || {});
+}
+
+async function subscribe(topic, handler) {
+  const reply = await request(SUBSCRIBE, {}, topic);
+  handlers[reply[2]] = handler;
+}
+
+function connect(authid, ticket) {
+  if (socket) {
+    socket.close();
+  }
+  const url = (location.protocol === "https:" ? "wss://" : "ws://") + location.host + "/";
+  socket = new WebSocket(url, "wamp.2.json");
+  socket.onopen = () => {
+    const details = {roles: {caller: {}, subscriber: {}}};
+    if (authid) {
+      details.authid = authid;
+      details.authmethods = ["ticket"];
+    }
+    send([HELLO, config.realm, details]);
+  };
+  socket.onmessage = (e) => handle(JSON.parse(e.data), ticket);
+  socket.onclose = () => {
+    clearInterval(refreshTimer);
+    setStatus("Disconnected", true);
+  };
+}
+
+function handle(msg, ticket) {
+  switch (msg[0]) {
+  case WELCOME:
+    setStatus("Connected as " + msg[2].authid + " (" + msg[2].authrole + ")");
+    start();
+    break;
+  case CHALLENGE:
+    send([AUTHENTICATE, ticket, {}]);
+    break;
+  case ABORT:
+  case GOODBYE:
+    setStatus("Closed: " + msg[2] + (msg[1].message ? ": " + msg[1].message : ""), true);
+    break;
+  case RESULT:
+  case SUBSCRIBED:
+    settle(msg[1], "resolve", msg);
+    break;
+  case ERROR:
+    settle(msg[2], "reject", new Error(msg[4] + (msg[5] && msg[5].length ? ": " + msg[5][0] : "")));
+    break;
+  case EVENT:
+    if (handlers[msg[1]]) {
+      handlers[msg[1]](msg[4] || []);
+    }
+    break;
+  }
+}
+
+function settle(id, how, value) {
+  const p = pending[id];
+  if (p) {
+    delete pending[id];
+    p[how](value);
+  }
+}
+
+function logEvent(text) {
+  const line = document.createElement("div");
+  line.textContent = new Date().toLocaleTimeString() + " " + text;
+  $("events").prepend(line);
+  while ($("events").childElementCount > maxEvents) {
+    $("events").lastChild.remove();
+  }
+}
+
+async function start() {
+  try {
+    await subscribe("wamp.session.on_join", (args) => {
+      const d = args[0] || {};
+      logEvent("joined: session " + d.session + " " + d.authid + " (" + d.authrole + ")");
+    });
+    await subscribe("wamp.session.on_leave", (args) => logEvent("left: session " + args[0]));
+  } catch (err) {
+    logEvent("cannot subscribe to session events: " + err.message);
+  }
+  refresh();
+  refreshTimer = setInterval(refresh, 2000);
+}
+
+async function refresh() {
+  try {
+    const count = await call("wamp.session.count");
+    $("count").textContent = count[3][0];
+    const list = await call("wamp.session.list");
+    const rows = await Promise.all(list[3][0].map((id) =>
+      call("wamp.session.get", [id]).then((r) => r[3][0], () => null)));
+    $("sessions").replaceChildren(...rows.filter((d) => d).map(sessionRow));
+  } catch (err) {
+    setStatus("Refresh failed: " + err.message, true);
+  }
+}
+
+function sessionRow(d) {
+  const row = document.createElement("tr");
+  for (const value of [d.session, d.authid, d.authrole, d.authmethod]) {
+    const cell = document.createElement("td");
+    cell.textContent = value;
+    row.append(cell);
+  }
+  const cell = document.createElement("td");
+  const kill = document.createElement("button");
+  kill.textContent = "Kill";
+  kill.onclick = () => control("wamp.session.kill", [d.session], "killed session " + d.session);
+  cell.append(kill);
+  row.append(cell);
+  return row;
+}
+
+async function control(procedure, args, done) {
+  try {
+    await call(procedure, args);
+    logEvent(done);
+    refresh();
+  } catch (err) {
+    logEvent(procedure + " failed: " + err.message);
+  }
+}
+
+$("pause").onclick = () => control(config.admin_prefix + ".accept.pause", [], "accepting new connections paused");
+$("resume").onclick = () => control(config.admin_prefix + ".accept.resume", [], "accepting new connections resumed");
+$("login").onsubmit = (e) => {
+  e.preventDefault();
+  connect($("authid").value, $("ticket").value);
+};
+
+fetch("config.json").then((r) => r.json()).then((c) => {
+  config = c;
+  connect("", "");
+});
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckDashboard(t *testing.T) {
+	if user, password, err := checkDashboard("/ops/dashboard/", "admin:s3:cret"); err != nil || user != "admin" || password != "s3:cret" {
+		t.Errorf("unexpected credentials %q %q: %v", user, password, err)
+	}
+	for _, tc := range [][2]string{{"/", "admin:secret"}, {"dashboard/", "admin:secret"}, {"/dashboard", "admin:secret"}, {"/dashboard/", "admin"}, {"/dashboard/", ":secret"}} {
+		if _, _, err := checkDashboard(tc[0], tc[1]); err == nil {
+			t.Errorf("%q %q: expected an error", tc[0], tc[1])
+		}
+	}
+}
+
+func TestDashboardCredentials(t *testing.T) {
+	if credentials, err := dashboardCredentials("admin:secret", ""); err != nil || credentials != "admin:secret" {
+		t.Errorf("expected the -dashboard-auth credentials, got %q: %v", credentials, err)
+	}
+	path := filepath.Join(t.TempDir(), "dashboard.auth")
+	if err := os.WriteFile(path, []byte("admin:s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if credentials, err := dashboardCredentials("", path); err != nil || credentials != "admin:s3cret" {
+		t.Errorf("expected the credentials of the file, got %q: %v", credentials, err)
+	}
+	if _, err := dashboardCredentials("", path+".missing"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	savedEnable, savedUser, savedPassword := dashEnable, dashUser, dashPassword
+	defer func() { dashEnable, dashUser, dashPassword = savedEnable, savedUser, savedPassword }()
+	dashUser, dashPassword = "admin", "secret"
+
+	// get requests the dashboard path of the server with the credentials.
+	get := func(url, path, user, password string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, url+path, nil)
+		if user != "" {
+			req.SetBasicAuth(user, password)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(body)
+	}
+
+	t.Run("enabled", func(t *testing.T) {
+		dashEnable = true
+		startTestRouter(t)
+		server := httptest.NewServer(dashboardMux(newWebsocketServer(wsRouter)))
+		defer server.Close()
+
+		if status, _ := get(server.URL, dashPath, "", ""); status != http.StatusUnauthorized {
+			t.Errorf("expected 401 without credentials, got %d", status)
+		}
+		if status, _ := get(server.URL, dashPath, "admin", "wrong"); status != http.StatusUnauthorized {
+			t.Errorf("expected 401 with a wrong password, got %d", status)
+		}
+		if status, body := get(server.URL, dashPath, "admin", "secret"); status != http.StatusOK || !strings.Contains(body, "<title>nexus dashboard</title>") {
+			t.Errorf("expected the dashboard index, got %d %q", status, body)
+		}
+		if status, _ := get(server.URL, dashPath+"dashboard.js", "admin", "secret"); status != http.StatusOK {
+			t.Errorf("expected the dashboard script, got %d", status)
+		}
+		_, body := get(server.URL, dashPath+"config.json", "admin", "secret")
+		var config map[string]string
+		if err := json.Unmarshal([]byte(body), &config); err != nil || config["realm"] != realm || config["admin_prefix"] != adminPrefix {
+			t.Errorf("unexpected config %q: %v", body, err)
+		}
+		// WebSocket clients still join on the other paths.
+		connectTestClient(t, "ws"+strings.TrimPrefix(server.URL, "http"), realm)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		dashEnable = false
+		startTestRouter(t)
+		server := httptest.NewServer(dashboardMux(newWebsocketServer(wsRouter)))
+		defer server.Close()
+
+		if status, _ := get(server.URL, dashPath, "admin", "secret"); status != http.StatusNotFound {
+			t.Errorf("expected 404, got %d", status)
+		}
+		connectTestClient(t, "ws"+strings.TrimPrefix(server.URL, "http"), realm)
+	})
+}
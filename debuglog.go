@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+var (
+	debugLogMu sync.Mutex
+	// debugLog is a ring buffer of the last messages received from remote
+	// sessions, with debugLogNext the index of the next entry to overwrite.
+	debugLog     []wamp.Dict
+	debugLogNext int
+)
+
+// logDebugMessage adds a summary of msg to the debug log, if it is enabled
+// with -debug-log-size.  Payloads are only kept with -debug-log-payloads.
+func logDebugMessage(sess *wamp.Session, msg wamp.Message) {
+	if debugLogLen <= 0 {
+		return
+	}
+	entry := wamp.Dict{
+		"time":    time.Now().Format(time.RFC3339Nano),
+		"session": sess.ID,
+		"type":    msg.MessageType().String(),
+	}
+	if uri, ok := messageURI(msg); ok {
+		entry["uri"] = uri
+	}
+	if debugArgs {
+		if args, kwargs, ok := messagePayload(msg); ok {
+			entry["args"], entry["kwargs"] = args, kwargs
+		}
+	}
+	debugLogMu.Lock()
+	defer debugLogMu.Unlock()
+	if len(debugLog) < debugLogLen {
+		debugLog = append(debugLog, entry)
+		return
+	}
+	debugLog[debugLogNext] = entry
+	debugLogNext = (debugLogNext + 1) % len(debugLog)
+}
+
+// messagePayload returns the arguments of messages carrying a payload.
+func messagePayload(msg wamp.Message) (wamp.List, wamp.Dict, bool) {
+	switch msg := msg.(type) {
+	case *wamp.Publish:
+		return msg.Arguments, msg.ArgumentsKw, true
+	case *wamp.Call:
+		return msg.Arguments, msg.ArgumentsKw, true
+	case *wamp.Yield:
+		return msg.Arguments, msg.ArgumentsKw, true
+	case *wamp.Error:
+		return msg.Arguments, msg.ArgumentsKw, true
+	}
+	return nil, nil, false
+}
+
+// adminDebugLog handles <admin-prefix>.debuglog.  It returns the debug log,
+// oldest message first.
+func adminDebugLog(ctx context.Context, inv *wamp.Invocation) client.InvokeResult {
+	debugLogMu.Lock()
+	defer debugLogMu.Unlock()
+	entries := make(wamp.List, 0, len(debugLog))
+	for i := range debugLog {
+		entries = append(entries, debugLog[(debugLogNext+i)%len(debugLog)])
+	}
+	return client.InvokeResult{Args: wamp.List{entries}}
+}
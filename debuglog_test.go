@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestDebugLog(t *testing.T) {
+	savedRole, savedLen, savedArgs := anonRole, debugLogLen, debugArgs
+	defer func() { anonRole, debugLogLen, debugArgs = savedRole, savedLen, savedArgs }()
+	anonRole, debugLogLen = adminRole, 3
+
+	for _, debugArgs = range []bool{false, true} {
+		url := startTestRouter(t)
+		if err := createLocalCallee(getLocalClient(), adminPrefix+".debuglog", adminDebugLog); err != nil {
+			t.Fatal(err)
+		}
+		c := connectTestClient(t, url, realm)
+		for _, procedure := range []string{"test.first", "test.second", "test.third"} {
+			testCall(c, procedure, wamp.List{"secret"}, nil)
+		}
+
+		res, err := testCall(c, adminPrefix+".debuglog", nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries, _ := wamp.AsList(res.Arguments[0])
+		if len(entries) != debugLogLen {
+			t.Fatalf("expected %d entries, got %v", debugLogLen, entries)
+		}
+		// The oldest call was dropped and the debuglog call itself is last.
+		for i, want := range []wamp.URI{"test.second", "test.third", wamp.URI(adminPrefix + ".debuglog")} {
+			entry, _ := wamp.AsDict(entries[i])
+			uri, _ := wamp.AsURI(entry["uri"])
+			sid, _ := wamp.AsID(entry["session"])
+			if entry["type"] != "CALL" || uri != want || sid != c.ID() {
+				t.Errorf("entry %d: expected a call to %s by %d, got %v", i, want, c.ID(), entry)
+			}
+		}
+		entry, _ := wamp.AsDict(entries[0])
+		if _, ok := entry["args"]; ok != debugArgs {
+			t.Errorf("payloads %t: unexpected entry %v", debugArgs, entry)
+		}
+	}
+}
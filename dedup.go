@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+var (
+	// dedupTopicSet holds the -dedup-topics whose duplicate publishes are
+	// dropped.
+	dedupTopicSet = map[wamp.URI]bool{}
+	// duplicatePublishes counts the publishes dropped as duplicates.
+	duplicatePublishes uint64
+)
+
+// parseDedupTopics parses the comma separated topics of -dedup-topics.
+func parseDedupTopics(s string) error {
+	for _, topic := range strings.Split(s, ",") {
+		topic = strings.TrimSpace(topic)
+		if topic == "" {
+			continue
+		}
+		if !wamp.URI(topic).ValidURI(false, "") {
+			return fmt.Errorf("invalid dedup topic %q", topic)
+		}
+		dedupTopicSet[wamp.URI(topic)] = true
+	}
+	return nil
+}
+
+// publishDedup remembers the payloads recently published to the dedup topics
+// of a realm.
+type publishDedup struct {
+	window time.Duration
+	mu     sync.Mutex
+	seen   map[wamp.URI]map[[sha256.Size]byte]time.Time
+	pruned time.Time
+}
+
+func newPublishDedup(window time.Duration) *publishDedup {
+	return &publishDedup{window: window, seen: map[wamp.URI]map[[sha256.Size]byte]time.Time{}}
+}
+
+// check is a publishCheck rejecting a publish to a dedup topic with the same
+// args and kwargs as another one within the window, from any publisher.
+func (d *publishDedup) check(topic wamp.URI, args wamp.List, kwargs wamp.Dict) error {
+	if !dedupTopicSet[topic] {
+		return nil
+	}
+	// JSON sorts dict keys, so equal payloads encode the same.
+	data, err := json.Marshal([]interface{}{args, kwargs})
+	if err != nil {
+		return fmt.Errorf("cannot encode payload: %s", err)
+	}
+	sum := sha256.Sum256(data)
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if now.Sub(d.pruned) >= d.window {
+		for t, sums := range d.seen {
+			for s, at := range sums {
+				if now.Sub(at) >= d.window {
+					delete(sums, s)
+				}
+			}
+			if len(sums) == 0 {
+				delete(d.seen, t)
+			}
+		}
+		d.pruned = now
+	}
+	sums := d.seen[topic]
+	if sums == nil {
+		sums = map[[sha256.Size]byte]time.Time{}
+		d.seen[topic] = sums
+	}
+	if at, ok := sums[sum]; ok && now.Sub(at) < d.window {
+		atomic.AddUint64(&duplicatePublishes, 1)
+		return fmt.Errorf("duplicate_publish: identical publish to %s within %s", topic, d.window)
+	}
+	sums[sum] = now
+	return nil
+}
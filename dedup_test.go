@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestDedupPublishes(t *testing.T) {
+	savedTopics, savedWindow, savedCount := dedupTopicSet, dedupWindow, atomic.LoadUint64(&duplicatePublishes)
+	defer func() {
+		dedupTopicSet, dedupWindow = savedTopics, savedWindow
+		atomic.StoreUint64(&duplicatePublishes, savedCount)
+	}()
+	dedupTopicSet, dedupWindow = map[wamp.URI]bool{}, time.Minute
+	atomic.StoreUint64(&duplicatePublishes, 0)
+	if err := parseDedupTopics("test.dedup"); err != nil {
+		t.Fatal(err)
+	}
+	if err := parseDedupTopics("bad..topic"); err == nil {
+		t.Error("expected an error for an invalid topic")
+	}
+
+	url := startTestRouter(t)
+	sub := connectTestClient(t, url, realm)
+	dedupEvents, plainEvents := make(chan *wamp.Event, 10), make(chan *wamp.Event, 10)
+	if err := sub.SubscribeChan("test.dedup", dedupEvents, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := sub.SubscribeChan("test.plain", plainEvents, nil); err != nil {
+		t.Fatal(err)
+	}
+	pub := connectTestClient(t, url, realm)
+	ack := wamp.Dict{wamp.OptAcknowledge: true}
+	for i := 0; i < 3; i++ {
+		err := pub.Publish("test.dedup", ack, wamp.List{"same"}, wamp.Dict{"a": 1, "b": 2})
+		if i == 0 && err != nil {
+			t.Fatal(err)
+		}
+		if i > 0 && (err == nil || !strings.Contains(err.Error(), "duplicate_publish: ")) {
+			t.Errorf("expected the duplicate to be rejected, got %v", err)
+		}
+		if err = pub.Publish("test.plain", ack, wamp.List{"same"}, nil); err != nil {
+			t.Errorf("expected publishes to other topics to pass, got %v", err)
+		}
+	}
+	// A different payload is not a duplicate.
+	if err := pub.Publish("test.dedup", ack, wamp.List{"other"}, nil); err != nil {
+		t.Error(err)
+	}
+
+	// receive returns the arguments of the n events of the channel, failing
+	// on more.
+	receive := func(events chan *wamp.Event, n int) []interface{} {
+		var args []interface{}
+		for len(args) < n {
+			select {
+			case event := <-events:
+				args = append(args, event.Arguments[0])
+			case <-time.After(5 * time.Second):
+				t.Fatalf("expected %d events, got %v", n, args)
+			}
+		}
+		select {
+		case event := <-events:
+			t.Errorf("unexpected event %v", event)
+		case <-time.After(50 * time.Millisecond):
+		}
+		return args
+	}
+	if args := receive(dedupEvents, 2); args[0] != "same" || args[1] != "other" {
+		t.Errorf("expected a single event of the duplicates, got %v", args)
+	}
+	receive(plainEvents, 3)
+	if n := atomic.LoadUint64(&duplicatePublishes); n != 2 {
+		t.Errorf("expected 2 duplicates counted, got %d", n)
+	}
+}
+
+func TestDedupWindow(t *testing.T) {
+	saved := dedupTopicSet
+	defer func() { dedupTopicSet = saved }()
+	dedupTopicSet = map[wamp.URI]bool{"test.dedup": true}
+
+	d := newPublishDedup(50 * time.Millisecond)
+	if err := d.check("test.dedup", wamp.List{1}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.check("test.dedup", wamp.List{1}, nil); err == nil {
+		t.Error("expected a duplicate within the window")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if err := d.check("test.dedup", wamp.List{1}, nil); err != nil {
+		t.Errorf("expected the publish to pass after the window, got %v", err)
+	}
+	if len(d.seen["test.dedup"]) != 1 {
+		t.Errorf("expected expired payloads to be pruned, got %v", d.seen)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// devEchoDelay is how long <dev-prefix>.echo waits before answering.
+var devEchoDelay = 2 * time.Second
+
+// devEchoCallee handles <dev-prefix>.echo, returning its arguments after
+// devEchoDelay.  It gives up when ctx ends first, which the client does at
+// the timeout of the call, the smaller of the caller's and -call-timeouts.
+func devEchoCallee(ctx context.Context, inv *wamp.Invocation) client.InvokeResult {
+	timer := time.NewTimer(devEchoDelay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		reason := "canceled"
+		if ctx.Err() == context.DeadlineExceeded {
+			reason = "call timeout"
+		}
+		return client.InvokeResult{Err: wamp.ErrCanceled, Args: wamp.List{reason}}
+	}
+	res := client.InvokeResult{
+		Args:   inv.Arguments,
+		Kwargs: inv.ArgumentsKw,
+	}
+	logger.Printf("%s.echo %v %v\n", devPrefix, res, inv.Details)
+	return res
+}
+
+// registerDevEcho registers <dev-prefix>.echo on the local client.
+func registerDevEcho(c *client.Client) error {
+	return registerDevCallee(c, devPrefix+".echo", devEchoCallee)
+}
+
+// registerDevCallee registers a development procedure on the local client.  A
+// failed registration, such as the URI being taken by another callee, only
+// returns an error with -fail-on-dev-error; otherwise it is logged and the
+// router keeps running without the procedure.
+func registerDevCallee(c *client.Client, procedure string, callback client.InvocationHandler) error {
+	err := createLocalCallee(c, procedure, callback)
+	if err != nil && !failOnDev {
+		logger.Printf("dev procedure unavailable: %s\n", err)
+		return nil
+	}
+	return err
+}
+
+// publishDevTime publishes the time on <dev-prefix>.time every interval until
+// quit is closed.
+func publishDevTime(interval time.Duration, quit <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	topic := devPrefix + ".time"
+	for {
+		select {
+		case <-ticker.C:
+			if !devTimeAll && !hasSubscribers(topic, interval) {
+				continue
+			}
+			now := formatTime(time.Now())
+			logger.Printf("%s: %v\n", topic, now)
+			localPublish(topic, wamp.List{now}, nil)
+		case <-quit:
+			return
+		}
+	}
+}
+
+// devTimeNow handles <dev-prefix>.time.now, publishing the time on
+// <dev-prefix>.time at once and returning it.  The publish is acknowledged,
+// so the call fails if the router refuses it.
+func devTimeNow(ctx context.Context, inv *wamp.Invocation) client.InvokeResult {
+	topic := devPrefix + ".time"
+	now := formatTime(time.Now())
+	logger.Printf("%s: %v\n", topic, now)
+	err := getLocalClient().Publish(topic, wamp.Dict{wamp.OptAcknowledge: true}, wamp.List{now}, wamp.Dict{})
+	if err != nil {
+		return client.InvokeResult{Err: errInternal, Args: wamp.List{err.Error()}}
+	}
+	return client.InvokeResult{Args: wamp.List{now}}
+}
+
+// publishDevWildcard publishes a counter on <dev-prefix>.wildcard.<group>.tick
+// every interval, cycling through the groups, until quit is closed.
+func publishDevWildcard(interval time.Duration, quit <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	groups := []string{"alpha", "beta", "gamma"}
+	for i := 0; ; i++ {
+		select {
+		case <-ticker.C:
+			topic := fmt.Sprintf("%s.wildcard.%s.tick", devPrefix, groups[i%len(groups)])
+			logger.Printf("%s: %d\n", topic, i)
+			localPublish(topic, wamp.List{i}, nil)
+		case <-quit:
+			return
+		}
+	}
+}
+
+// hasSubscribers reports whether any subscription of the default realm,
+// exact or pattern based, matches topic.  It reports true when the meta API
+// cannot be asked within timeout, so events are not lost to a slow router.
+func hasSubscribers(topic string, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	res, err := localCall(ctx, wamp.MetaProcSubMatch, wamp.List{topic}, nil)
+	if err != nil {
+		return true
+	}
+	ids, _ := wamp.AsList(res.Arguments[0])
+	return len(ids) != 0
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestDevWildcard(t *testing.T) {
+	url := startTestRouter(t)
+	c := connectTestClient(t, url, realm)
+	wildcard := make(chan *wamp.Event, 8)
+	if err := c.SubscribeChan("dev.wildcard..tick", wildcard, wamp.Dict{wamp.OptMatch: wamp.MatchWildcard}); err != nil {
+		t.Fatal(err)
+	}
+	prefix := make(chan *wamp.Event, 8)
+	if err := c.SubscribeChan("dev.wildcard", prefix, wamp.Dict{wamp.OptMatch: wamp.MatchPrefix}); err != nil {
+		t.Fatal(err)
+	}
+	runUntilEnd(t, func(quit <-chan struct{}) { publishDevWildcard(20*time.Millisecond, quit) })
+
+	topics := map[string]bool{}
+	timeout := time.After(5 * time.Second)
+	for len(topics) < 3 {
+		select {
+		case event := <-wildcard:
+			topic, _ := wamp.AsString(event.Details["topic"])
+			topics[topic] = true
+		case <-timeout:
+			t.Fatalf("expected events for all groups, got %v", topics)
+		}
+	}
+	for _, topic := range []string{"dev.wildcard.alpha.tick", "dev.wildcard.beta.tick", "dev.wildcard.gamma.tick"} {
+		if !topics[topic] {
+			t.Errorf("no wildcard event on %s", topic)
+		}
+	}
+	select {
+	case <-prefix:
+	case <-timeout:
+		t.Error("no prefix event")
+	}
+}
+
+func TestDevPrefix(t *testing.T) {
+	savedPrefix, savedDelay := devPrefix, devEchoDelay
+	defer func() { devPrefix, devEchoDelay = savedPrefix, savedDelay }()
+	devPrefix, devEchoDelay = "sandbox", 0
+
+	url := startTestRouter(t)
+	if err := createLocalCallee(getLocalClient(), devPrefix+".echo", devEchoCallee); err != nil {
+		t.Fatal(err)
+	}
+	c := connectTestClient(t, url, realm)
+	res, err := testCall(c, "sandbox.echo", wamp.List{"hi"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Arguments) != 1 || res.Arguments[0] != "hi" {
+		t.Errorf("unexpected echo %v", res.Arguments)
+	}
+	if _, err = testCall(c, "dev.echo", nil, nil); errorURI(err) != wamp.ErrNoSuchProcedure {
+		t.Errorf("expected no dev.echo, got %v", err)
+	}
+
+	events := make(chan *wamp.Event, 8)
+	if err = c.SubscribeChan("sandbox", events, wamp.Dict{wamp.OptMatch: wamp.MatchPrefix}); err != nil {
+		t.Fatal(err)
+	}
+	runUntilEnd(t, func(quit <-chan struct{}) { publishDevTime(20*time.Millisecond, quit) })
+	runUntilEnd(t, func(quit <-chan struct{}) { publishDevWildcard(20*time.Millisecond, quit) })
+	topics := map[string]bool{}
+	timeout := time.After(5 * time.Second)
+	for !topics["sandbox.time"] || !topics["sandbox.wildcard.alpha.tick"] {
+		select {
+		case event := <-events:
+			topic, _ := wamp.AsString(event.Details["topic"])
+			topics[topic] = true
+		case <-timeout:
+			t.Fatalf("expected time and wildcard events under the prefix, got %v", topics)
+		}
+	}
+}
+
+func TestDevEchoRegistrationFailure(t *testing.T) {
+	saved := failOnDev
+	defer func() { failOnDev = saved }()
+
+	url := startTestRouter(t)
+	c := connectTestClient(t, url, realm)
+	taken := func(context.Context, *wamp.Invocation) client.InvokeResult {
+		return client.InvokeResult{Args: wamp.List{"taken"}}
+	}
+	if err := c.Register(devPrefix+".echo", taken, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	failOnDev = true
+	if err := registerDevEcho(getLocalClient()); err == nil {
+		t.Error("expected an error with -fail-on-dev-error")
+	}
+	failOnDev = false
+	if err := registerDevEcho(getLocalClient()); err != nil {
+		t.Errorf("expected the failure to be logged only, got %s", err)
+	}
+	res, err := testCall(c, devPrefix+".echo", nil, nil)
+	if err != nil || res.Arguments[0] != "taken" {
+		t.Errorf("router stopped routing to the existing callee: %v %v", res, err)
+	}
+}
+
+func TestDevTimeSubscribers(t *testing.T) {
+	savedAlways := devTimeAll
+	defer func() { devTimeAll = savedAlways }()
+	url := startTestRouter(t)
+	published := &countingWriter{match: "dev.time:"}
+	logger = log.New(published, "", 0)
+	// run publishes dev.time for a while and returns the number of publishes.
+	run := func() int32 {
+		atomic.StoreInt32(&published.count, 0)
+		quit, done := make(chan struct{}), make(chan struct{})
+		go func() {
+			defer close(done)
+			publishDevTime(10*time.Millisecond, quit)
+		}()
+		time.Sleep(100 * time.Millisecond)
+		close(quit)
+		<-done
+		return atomic.LoadInt32(&published.count)
+	}
+
+	if n := run(); n != 0 {
+		t.Errorf("expected no publishes without subscribers, got %d", n)
+	}
+
+	c := connectTestClient(t, url, realm)
+	if err := c.Subscribe("dev", func(*wamp.Event) {}, wamp.Dict{wamp.OptMatch: wamp.MatchPrefix}); err != nil {
+		t.Fatal(err)
+	}
+	if run() == 0 {
+		t.Error("expected publishes with a pattern subscriber")
+	}
+	if err := c.Unsubscribe("dev"); err != nil {
+		t.Fatal(err)
+	}
+
+	devTimeAll = true
+	if run() == 0 {
+		t.Error("expected publishes without subscribers with -dev-time-always")
+	}
+}
+
+func TestDevEchoTimeout(t *testing.T) {
+	savedDelay := devEchoDelay
+	defer func() { devEchoDelay = savedDelay }()
+	devEchoDelay = 10 * time.Second
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	res := devEchoCallee(ctx, &wamp.Invocation{Arguments: wamp.List{"hi"}})
+	if res.Err != wamp.ErrCanceled || len(res.Args) != 1 || res.Args[0] != "call timeout" {
+		t.Errorf("expected a call timeout, got %+v", res)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("echo returned after %s instead of at the deadline", elapsed)
+	}
+
+	url := startTestRouter(t)
+	// The client does not wait for handlers of timed out calls, so the test
+	// waits for it before restoring devEchoDelay.
+	returned := make(chan client.InvokeResult, 1)
+	err := createLocalCallee(getLocalClient(), devPrefix+".echo", func(ctx context.Context, inv *wamp.Invocation) client.InvokeResult {
+		res := devEchoCallee(ctx, inv)
+		returned <- res
+		return res
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	call, cancelCall := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelCall()
+	_, err = connectTestClient(t, url, realm).Call(call, devPrefix+".echo", wamp.Dict{wamp.OptTimeout: 100}, nil, nil, nil)
+	if uri := errorURI(err); uri != wamp.ErrCanceled {
+		t.Errorf("expected %s for a call past its timeout, got %v", wamp.ErrCanceled, err)
+	}
+	select {
+	case res := <-returned:
+		if res.Err != wamp.ErrCanceled {
+			t.Errorf("expected the handler to give up, got %+v", res)
+		}
+	case <-time.After(time.Second):
+		t.Error("handler still running after the call timed out")
+	}
+}
+
+func TestDevTimeNow(t *testing.T) {
+	saved := dtimeFormat
+	defer func() { dtimeFormat = saved }()
+	dtimeFormat = timeUnix
+
+	url := startTestRouter(t)
+	if err := registerDevCallee(getLocalClient(), devPrefix+".time.now", devTimeNow); err != nil {
+		t.Fatal(err)
+	}
+	s := joinRaw(t, url)
+	s.send(32, 1, map[string]interface{}{}, devPrefix+".time")
+	s.read(33)
+	// The event and the result of the call may arrive in either order.
+	s.send(48, 2, map[string]interface{}{}, devPrefix+".time.now")
+	var event, result []interface{}
+	for event == nil || result == nil {
+		switch msg := s.next(); msg[0] {
+		case float64(36):
+			event = msg
+		case float64(50):
+			result = msg
+		default:
+			t.Fatalf("expected the event and the result, got %v", msg)
+		}
+	}
+	args, _ := event[4].([]interface{})
+	results, _ := result[3].([]interface{})
+	if len(args) != 1 || len(results) != 1 || args[0] != results[0] {
+		t.Fatalf("expected the published time to be returned, got %v and %v", event, result)
+	}
+	if now, ok := args[0].(float64); !ok || int64(now) > time.Now().Unix() {
+		t.Errorf("expected a unix time, got %v", args[0])
+	}
+}
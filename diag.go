@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// transportErrors maps the log messages of transport errors in nexus to the
+// transport and kind of error published on the diagnostics topic.  Only these
+// are published, and without the logged error text, which can include client
+// addresses and message contents.
+var transportErrors = []struct {
+	message, transport, kind string
+}{
+	{"Error upgrading to websocket connection:", "websocket", "handshake"},
+	{"Client cannot attach to router:", "websocket", "join"},
+	{"Error accepting rawsocket client:", "rawsocket", "handshake"},
+	{"Error attaching to router:", "rawsocket", "join"},
+	{"Aborting session", "", "protocol_violation"},
+	{"Cannot deserialize peer message:", "", "deserialize"},
+}
+
+var (
+	transportErrorsMu sync.Mutex
+	// transportErrorCounts holds the number of transport errors logged per
+	// kind.
+	transportErrorCounts = map[string]uint64{}
+)
+
+// transportErrorStats returns the transport error counts as published in the
+// stats.
+func transportErrorStats() wamp.Dict {
+	transportErrorsMu.Lock()
+	defer transportErrorsMu.Unlock()
+	stats := wamp.Dict{}
+	for kind, count := range transportErrorCounts {
+		stats[kind] = count
+	}
+	return stats
+}
+
+// diagWriter passes log output through to w, counting each transport error
+// logged and sending an event for it to events.  Events are dropped when
+// events is full or nil, so logging never blocks on publishing.  Handshake
+// errors, mostly from port scanners and probes, are only passed through at
+// debug level or with -log-handshake-errors.
+type diagWriter struct {
+	w      io.Writer
+	events chan<- wamp.Dict
+}
+
+func (d *diagWriter) Write(p []byte) (int, error) {
+	for _, e := range transportErrors {
+		if !bytes.Contains(p, []byte(e.message)) {
+			continue
+		}
+		transportErrorsMu.Lock()
+		transportErrorCounts[e.kind]++
+		transportErrorsMu.Unlock()
+		event := wamp.Dict{"kind": e.kind, "time": time.Now().Format(time.RFC3339)}
+		if e.transport != "" {
+			event["transport"] = e.transport
+		}
+		select {
+		case d.events <- event:
+		default:
+		}
+		if e.kind == "handshake" && !logHsErrors && logLevelID.Load() < levelDebug {
+			return len(p), nil
+		}
+		break
+	}
+	return d.w.Write(p)
+}
+
+// publishDiagnostics publishes the events on topic until quit is closed.
+func publishDiagnostics(topic string, events <-chan wamp.Dict, quit <-chan struct{}) {
+	for {
+		select {
+		case event := <-events:
+			localPublish(topic, wamp.List{event}, nil)
+		case <-quit:
+			return
+		}
+	}
+}
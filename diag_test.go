@@ -0,0 +1,156 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/router"
+	"github.com/gammazero/nexus/v3/wamp"
+	"github.com/gorilla/websocket"
+)
+
+func TestDiagnostics(t *testing.T) {
+	url := startTestRouter(t)
+	c := connectTestClient(t, url, realm)
+	received := make(chan *wamp.Event, 4)
+	if err := c.SubscribeChan("test.diag", received, nil); err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan wamp.Dict, 4)
+	runUntilEnd(t, func(quit <-chan struct{}) { publishDiagnostics("test.diag", events, quit) })
+
+	// A second router logging through the diagnostics writer serves the
+	// malformed handshakes.
+	r, err := router.NewRouter(&router.Config{RealmConfigs: realmConfigs(nil)}, log.New(&diagWriter{io.Discard, events}, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	ws := httptest.NewServer(newWebsocketServer(r))
+	defer ws.Close()
+	rs, err := newRawSocketServer(r).ListenAndServe("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+
+	// A plain HTTP request is not a WebSocket upgrade.
+	res, err := http.Get(ws.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	// The RawSocket handshake starts with the magic byte 0x7f.
+	conn, err := net.Dial("tcp", rs.(net.Listener).Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write([]byte("GET / HTTP/1.1\r\n\r\n"))
+	defer conn.Close()
+
+	got := map[string]bool{}
+	timeout := time.After(5 * time.Second)
+	for len(got) < 2 {
+		select {
+		case event := <-received:
+			diag, _ := wamp.AsDict(event.Arguments[0])
+			transport, _ := wamp.AsString(diag["transport"])
+			kind, _ := wamp.AsString(diag["kind"])
+			got[transport+" "+kind] = true
+			if len(diag) != 3 {
+				t.Errorf("unexpected fields in %v", diag)
+			}
+		case <-timeout:
+			t.Fatalf("expected handshake errors of both transports, got %v", got)
+		}
+	}
+	for _, want := range []string{"websocket handshake", "rawsocket handshake"} {
+		if !got[want] {
+			t.Errorf("no %s error published, got %v", want, got)
+		}
+	}
+}
+
+func TestDeserializeErrorCount(t *testing.T) {
+	r, err := router.NewRouter(&router.Config{RealmConfigs: realmConfigs(nil)}, log.New(&diagWriter{io.Discard, nil}, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	ws := httptest.NewServer(newWebsocketServer(r))
+	defer ws.Close()
+
+	before, _ := wamp.AsInt64(transportErrorStats()["deserialize"])
+	dialer := websocket.Dialer{Subprotocols: []string{"wamp.2.json"}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(ws.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err = conn.WriteMessage(websocket.TextMessage, []byte("[1, not json")); err != nil {
+		t.Fatal(err)
+	}
+	counted := waitFor(t, 5*time.Second, func() bool {
+		after, _ := wamp.AsInt64(transportErrorStats()["deserialize"])
+		return after == before+1
+	})
+	if !counted {
+		t.Fatalf("deserialization error not counted: %v", transportErrorStats())
+	}
+}
+
+func TestHandshakeErrorsQuiet(t *testing.T) {
+	saved := logHsErrors
+	defer func() { logHsErrors = saved }()
+
+	logHsErrors = false
+	if n := probeHandshake(t); n != 0 {
+		t.Errorf("expected the handshake error not to be logged, got %d lines", n)
+	}
+	logHsErrors = true
+	if n := probeHandshake(t); n != 1 {
+		t.Errorf("expected the handshake error to be logged with -log-handshake-errors, got %d lines", n)
+	}
+}
+
+// probeHandshake sends garbage instead of a RawSocket handshake to a new
+// router, waits for the handshake error to be counted, and logged with
+// -log-handshake-errors, and returns the number of times it was logged.
+func probeHandshake(t *testing.T) int32 {
+	t.Helper()
+	logged := &countingWriter{match: "Error accepting rawsocket client:"}
+	r, err := router.NewRouter(&router.Config{RealmConfigs: realmConfigs(nil)}, log.New(&diagWriter{logged, nil}, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	rs, err := newRawSocketServer(r).ListenAndServe("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+
+	before, _ := wamp.AsInt64(transportErrorStats()["handshake"])
+	conn, err := net.Dial("tcp", rs.(net.Listener).Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("\x16\x03\x01\x00\x05hello"))
+	done := waitFor(t, 5*time.Second, func() bool {
+		// The error is counted before it is logged, in the same write.
+		counted, _ := wamp.AsInt64(transportErrorStats()["handshake"])
+		return counted > before && (atomic.LoadInt32(&logged.count) != 0 || !logHsErrors)
+	})
+	if !done {
+		t.Fatalf("handshake error not counted or not logged: %v", transportErrorStats())
+	}
+	return atomic.LoadInt32(&logged.count)
+}
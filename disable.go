@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+var (
+	disabledMu sync.RWMutex
+	// disabledTopics and disabledProcs hold the topics and procedures disabled
+	// with the disable admin procedures, in every realm.
+	disabledTopics = map[wamp.URI]bool{}
+	disabledProcs  = map[wamp.URI]bool{}
+)
+
+// disabledState is the content of the -disabled-file.
+type disabledState struct {
+	Topics     []wamp.URI `json:"topics"`
+	Procedures []wamp.URI `json:"procedures"`
+}
+
+// checkDisabled rejects publishes to disabled topics and calls to disabled
+// procedures.
+func checkDisabled(msg wamp.Message) error {
+	disabledMu.RLock()
+	defer disabledMu.RUnlock()
+	switch msg := msg.(type) {
+	case *wamp.Publish:
+		if disabledTopics[msg.Topic] {
+			return fmt.Errorf("disabled: topic %s is disabled by an administrator", msg.Topic)
+		}
+	case *wamp.Call:
+		if disabledProcs[msg.Procedure] {
+			return fmt.Errorf("disabled: procedure %s is disabled by an administrator", msg.Procedure)
+		}
+	}
+	return nil
+}
+
+// loadDisabled reads the disabled topics and procedures from path.  A missing
+// file disables nothing.
+func loadDisabled(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var state disabledState
+	if err = json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("invalid disabled file %q: %s", path, err)
+	}
+	disabledMu.Lock()
+	defer disabledMu.Unlock()
+	for _, uri := range state.Topics {
+		disabledTopics[uri] = true
+	}
+	for _, uri := range state.Procedures {
+		disabledProcs[uri] = true
+	}
+	return nil
+}
+
+// saveDisabled writes the disabled topics and procedures to path, replacing
+// it atomically.  disabledMu must be held.
+func saveDisabled(path string) error {
+	data, err := json.MarshalIndent(disabledState{sortedURIs(disabledTopics), sortedURIs(disabledProcs)}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func sortedURIs(set map[wamp.URI]bool) []wamp.URI {
+	uris := make([]wamp.URI, 0, len(set))
+	for uri := range set {
+		uris = append(uris, uri)
+	}
+	sort.Slice(uris, func(i, j int) bool { return uris[i] < uris[j] })
+	return uris
+}
+
+// adminDisable returns the handler of an admin procedure disabling or
+// enabling the topic or procedure URI given as argument in set.  It returns
+// the URIs disabled afterwards.  Admin procedures cannot be disabled, so they
+// can always be enabled again.
+func adminDisable(set map[wamp.URI]bool, disable bool) func(context.Context, *wamp.Invocation) client.InvokeResult {
+	return func(ctx context.Context, inv *wamp.Invocation) client.InvokeResult {
+		var uri wamp.URI
+		if len(inv.Arguments) != 0 {
+			uri, _ = wamp.AsURI(inv.Arguments[0])
+		}
+		if !uri.ValidURI(false, "") {
+			return client.InvokeResult{Err: wamp.ErrInvalidArgument, Args: wamp.List{"argument must be a topic or procedure URI"}}
+		}
+		if disable && isAdminURI(uri) {
+			return client.InvokeResult{Err: wamp.ErrInvalidArgument, Args: wamp.List{"admin procedures cannot be disabled"}}
+		}
+		disabledMu.Lock()
+		defer disabledMu.Unlock()
+		if set[uri] != disable {
+			if disable {
+				set[uri] = true
+				logger.Printf("disabled %s\n", uri)
+			} else {
+				delete(set, uri)
+				logger.Printf("enabled %s\n", uri)
+			}
+			if disableFile != "" {
+				if err := saveDisabled(disableFile); err != nil {
+					logger.Printf("failed to save disabled topics and procedures: %s\n", err)
+					return client.InvokeResult{Err: errInternal, Args: wamp.List{"changed but not saved: " + err.Error()}}
+				}
+			}
+		}
+		return client.InvokeResult{Args: wamp.List{sortedURIs(set)}}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestDisableProcedure(t *testing.T) {
+	savedRole, savedFile := anonRole, disableFile
+	defer func() {
+		anonRole, disableFile = savedRole, savedFile
+		disabledTopics, disabledProcs = map[wamp.URI]bool{}, map[wamp.URI]bool{}
+	}()
+	anonRole = adminRole
+	disableFile = filepath.Join(t.TempDir(), "disabled.json")
+
+	url := startTestRouter(t)
+	lc := getLocalClient()
+	for name, handler := range map[string]client.InvocationHandler{
+		".proc.disable":  adminDisable(disabledProcs, true),
+		".proc.enable":   adminDisable(disabledProcs, false),
+		".topic.disable": adminDisable(disabledTopics, true),
+	} {
+		if err := createLocalCallee(lc, adminPrefix+name, handler); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := createLocalCallee(lc, "test.proc", func(context.Context, *wamp.Invocation) client.InvokeResult {
+		return client.InvokeResult{}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := connectTestClient(t, url, realm)
+
+	if _, err = testCall(c, "test.proc", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	res, err := testCall(c, adminPrefix+".proc.disable", wamp.List{"test.proc"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if disabled, _ := wamp.AsList(res.Arguments[0]); len(disabled) != 1 {
+		t.Errorf("expected the disabled procedure to be returned, got %v", res.Arguments)
+	}
+	_, err = testCall(c, "test.proc", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "disabled: procedure test.proc") {
+		t.Errorf("expected the call to fail while disabled, got %v", err)
+	}
+	data, err := os.ReadFile(disableFile)
+	if err != nil || !strings.Contains(string(data), "test.proc") {
+		t.Errorf("expected the disabled procedure to be saved, got %q: %v", data, err)
+	}
+
+	if _, err = testCall(c, adminPrefix+".proc.enable", wamp.List{"test.proc"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = testCall(c, "test.proc", nil, nil); err != nil {
+		t.Errorf("expected the call to succeed after enabling, got %v", err)
+	}
+	if _, err = testCall(c, adminPrefix+".proc.disable", wamp.List{adminPrefix + ".proc.enable"}, nil); err == nil {
+		t.Error("expected admin procedures not to be disabled")
+	}
+
+	if _, err = testCall(c, adminPrefix+".topic.disable", wamp.List{"test.topic"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	err = c.Publish("test.topic", wamp.Dict{wamp.OptAcknowledge: true}, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "disabled: topic test.topic") {
+		t.Errorf("expected the publish to fail while disabled, got %v", err)
+	}
+}
+
+func TestLoadDisabled(t *testing.T) {
+	defer func() { disabledTopics, disabledProcs = map[wamp.URI]bool{}, map[wamp.URI]bool{} }()
+	path := filepath.Join(t.TempDir(), "disabled.json")
+	if err := loadDisabled(path); err != nil {
+		t.Errorf("expected a missing file to disable nothing, got %s", err)
+	}
+	if err := os.WriteFile(path, []byte(`{"topics": ["app.noisy"], "procedures": ["app.buggy"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadDisabled(path); err != nil {
+		t.Fatal(err)
+	}
+	if !disabledTopics["app.noisy"] || !disabledProcs["app.buggy"] {
+		t.Errorf("unexpected disabled topics %v and procedures %v", disabledTopics, disabledProcs)
+	}
+	if err := checkDisabled(&wamp.Call{Procedure: "app.buggy"}); err == nil {
+		t.Error("expected a call to a loaded procedure to be rejected")
+	}
+	if err := os.WriteFile(path, []byte("not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadDisabled(path); err == nil {
+		t.Error("expected an error for an invalid file")
+	}
+}
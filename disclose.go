@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// Disclosure modes of a realm, set with -realm-disclosure.
+const (
+	// discloseForbid rejects disclose_me and disclose_caller requests.
+	discloseForbid = "forbid"
+	// discloseAllow discloses callers and publishers that ask for it.  This is
+	// the default.
+	discloseAllow = "allow"
+	// discloseForce discloses every caller and publisher.
+	discloseForce = "force"
+)
+
+// discloseModes maps realms to their disclosure mode.  Realms missing from it
+// allow disclosure.
+var discloseModes = map[wamp.URI]string{}
+
+// parseDisclosure merges a comma separated list of realm=mode pairs into
+// discloseModes.
+func parseDisclosure(s string) error {
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		uri, mode, ok := strings.Cut(pair, "=")
+		if !ok || !wamp.URI(uri).ValidURI(false, "") {
+			return fmt.Errorf("invalid realm disclosure %q, expected realm=mode", pair)
+		}
+		switch mode {
+		case discloseForbid, discloseAllow, discloseForce:
+		default:
+			return fmt.Errorf("invalid disclosure mode in %q, expected forbid, allow or force", pair)
+		}
+		discloseModes[wamp.URI(uri)] = mode
+	}
+	return nil
+}
+
+// discloseMode returns the disclosure mode of the realm.
+func discloseMode(uri wamp.URI) string {
+	if mode, ok := discloseModes[uri]; ok {
+		return mode
+	}
+	return discloseAllow
+}
+
+// forceDisclose sets disclose_me on calls and publishes, so the router
+// discloses the caller or publisher as if it had asked for it.
+func forceDisclose(msg wamp.Message) {
+	var options *wamp.Dict
+	switch msg := msg.(type) {
+	case *wamp.Call:
+		options = &msg.Options
+	case *wamp.Publish:
+		options = &msg.Options
+	default:
+		return
+	}
+	if *options == nil {
+		*options = wamp.Dict{}
+	}
+	(*options)[wamp.OptDiscloseMe] = true
+}
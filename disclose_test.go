@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestDisclosureCannotBeForged(t *testing.T) {
+	url := startTestRouter(t)
+	callee := connectTestClient(t, url, realm)
+	caller := connectTestClient(t, url, realm)
+	invocations := make(chan wamp.Dict, 2)
+	if err := callee.Register("test.who", func(ctx context.Context, inv *wamp.Invocation) client.InvokeResult {
+		invocations <- inv.Details
+		return client.InvokeResult{}
+	}, nil); err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan *wamp.Event, 2)
+	if err := callee.SubscribeChan("test.who", events, nil); err != nil {
+		t.Fatal(err)
+	}
+	// nextInvocation and nextEvent return the details of the next invocation
+	// and the next event, failing the test if none arrives.
+	nextInvocation := func() wamp.Dict {
+		t.Helper()
+		select {
+		case details := <-invocations:
+			return details
+		case <-time.After(5 * time.Second):
+			t.Fatal("no invocation")
+			return nil
+		}
+	}
+	nextEvent := func() *wamp.Event {
+		t.Helper()
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event")
+			return nil
+		}
+	}
+	forged := wamp.Dict{"caller": 1, "caller_authid": "root", "publisher": 1, "publisher_authid": "root", wamp.OptAcknowledge: true}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := caller.Call(ctx, "test.who", forged, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if details := nextInvocation(); details["caller"] != nil || details["caller_authid"] != nil {
+		t.Errorf("forged caller options reached the callee: %v", details)
+	}
+	if err := caller.Publish("test.who", forged, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if event := nextEvent(); event.Details["publisher"] != nil || event.Details["publisher_authid"] != nil {
+		t.Errorf("forged publisher options reached the subscriber: %v", event.Details)
+	}
+
+	disclose := wamp.Dict{wamp.OptDiscloseMe: true, "caller_authid": "root", "publisher_authid": "root", wamp.OptAcknowledge: true}
+	if _, err := caller.Call(ctx, "test.who", disclose, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	details := nextInvocation()
+	if id, _ := wamp.AsID(details["caller"]); id != caller.ID() || details["caller_authid"] == "root" {
+		t.Errorf("expected the caller disclosed by the router, got %v", details)
+	}
+	if err := caller.Publish("test.who", disclose, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	event := nextEvent()
+	if id, _ := wamp.AsID(event.Details["publisher"]); id != caller.ID() || event.Details["publisher_authid"] == "root" {
+		t.Errorf("expected the publisher disclosed by the router, got %v", event.Details)
+	}
+}
+
+func TestParseDisclosure(t *testing.T) {
+	defer func() { discloseModes = map[wamp.URI]string{} }()
+	if err := parseDisclosure("a=forbid, b=force"); err != nil {
+		t.Fatal(err)
+	}
+	if discloseMode("a") != discloseForbid || discloseMode("b") != discloseForce || discloseMode("c") != discloseAllow {
+		t.Errorf("unexpected modes %v", discloseModes)
+	}
+	for _, s := range []string{"a", "a=maybe", "not a realm=allow"} {
+		if err := parseDisclosure(s); err == nil {
+			t.Errorf("expected an error for %q", s)
+		}
+	}
+}
+
+func TestRealmDisclosure(t *testing.T) {
+	defer func() { discloseModes = map[wamp.URI]string{} }()
+	discloseModes = map[wamp.URI]string{"closed": discloseForbid, "open": discloseForce}
+	url := startTestRouter(t, "closed", "open")
+
+	// discloseCaller calls a new procedure in the realm, with disclose_me if
+	// asked, and returns the caller disclosed to the callee.
+	procedures := 0
+	discloseCaller := func(uri string, discloseMe bool) (interface{}, error) {
+		procedures++
+		procedure := fmt.Sprintf("test.who%d", procedures)
+		callee := connectTestClient(t, url, uri)
+		caller := connectTestClient(t, url, uri)
+		callers := make(chan interface{}, 1)
+		if err := callee.Register(procedure, func(ctx context.Context, inv *wamp.Invocation) client.InvokeResult {
+			callers <- inv.Details["caller"]
+			return client.InvokeResult{}
+		}, nil); err != nil {
+			t.Fatal(err)
+		}
+		options := wamp.Dict{}
+		if discloseMe {
+			options[wamp.OptDiscloseMe] = true
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, err := caller.Call(ctx, procedure, options, nil, nil, nil); err != nil {
+			return nil, err
+		}
+		select {
+		case caller := <-callers:
+			return caller, nil
+		case <-time.After(5 * time.Second):
+			t.Fatal("no invocation")
+			return nil, nil
+		}
+	}
+
+	if _, err := discloseCaller("closed", true); errorURI(err) != wamp.ErrOptionDisallowedDiscloseMe {
+		t.Errorf("expected %s in a forbidding realm, got %v", wamp.ErrOptionDisallowedDiscloseMe, err)
+	}
+	if caller, err := discloseCaller(realm, true); err != nil || caller == nil {
+		t.Errorf("expected the caller disclosed on request, got %v %v", caller, err)
+	}
+	if caller, err := discloseCaller(realm, false); err != nil || caller != nil {
+		t.Errorf("expected no disclosure without request, got %v %v", caller, err)
+	}
+	if caller, err := discloseCaller("open", false); err != nil || caller == nil {
+		t.Errorf("expected the caller disclosed in a forcing realm, got %v %v", caller, err)
+	}
+
+	subscriber := connectTestClient(t, url, "open")
+	events := make(chan *wamp.Event, 1)
+	if err := subscriber.SubscribeChan("test.who", events, nil); err != nil {
+		t.Fatal(err)
+	}
+	publisher := connectTestClient(t, url, "open")
+	if err := publisher.Publish("test.who", wamp.Dict{wamp.OptAcknowledge: true}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-events:
+		if id, _ := wamp.AsID(event.Details["publisher"]); id != publisher.ID() {
+			t.Errorf("expected the publisher disclosed in a forcing realm, got %v", event.Details)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event")
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// drainTopic is the topic realms are told on that they are about to be
+// closed or cleared.
+const drainTopic = "nexus.realm.draining"
+
+var errBadDrain = errors.New("drain must be a non-negative number of seconds")
+
+var (
+	drainMu sync.Mutex
+	// drainingRealms holds the realms refusing joins until they are closed or
+	// cleared.
+	drainingRealms = map[wamp.URI]bool{}
+)
+
+// checkDraining refuses sessions joining a realm that is draining.
+func checkDraining(uri wamp.URI) error {
+	drainMu.Lock()
+	defer drainMu.Unlock()
+	if drainingRealms[uri] {
+		return &abortError{abortDraining, fmt.Errorf("realm %s is draining and about to be disconnected", uri)}
+	}
+	return nil
+}
+
+// drainRealm handles the drain kwarg of realm.close and realm.clear, in
+// seconds.  With a drain, it refuses new joins of the realm, publishes the
+// reason and message the sessions are about to be disconnected with on
+// drainTopic in it, and waits for the drain to pass.  The returned function
+// lets sessions join again and must be called once they are disconnected.
+// The drain ends early with the error of ctx when the call is canceled.
+func drainRealm(ctx context.Context, uri wamp.URI, kwargs wamp.Dict, reason wamp.URI, message string) (func(), error) {
+	var drain time.Duration
+	if v, ok := kwargs["drain"]; ok {
+		seconds, ok := wamp.AsFloat64(v)
+		if !ok || seconds < 0 {
+			return nil, errBadDrain
+		}
+		drain = time.Duration(seconds * float64(time.Second))
+	}
+	if drain == 0 {
+		return func() {}, nil
+	}
+	c, err := realmClient(uri)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %s", errNoRealm, uri, err)
+	}
+	drainMu.Lock()
+	drainingRealms[uri] = true
+	drainMu.Unlock()
+	stop := func() {
+		drainMu.Lock()
+		delete(drainingRealms, uri)
+		drainMu.Unlock()
+	}
+	err = c.Publish(drainTopic, nil, nil, wamp.Dict{
+		"realm":   uri,
+		"drain":   drain.Seconds(),
+		"reason":  reason,
+		"message": message,
+	})
+	if err != nil {
+		stop()
+		return nil, fmt.Errorf("failed to announce the drain of %q: %s", uri, err)
+	}
+	logger.Printf("draining realm %s for %s\n", uri, drain)
+	timer := time.NewTimer(drain)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return stop, nil
+	case <-ctx.Done():
+		stop()
+		logger.Printf("drain of realm %s canceled\n", uri)
+		return nil, ctx.Err()
+	}
+}
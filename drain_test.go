@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestDrainRealm(t *testing.T) {
+	saved := anonRole
+	defer func() { anonRole = saved }()
+	anonRole = adminRole
+
+	url := startTestRouter(t, "tenant", "closed")
+	for proc, handler := range map[string]client.InvocationHandler{
+		adminPrefix + ".realm.clear": adminRealmClear,
+		adminPrefix + ".realm.close": adminRealmClose,
+	} {
+		if err := createLocalCallee(getLocalClient(), proc, handler); err != nil {
+			t.Fatal(err)
+		}
+	}
+	admin := connectTestClient(t, url, realm)
+	target := connectTestClient(t, url, "tenant")
+	events := make(chan *wamp.Event, 1)
+	if err := target.SubscribeChan(drainTopic, events, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	const drain = 300 * time.Millisecond
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		_, err := testCall(admin, adminPrefix+".realm.clear", wamp.List{"tenant"}, wamp.Dict{"drain": drain.Seconds(), "message": "upgrade"})
+		done <- err
+	}()
+	select {
+	case event := <-events:
+		if event.ArgumentsKw["realm"] != "tenant" || event.ArgumentsKw["message"] != "upgrade" || event.ArgumentsKw["reason"] != string(wamp.CloseNormal) {
+			t.Errorf("unexpected drain event %v", event.ArgumentsKw)
+		}
+		if seconds, _ := wamp.AsFloat64(event.ArgumentsKw["drain"]); seconds != drain.Seconds() {
+			t.Errorf("expected a drain of %s, got %v", drain, event.ArgumentsKw["drain"])
+		}
+	case <-time.After(time.Second):
+		t.Fatal("drain not announced")
+	}
+	if _, err := client.ConnectNet(context.Background(), url, client.Config{Realm: "tenant", Logger: logger}); err == nil || !strings.Contains(err.Error(), "draining") {
+		t.Errorf("expected joins of the draining realm to fail, got %v", err)
+	}
+	select {
+	case <-target.Done():
+		t.Fatal("session disconnected before the drain passed")
+	case <-time.After(drain / 2):
+	}
+	select {
+	case <-target.Done():
+		if elapsed := time.Since(start); elapsed < drain {
+			t.Errorf("session disconnected after %s, before the drain of %s", elapsed, drain)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("session not disconnected after the drain")
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	connectTestClient(t, url, "tenant")
+
+	// Closing drains as well, then removes the realm.
+	closed := connectTestClient(t, url, "closed")
+	start = time.Now()
+	if _, err := testCall(admin, adminPrefix+".realm.close", wamp.List{"closed"}, wamp.Dict{"drain": 0.1}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("realm closed after %s, before the drain", elapsed)
+	}
+	select {
+	case <-closed.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("session of the closed realm was not disconnected")
+	}
+
+	_, err := testCall(admin, adminPrefix+".realm.clear", wamp.List{"tenant"}, wamp.Dict{"drain": -1})
+	if uri := errorURI(err); uri != wamp.ErrInvalidArgument {
+		t.Errorf("expected %s for a negative drain, got %v", wamp.ErrInvalidArgument, err)
+	}
+}
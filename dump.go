@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"runtime/pprof"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// writeDump writes a diagnostic snapshot to path: the flags, limits and
+// transports, the sessions of each realm with their details, and the stacks
+// of all goroutines.  Sessions are looked up with a short timeout each, so a
+// wedged realm shows up as an error instead of blocking the dump.
+func writeDump(path string, realms []wamp.URI) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+
+	fmt.Fprintf(w, "# nexus-simple-router dump at %s\n\n# flags\n", time.Now().Format(time.RFC3339))
+	flag.VisitAll(func(f *flag.Flag) {
+		fmt.Fprintf(w, "-%s=%s\n", f.Name, dumpedValue(f))
+	})
+	limits, _ := json.Marshal(limits())
+	fmt.Fprintf(w, "\n# limits\n%s\n\n# transports\n", limits)
+	for _, t := range transports {
+		fmt.Fprintf(w, "%v\n", t)
+	}
+
+	fmt.Fprintf(w, "\n# sessions\n")
+	for _, uri := range realms {
+		dumpSessions(w, uri)
+	}
+
+	fmt.Fprintf(w, "\n# goroutines\n")
+	pprof.Lookup("goroutine").WriteTo(w, 2)
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// dumpedValue returns the value of the flag as written to the dump, with the
+// -dashboard-auth credentials and the password of a -http-proxy URL redacted.
+func dumpedValue(f *flag.Flag) string {
+	value := f.Value.String()
+	switch {
+	case value == "":
+	case f.Name == "dashboard-auth":
+		return "[redacted]"
+	case f.Name == "http-proxy":
+		if u, err := url.Parse(value); err == nil {
+			return u.Redacted()
+		}
+	}
+	return value
+}
+
+// dumpSessions writes a line with the details of each session of the realm.
+func dumpSessions(w *bufio.Writer, uri wamp.URI) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ids, err := realmSessionIDs(ctx, uri)
+	if err != nil {
+		fmt.Fprintf(w, "%s: failed to list sessions: %s\n", uri, err)
+		return
+	}
+	c, _ := realmClient(uri)
+	for id := range ids {
+		res, err := c.Call(ctx, string(wamp.MetaProcSessionGet), nil, wamp.List{id}, nil, nil)
+		if err != nil {
+			fmt.Fprintf(w, "%s: session %d: %s\n", uri, id, err)
+			continue
+		}
+		details, _ := json.Marshal(res.Arguments[0])
+		fmt.Fprintf(w, "%s: session %d %s\n", uri, id, details)
+	}
+}
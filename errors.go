@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// errHTTP is returned by proxy procedures for HTTP statuses that have no
+// mapped WAMP error.
+const errHTTP = wamp.URI("nexus.error.http")
+
+// errInternal is returned by router procedures that fail for reasons other
+// than invalid arguments.
+const errInternal = wamp.URI("nexus.error.internal")
+
+// statusErrors maps HTTP status codes returned by proxied endpoints to the
+// WAMP error URI the call fails with.  Each status has exactly one canonical
+// URI; -error-map replaces or adds entries.
+var statusErrors = map[int]wamp.URI{
+	http.StatusBadRequest:         wamp.ErrInvalidArgument,
+	http.StatusUnauthorized:       wamp.ErrAuthorizationFailed,
+	http.StatusForbidden:          wamp.ErrNotAuthorized,
+	http.StatusNotFound:           wamp.ErrNoSuchProcedure,
+	http.StatusServiceUnavailable: wamp.ErrNoEligibleCallee,
+	http.StatusGatewayTimeout:     wamp.ErrCanceled,
+}
+
+// parseErrorMap merges a comma separated list of uri=status pairs into
+// statusErrors.
+func parseErrorMap(s string) error {
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		uri, code, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("invalid error mapping %q, expected uri=status", pair)
+		}
+		if !wamp.URI(uri).ValidURI(false, "") {
+			return fmt.Errorf("invalid error URI in error mapping %q", pair)
+		}
+		status, err := strconv.Atoi(code)
+		if err != nil || status < 100 || status > 599 {
+			return fmt.Errorf("invalid HTTP status in error mapping %q", pair)
+		}
+		statusErrors[status] = wamp.URI(uri)
+	}
+	return nil
+}
+
+// statusError returns the WAMP error URI mapped to an HTTP status code.
+func statusError(status int) wamp.URI {
+	if uri, ok := statusErrors[status]; ok {
+		return uri
+	}
+	return errHTTP
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestStatusError(t *testing.T) {
+	for status, uri := range map[int]wamp.URI{
+		http.StatusBadRequest:          wamp.ErrInvalidArgument,
+		http.StatusUnauthorized:        wamp.ErrAuthorizationFailed,
+		http.StatusForbidden:           wamp.ErrNotAuthorized,
+		http.StatusNotFound:            wamp.ErrNoSuchProcedure,
+		http.StatusServiceUnavailable:  wamp.ErrNoEligibleCallee,
+		http.StatusGatewayTimeout:      wamp.ErrCanceled,
+		http.StatusInternalServerError: errHTTP,
+		http.StatusTeapot:              errHTTP,
+	} {
+		if got := statusError(status); got != uri {
+			t.Errorf("status %d: expected %s, got %s", status, uri, got)
+		}
+	}
+}
+
+func TestParseErrorMap(t *testing.T) {
+	saved := statusErrors
+	defer func() { statusErrors = saved }()
+	statusErrors = map[int]wamp.URI{http.StatusForbidden: wamp.ErrNotAuthorized}
+
+	if err := parseErrorMap("app.error.gone=410, app.error.denied=403"); err != nil {
+		t.Fatal(err)
+	}
+	if got := statusError(http.StatusGone); got != "app.error.gone" {
+		t.Errorf("expected app.error.gone for 410, got %s", got)
+	}
+	if got := statusError(http.StatusForbidden); got != "app.error.denied" {
+		t.Errorf("expected override app.error.denied for 403, got %s", got)
+	}
+
+	for _, s := range []string{"app.error.gone", "app.error.gone=abc", "app.error.gone=99", "bad..uri=410", " =410"} {
+		if err := parseErrorMap(s); err == nil {
+			t.Errorf("expected an error for %q", s)
+		}
+	}
+}
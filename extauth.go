@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// errExtAuthUnavailable rejects sessions when the -external-auth-socket
+// co-process cannot be asked or gives no valid answer.  The cause is only
+// logged.
+var errExtAuthUnavailable = errors.New("external authentication unavailable")
+
+// extAuthRequest is the JSON line sent to the co-process for each session
+// asking for the external auth method.  Details are those of the HELLO, with
+// the transport replaced by its type and remote address.
+type extAuthRequest struct {
+	Realm      wamp.URI  `json:"realm"`
+	Session    wamp.ID   `json:"session"`
+	Details    wamp.Dict `json:"details"`
+	Transport  string    `json:"transport"`
+	RemoteAddr string    `json:"remote_addr,omitempty"`
+}
+
+// extAuthResponse is the JSON line the co-process answers with.  Allowed
+// sessions join with the authrole and, if set, the authid, otherwise the one
+// of the HELLO.  The reason of a rejection is sent to the client.
+type extAuthResponse struct {
+	Allow    bool   `json:"allow"`
+	AuthID   string `json:"authid"`
+	AuthRole string `json:"authrole"`
+	Reason   string `json:"reason"`
+}
+
+// extAuth authenticates sessions asking for the external auth method by
+// asking the co-process listening on a Unix socket, one connection per
+// session.  Any failure to get an answer within the timeout rejects the
+// session.  It applies the same join checks and WELCOME details as
+// anonymousAuth.
+type extAuth struct {
+	realm   wamp.URI
+	socket  string
+	timeout time.Duration
+	agent   string
+	extra   wamp.Dict
+}
+
+func (a *extAuth) AuthMethod() string {
+	return "external"
+}
+
+func (a *extAuth) Authenticate(sid wamp.ID, details wamp.Dict, client wamp.Peer) (*wamp.Welcome, error) {
+	filterAuthExtra(details)
+	err := checkJoin(a.realm, sid, details)
+	var res *extAuthResponse
+	if err == nil {
+		res, err = a.ask(sid, details)
+	}
+	if err == nil && !res.Allow {
+		err = fmt.Errorf("external authentication rejected: %s", res.Reason)
+	}
+	if err == nil {
+		err = admitUnique(a.realm, sid, res.AuthID)
+	}
+	countAuth(a.AuthMethod(), err)
+	if err != nil {
+		return nil, err
+	}
+	expireSession(a.realm, sid)
+	return newWelcome(res.AuthID, res.AuthRole, "external", a.AuthMethod(), details, a.agent, a.extra), nil
+}
+
+// ask sends the request of the session to the co-process and returns its
+// answer.  Errors are logged and returned as errExtAuthUnavailable.
+func (a *extAuth) ask(sid wamp.ID, details wamp.Dict) (*extAuthResponse, error) {
+	req := extAuthRequest{Realm: a.realm, Session: sid, Details: wamp.Dict{}}
+	for k, v := range details {
+		if k != "transport" {
+			req.Details[k] = v
+		}
+	}
+	req.Transport = transportType(details)
+	if r, ok := wamp.DictChild(wamp.DictChild(details, "transport"), "auth")["request"].(*http.Request); ok {
+		req.RemoteAddr = r.RemoteAddr
+	}
+	res, err := a.exchange(&req)
+	if err == nil && res.Allow && res.AuthID == "" {
+		if res.AuthID, _ = wamp.AsString(details["authid"]); res.AuthID == "" {
+			err = errors.New("invalid answer: no authid")
+		}
+	}
+	if err != nil {
+		logger.Printf("external auth: session %d of %s: %s\n", sid, a.realm, err)
+		return nil, errExtAuthUnavailable
+	}
+	return res, nil
+}
+
+// exchange writes the request and reads the answer over a new connection to
+// the socket, all within the timeout.
+func (a *extAuth) exchange(req *extAuthRequest) (*extAuthResponse, error) {
+	conn, err := net.DialTimeout("unix", a.socket, a.timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(a.timeout))
+	if err = json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, err
+	}
+	var res extAuthResponse
+	if err = json.NewDecoder(conn).Decode(&res); err != nil {
+		return nil, fmt.Errorf("invalid answer: %s", err)
+	}
+	if res.Allow && res.AuthRole == "" {
+		return nil, errors.New("invalid answer: no authrole")
+	}
+	return &res, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// serveExtAuth runs a mock co-process on a Unix socket, answering each
+// request with answer, and returns the socket path.
+func serveExtAuth(t *testing.T, answer func(extAuthRequest) *extAuthResponse) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "auth.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			var req extAuthRequest
+			if json.NewDecoder(conn).Decode(&req) == nil {
+				if res := answer(req); res != nil {
+					json.NewEncoder(conn).Encode(res)
+				} else {
+					// Hold the connection open without answering.
+					time.Sleep(time.Second)
+				}
+			}
+			conn.Close()
+		}
+	}()
+	return path
+}
+
+// joinExternal connects with the authid and token for the external method.
+func joinExternal(url, authid, token string) (*client.Client, error) {
+	return client.ConnectNet(context.Background(), url, client.Config{
+		Realm:        realm,
+		Logger:       logger,
+		HelloDetails: wamp.Dict{"authid": authid, "authextra": wamp.Dict{"token": token}},
+		AuthHandlers: map[string]client.AuthFunc{
+			"external": func(*wamp.Challenge) (string, wamp.Dict) { return "", wamp.Dict{} },
+		},
+	})
+}
+
+func TestExternalAuth(t *testing.T) {
+	saved := extAuthSock
+	defer func() { extAuthSock = saved }()
+	requests := make(chan extAuthRequest, 2)
+	extAuthSock = serveExtAuth(t, func(req extAuthRequest) *extAuthResponse {
+		requests <- req
+		extra, _ := wamp.AsDict(req.Details["authextra"])
+		if req.Details["authid"] == "alice" && extra["token"] == "good" {
+			return &extAuthResponse{Allow: true, AuthRole: "user"}
+		}
+		return &extAuthResponse{Reason: "bad token"}
+	})
+	url := startTestRouter(t)
+
+	c, err := joinExternal(url, "alice", "good")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	res, err := testCall(getLocalClient(), string(wamp.MetaProcSessionGet), wamp.List{c.ID()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	details, _ := wamp.AsDict(res.Arguments[0])
+	if details["authid"] != "alice" || details["authrole"] != "user" || details["authmethod"] != "external" {
+		t.Errorf("unexpected session details %v", details)
+	}
+	req := <-requests
+	if req.Realm != wamp.URI(realm) || req.Session != c.ID() || req.Transport != "websocket" || req.RemoteAddr == "" {
+		t.Errorf("unexpected request %+v", req)
+	}
+
+	if _, err = joinExternal(url, "mallory", "forged"); err == nil || !strings.Contains(err.Error(), "bad token") {
+		t.Errorf("expected mallory to be rejected with the reason, got %v", err)
+	}
+}
+
+func TestExternalAuthFailsClosed(t *testing.T) {
+	savedSock, savedWait := extAuthSock, extAuthWait
+	defer func() { extAuthSock, extAuthWait = savedSock, savedWait }()
+	extAuthWait = 100 * time.Millisecond
+	silent := serveExtAuth(t, func(extAuthRequest) *extAuthResponse { return nil })
+	invalid := serveExtAuth(t, func(extAuthRequest) *extAuthResponse { return &extAuthResponse{Allow: true} })
+
+	for _, path := range []string{filepath.Join(t.TempDir(), "missing.sock"), silent, invalid} {
+		extAuthSock = path
+		url := startTestRouter(t)
+		start := time.Now()
+		_, err := joinExternal(url, "alice", "good")
+		if err == nil || !strings.Contains(err.Error(), errExtAuthUnavailable.Error()) {
+			t.Errorf("%s: expected the join to fail closed, got %v", filepath.Base(path), err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s: expected the timeout to apply, took %s", filepath.Base(path), elapsed)
+		}
+	}
+}
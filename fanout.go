@@ -0,0 +1,168 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gammazero/nexus/v3/router"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// fanoutBuckets are the upper bounds of the fan-out histogram buckets, the
+// last bucket holding the larger fan-outs.
+var fanoutBuckets = []int{1, 2, 5, 10, 50, 100, 500, 1000}
+
+// fanoutQuiet is the time after the last event of a publication after which
+// its fan-out is recorded, if no other publication of the realm ended it.
+const fanoutQuiet = 100 * time.Millisecond
+
+// pendingFanout is the publication whose events a realm's broker is
+// delivering.
+type pendingFanout struct {
+	pub   wamp.ID
+	count int
+	last  time.Time
+}
+
+var (
+	fanoutMu sync.Mutex
+	// fanoutPending holds the publication being delivered in each realm.
+	fanoutPending = map[wamp.URI]*pendingFanout{}
+	// fanoutHist counts the recorded fan-outs per bucket of fanoutBuckets.
+	fanoutHist   = make([]uint64, len(fanoutBuckets)+1)
+	fanoutPubs   uint64
+	fanoutEvents uint64
+	fanoutMax    int
+	// deliveryFailures counts the events the broker could not queue to a
+	// closed or slow session.
+	deliveryFailures uint64
+)
+
+// fanoutRouter counts the events delivered to the clients attached to the
+// router, with -count-fanout.  It overrides Attach as the RawSocket server
+// calls it instead of AttachClient.
+type fanoutRouter struct {
+	router.Router
+}
+
+func (r fanoutRouter) Attach(client wamp.Peer) error {
+	return r.Router.Attach(newFanoutPeer(client))
+}
+
+func (r fanoutRouter) AttachClient(client wamp.Peer, transportDetails wamp.Dict) error {
+	return r.Router.AttachClient(newFanoutPeer(client), transportDetails)
+}
+
+// fanoutPeer records the events the broker sends to a peer in the fan-out
+// of their publication, in the realm of the peer's HELLO.
+type fanoutPeer struct {
+	wamp.Peer
+	realm     atomic.Value
+	recv      chan wamp.Message
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newFanoutPeer(p wamp.Peer) *fanoutPeer {
+	fp := &fanoutPeer{Peer: p, recv: make(chan wamp.Message), done: make(chan struct{})}
+	go func() {
+		defer close(fp.recv)
+		for msg := range p.Recv() {
+			if hello, ok := msg.(*wamp.Hello); ok {
+				fp.realm.Store(hello.Realm)
+			}
+			select {
+			case fp.recv <- msg:
+			case <-fp.done:
+				return
+			}
+		}
+	}()
+	return fp
+}
+
+// TrySend is how the broker delivers events.
+func (p *fanoutPeer) TrySend(msg wamp.Message) error {
+	err := p.Peer.TrySend(msg)
+	if event, ok := msg.(*wamp.Event); ok {
+		realm, _ := p.realm.Load().(wamp.URI)
+		recordEvent(realm, event.Publication, err == nil)
+	}
+	return err
+}
+
+func (p *fanoutPeer) Recv() <-chan wamp.Message {
+	return p.recv
+}
+
+func (p *fanoutPeer) Close() {
+	p.closeOnce.Do(func() { close(p.done) })
+	p.Peer.Close()
+}
+
+// recordEvent counts an event of the publication delivered in the realm.  A
+// broker delivers the events of one publication after the other, so the
+// first event of another publication ends the fan-out of the previous one.
+func recordEvent(realm wamp.URI, pub wamp.ID, delivered bool) {
+	fanoutMu.Lock()
+	defer fanoutMu.Unlock()
+	if !delivered {
+		deliveryFailures++
+	}
+	p := fanoutPending[realm]
+	if p == nil || p.pub != pub {
+		if p != nil {
+			recordFanout(p.count)
+		}
+		p = &pendingFanout{pub: pub}
+		fanoutPending[realm] = p
+	}
+	p.count++
+	p.last = time.Now()
+}
+
+// recordFanout adds a fan-out to the histogram, under fanoutMu.
+func recordFanout(n int) {
+	i := 0
+	for i < len(fanoutBuckets) && n > fanoutBuckets[i] {
+		i++
+	}
+	fanoutHist[i]++
+	fanoutPubs++
+	fanoutEvents += uint64(n)
+	if n > fanoutMax {
+		fanoutMax = n
+	}
+}
+
+// fanoutStats returns the fan-out histogram and delivery failures as
+// published in the stats, after recording the publications that have not
+// had an event for fanoutQuiet.  Bucket keys are the upper bounds, and inf
+// for the last one.
+func fanoutStats() wamp.Dict {
+	fanoutMu.Lock()
+	defer fanoutMu.Unlock()
+	for realm, p := range fanoutPending {
+		if time.Since(p.last) >= fanoutQuiet {
+			recordFanout(p.count)
+			delete(fanoutPending, realm)
+		}
+	}
+	buckets := wamp.Dict{}
+	for i, n := range fanoutHist {
+		key := "inf"
+		if i < len(fanoutBuckets) {
+			key = strconv.Itoa(fanoutBuckets[i])
+		}
+		buckets[key] = n
+	}
+	return wamp.Dict{
+		"publications":      fanoutPubs,
+		"events":            fanoutEvents,
+		"max":               fanoutMax,
+		"buckets":           buckets,
+		"delivery_failures": deliveryFailures,
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// resetFanout clears the recorded fan-outs.
+func resetFanout() {
+	fanoutMu.Lock()
+	defer fanoutMu.Unlock()
+	fanoutPending = map[wamp.URI]*pendingFanout{}
+	fanoutHist = make([]uint64, len(fanoutBuckets)+1)
+	fanoutPubs, fanoutEvents, fanoutMax, deliveryFailures = 0, 0, 0, 0
+}
+
+func TestFanout(t *testing.T) {
+	resetFanout()
+	defer resetFanout()
+	startTestRouter(t)
+	server := httptest.NewServer(newWebsocketServer(fanoutRouter{wsRouter}))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	rs, err := newRawSocketServer(fanoutRouter{wsRouter}).ListenAndServe("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+
+	// The last subscriber joins over RawSocket.
+	const subscribers = 3
+	events := make(chan *wamp.Event, subscribers)
+	for i := 0; i < subscribers; i++ {
+		subURL := url
+		if i == subscribers-1 {
+			subURL = "tcp://" + rs.(net.Listener).Addr().String()
+		}
+		if err := connectTestClient(t, subURL, realm).SubscribeChan("test.fanout", events, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pub := connectTestClient(t, url, realm)
+	if err := pub.Publish("test.fanout", wamp.Dict{wamp.OptAcknowledge: true}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < subscribers; i++ {
+		select {
+		case <-events:
+		case <-time.After(5 * time.Second):
+			t.Fatal("event not delivered")
+		}
+	}
+
+	// The publication is recorded once no more events follow it.
+	time.Sleep(fanoutQuiet)
+	stats := fanoutStats()
+	buckets, _ := wamp.AsDict(stats["buckets"])
+	if stats["publications"] != uint64(1) || stats["events"] != uint64(subscribers) || stats["max"] != subscribers {
+		t.Errorf("expected one fan-out of %d, got %v", subscribers, stats)
+	}
+	if buckets["5"] != uint64(1) || buckets["2"] != uint64(0) {
+		t.Errorf("expected the fan-out in the bucket of 5, got %v", buckets)
+	}
+}
+
+// failingPeer fails to queue every message, like the peer of a slow session.
+type failingPeer struct {
+	wamp.Peer
+	recv chan wamp.Message
+}
+
+func (p failingPeer) TrySend(wamp.Message) error { return errors.New("blocked") }
+
+func (p failingPeer) Recv() <-chan wamp.Message { return p.recv }
+
+func (p failingPeer) Close() {}
+
+func TestFanoutDeliveryFailures(t *testing.T) {
+	resetFanout()
+	defer resetFanout()
+	recv := make(chan wamp.Message)
+	close(recv)
+	p := newFanoutPeer(failingPeer{recv: recv})
+	defer p.Close()
+
+	// The next publication ends the fan-out of the first one.
+	for _, pub := range []wamp.ID{1, 1, 2} {
+		if err := p.TrySend(&wamp.Event{Publication: pub}); err == nil {
+			t.Fatal("expected the send to fail")
+		}
+	}
+	fanoutMu.Lock()
+	defer fanoutMu.Unlock()
+	if deliveryFailures != 3 || fanoutPubs != 1 || fanoutMax != 2 {
+		t.Errorf("expected 3 failures and a fan-out of 2, got %d failures and %d fan-outs of %d at most", deliveryFailures, fanoutPubs, fanoutMax)
+	}
+}
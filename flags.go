@@ -0,0 +1,157 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// stringList is a flag.Value collecting the values of a repeatable flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// parseFlags parses args into fs.  The error for an unknown flag suggests the
+// closest defined flag, if one is within two edits.  The error is printed once
+// to the output of fs, followed by the usage, which -help prints alone.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	// Parse would print the error, without the suggestion, and the usage.
+	out := fs.Output()
+	fs.SetOutput(io.Discard)
+	err := fs.Parse(args)
+	fs.SetOutput(out)
+	if err == nil {
+		return nil
+	}
+	if err != flag.ErrHelp {
+		err = suggestFlag(fs, err)
+		fmt.Fprintln(out, err)
+	}
+	fs.Usage()
+	return err
+}
+
+// suggestFlag returns err with the closest flag of fs to the unknown one of
+// err, if any.
+func suggestFlag(fs *flag.FlagSet, err error) error {
+	const unknown = "flag provided but not defined: -"
+	if !strings.HasPrefix(err.Error(), unknown) {
+		return err
+	}
+	name := strings.TrimPrefix(err.Error(), unknown)
+	best, bestDist := "", 3
+	fs.VisitAll(func(f *flag.Flag) {
+		if d := editDistance(name, f.Name); d < bestDist {
+			best, bestDist = f.Name, d
+		}
+	})
+	if best == "" {
+		return err
+	}
+	return fmt.Errorf("%s, did you mean -%s?", err, best)
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// validateFlags checks the combinations of the parsed flags in fs, rejecting
+// flags that have no effect with the others given.
+func validateFlags(fs *flag.FlagSet) error {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	value := func(name string) string {
+		if f := fs.Lookup(name); f != nil {
+			return f.Value.String()
+		}
+		return ""
+	}
+
+	if value("ws") == "false" && value("rs") == "false" {
+		return fmt.Errorf("one of WebSocket (-ws) or RawSocket (-rs) transports must be enabled")
+	}
+	for _, t := range []struct {
+		enable string
+		flags  []string
+	}{
+		{"ws", []string{"ws-host", "ws-port", "ws-header", "ws-strict-paths", "dashboard"}},
+		{"rs", []string{"rs-host", "rs-port", "rs-proto", "rs-listen", "rs-accept-rate"}},
+	} {
+		for _, name := range t.flags {
+			if set[name] && value(t.enable) == "false" {
+				return fmt.Errorf("-%s cannot be used with -%s=false", name, t.enable)
+			}
+		}
+	}
+	if set["ws-port"] && strings.HasPrefix(value("ws-host"), "unix:") {
+		return fmt.Errorf("-ws-port cannot be used with a unix: -ws-host")
+	}
+	if set["debug-log-payloads"] && value("debug-log-size") == "0" {
+		return fmt.Errorf("-debug-log-payloads requires -debug-log-size")
+	}
+	for _, name := range []string{"trace-max-size", "trace-redact"} {
+		if set[name] && value("log-level") != "trace" && value("tap") == "" {
+			return fmt.Errorf("-%s requires -log-level trace or -tap", name)
+		}
+	}
+	if set["dashboard-auth"] && set["dashboard-auth-file"] {
+		return fmt.Errorf("-dashboard-auth cannot be used with -dashboard-auth-file")
+	}
+	for _, name := range []string{"dashboard-path", "dashboard-auth", "dashboard-auth-file"} {
+		if set[name] && value("dashboard") != "true" {
+			return fmt.Errorf("-%s requires -dashboard", name)
+		}
+	}
+	for _, name := range []string{"http-proxy", "http-ca-file", "http-insecure-skip-verify"} {
+		if set[name] && value("proxy-config") == "" {
+			return fmt.Errorf("-%s requires -proxy-config", name)
+		}
+	}
+	if set["topology-depth"] && value("topology-interval") == "0s" {
+		return fmt.Errorf("-topology-depth requires -topology-interval")
+	}
+	if set["quota-window"] && value("realm-quotas") == "" {
+		return fmt.Errorf("-quota-window requires -realm-quotas")
+	}
+	for _, name := range []string{"auth-user-header", "auth-role-header"} {
+		if set[name] && value("trusted-proxies") == "" {
+			return fmt.Errorf("-%s requires -trusted-proxies", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"strings"
+	"testing"
+)
+
+// testFlagSet returns a flag set with the flags validateFlags looks at.
+func testFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Bool("ws", true, "")
+	fs.String("ws-host", "localhost", "")
+	fs.Int("ws-port", 8951, "")
+	fs.Bool("rs", true, "")
+	fs.Int("rs-port", 8952, "")
+	fs.Int("rs-accept-rate", 0, "")
+	fs.Int("debug-log-size", 0, "")
+	fs.Bool("debug-log-payloads", false, "")
+	fs.String("log-level", "info", "")
+	fs.String("trace-redact", "", "")
+	fs.Var(&stringList{}, "tap", "")
+	fs.String("trusted-proxies", "", "")
+	fs.String("auth-user-header", "X-Auth-User", "")
+	fs.Bool("dashboard", false, "")
+	fs.String("dashboard-auth", "", "")
+	fs.String("dashboard-auth-file", "", "")
+	fs.String("proxy-config", "", "")
+	fs.String("http-proxy", "", "")
+	fs.Duration("topology-interval", 0, "")
+	fs.Int("topology-depth", 2, "")
+	return fs
+}
+
+func TestParseFlagsSuggestion(t *testing.T) {
+	err := parseFlags(testFlagSet(), []string{"-wsport", "9000"})
+	if err == nil || !strings.Contains(err.Error(), "did you mean -ws-port?") {
+		t.Errorf("expected a suggestion of -ws-port, got %v", err)
+	}
+	err = parseFlags(testFlagSet(), []string{"-completely-unknown"})
+	if err == nil || strings.Contains(err.Error(), "did you mean") {
+		t.Errorf("expected an error without a suggestion, got %v", err)
+	}
+	if err = parseFlags(testFlagSet(), []string{"-ws-port", "9000"}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseFlagsOutput(t *testing.T) {
+	var out bytes.Buffer
+	fs := testFlagSet()
+	fs.SetOutput(&out)
+	err := parseFlags(fs, []string{"-wsport", "9000"})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if n := strings.Count(out.String(), "-wsport"); n != 1 {
+		t.Errorf("expected the error to be printed once, got %d times in %q", n, out.String())
+	}
+	if !strings.HasPrefix(out.String(), err.Error()+"\nUsage of test:\n") {
+		t.Errorf("expected the error followed by the usage, got %q", out.String())
+	}
+
+	out.Reset()
+	if err = parseFlags(fs, []string{"-help"}); err != flag.ErrHelp {
+		t.Fatalf("expected flag.ErrHelp, got %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "Usage of test:\n") {
+		t.Errorf("expected the usage alone, got %q", out.String())
+	}
+}
+
+func TestValidateFlags(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		err  string
+	}{
+		{nil, ""},
+		{[]string{"-ws=false", "-rs=false"}, "must be enabled"},
+		{[]string{"-ws=false", "-ws-port", "9000"}, "-ws-port cannot be used with -ws=false"},
+		{[]string{"-rs=false", "-rs-port", "9000"}, "-rs-port cannot be used with -rs=false"},
+		{[]string{"-rs=false", "-rs-accept-rate", "10"}, "-rs-accept-rate cannot be used with -rs=false"},
+		{[]string{"-ws-host", "unix:/tmp/ws.sock", "-ws-port", "9000"}, "unix:"},
+		{[]string{"-ws-host", "unix:/tmp/ws.sock"}, ""},
+		{[]string{"-debug-log-payloads"}, "requires -debug-log-size"},
+		{[]string{"-debug-log-payloads", "-debug-log-size", "10"}, ""},
+		{[]string{"-trace-redact", "password"}, "requires -log-level trace"},
+		{[]string{"-trace-redact", "password", "-log-level", "trace"}, ""},
+		{[]string{"-trace-redact", "password", "-tap", "app.audit"}, ""},
+		{[]string{"-auth-user-header", "X-User"}, "requires -trusted-proxies"},
+		{[]string{"-auth-user-header", "X-User", "-trusted-proxies", "10.0.0.1"}, ""},
+		{[]string{"-ws=false", "-dashboard"}, "-dashboard cannot be used with -ws=false"},
+		{[]string{"-dashboard-auth", "admin:secret"}, "requires -dashboard"},
+		{[]string{"-dashboard", "-dashboard-auth", "admin:secret"}, ""},
+		{[]string{"-dashboard-auth-file", "dashboard.auth"}, "requires -dashboard"},
+		{[]string{"-dashboard", "-dashboard-auth", "admin:secret", "-dashboard-auth-file", "dashboard.auth"}, "cannot be used with -dashboard-auth-file"},
+		{[]string{"-dashboard", "-dashboard-auth-file", "dashboard.auth"}, ""},
+		{[]string{"-http-proxy", "http://proxy:3128"}, "requires -proxy-config"},
+		{[]string{"-http-proxy", "http://proxy:3128", "-proxy-config", "proxy.json"}, ""},
+		{[]string{"-topology-depth", "3"}, "requires -topology-interval"},
+		{[]string{"-topology-depth", "3", "-topology-interval", "1m"}, ""},
+	} {
+		fs := testFlagSet()
+		if err := fs.Parse(tc.args); err != nil {
+			t.Fatal(err)
+		}
+		err := validateFlags(fs)
+		if tc.err == "" && err != nil {
+			t.Errorf("%v: %s", tc.args, err)
+		}
+		if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("%v: expected an error containing %q, got %v", tc.args, tc.err, err)
+		}
+	}
+}
@@ -2,10 +2,12 @@ module github.com/lajosbencz/nexus-simple-router
 
 go 1.19
 
-require github.com/gammazero/nexus/v3 v3.0.4
+require (
+	github.com/gammazero/nexus/v3 v3.0.4
+	github.com/gorilla/websocket v1.4.2
+)
 
 require (
-	github.com/gorilla/websocket v1.4.2 // indirect
 	github.com/ugorji/go/codec v1.2.5 // indirect
 	golang.org/x/crypto v0.0.0-20201016220609-9e8e0b390897 // indirect
 )
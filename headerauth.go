@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// trustedNets are the addresses of the reverse proxies whose identity headers
+// are trusted, from -trusted-proxies.  Trusted header authentication is only
+// offered when it is set.
+var trustedNets []*net.IPNet
+
+// parseTrustedProxies parses a comma separated list of IP addresses and CIDR
+// ranges.
+func parseTrustedProxies(s string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q, expected an IP address or CIDR range", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q, expected an IP address or CIDR range", entry)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// errUntrustedSource rejects identity headers of connections not coming from
+// a trusted proxy.
+var errUntrustedSource = errors.New("identity headers are only accepted from trusted proxies")
+
+// headerAuth authenticates WebSocket sessions with the authid and authrole
+// headers a trusted reverse proxy set on the upgrade request.  Requests from
+// other addresses are rejected, whatever headers they carry.
+type headerAuth struct {
+	realm      wamp.URI
+	trusted    []*net.IPNet
+	userHeader string
+	roleHeader string
+	agent      string
+	extra      wamp.Dict
+}
+
+func (a *headerAuth) AuthMethod() string {
+	return "trusted-header"
+}
+
+func (a *headerAuth) Authenticate(sid wamp.ID, details wamp.Dict, client wamp.Peer) (*wamp.Welcome, error) {
+	filterAuthExtra(details)
+	authid, authrole, err := a.identity(details)
+	if err == nil {
+		err = checkJoin(a.realm, sid, details)
+	}
+	if err == nil {
+		err = admitUnique(a.realm, sid, authid)
+	}
+	countAuth(a.AuthMethod(), err)
+	if err != nil {
+		return nil, err
+	}
+	expireSession(a.realm, sid)
+	return newWelcome(authid, authrole, "proxy", a.AuthMethod(), details, a.agent, a.extra), nil
+}
+
+// identity returns the authid and authrole headers of the upgrade request in
+// the HELLO details, which the WebSocket server captures for this.
+func (a *headerAuth) identity(details wamp.Dict) (string, string, error) {
+	req, _ := wamp.DictChild(wamp.DictChild(details, "transport"), "auth")["request"].(*http.Request)
+	if req == nil {
+		return "", "", errors.New("trusted header authentication requires a WebSocket connection")
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	ip := net.ParseIP(host)
+	if err != nil || ip == nil || !a.trustedIP(ip) {
+		return "", "", errUntrustedSource
+	}
+	authid, authrole := req.Header.Get(a.userHeader), req.Header.Get(a.roleHeader)
+	if authid == "" || authrole == "" {
+		return "", "", fmt.Errorf("missing %s or %s header", a.userHeader, a.roleHeader)
+	}
+	return authid, authrole, nil
+}
+
+func (a *headerAuth) trustedIP(ip net.IP) bool {
+	for _, n := range a.trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
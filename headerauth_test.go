@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+	"github.com/gorilla/websocket"
+)
+
+// helloWithHeaders connects with the headers on the upgrade request, sends a
+// HELLO asking for trusted header authentication and returns the reply.
+func helloWithHeaders(t *testing.T, url string, header http.Header) []interface{} {
+	t.Helper()
+	dialer := websocket.Dialer{Subprotocols: []string{"wamp.2.json"}}
+	conn, _, err := dialer.Dial(url, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	hello := []interface{}{1, realm, map[string]interface{}{
+		"roles":       map[string]interface{}{"caller": map[string]interface{}{}},
+		"authmethods": []string{"trusted-header"},
+	}}
+	if err = conn.WriteJSON(hello); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg []interface{}
+	if err = json.Unmarshal(data, &msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	nets, err := parseTrustedProxies("127.0.0.1, 10.0.0.0/8, ::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nets) != 3 || nets[0].String() != "127.0.0.1/32" || nets[2].String() != "::1/128" {
+		t.Errorf("unexpected networks %v", nets)
+	}
+	for _, s := range []string{"localhost", "10.0.0.0/33"} {
+		if _, err = parseTrustedProxies(s); err == nil {
+			t.Errorf("expected an error for %q", s)
+		}
+	}
+}
+
+func TestHeaderAuth(t *testing.T) {
+	identity := http.Header{"X-Auth-User": {"alice"}, "X-Auth-Role": {"operator"}}
+	for _, tc := range []struct {
+		name    string
+		trusted string
+		header  http.Header
+		welcome bool
+	}{
+		{"trusted", "127.0.0.1", identity, true},
+		{"untrusted", "10.0.0.0/8", identity, false},
+		{"missing headers", "127.0.0.1", http.Header{"X-Auth-User": {"alice"}}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			saved := trustedNets
+			t.Cleanup(func() { trustedNets = saved })
+			var err error
+			if trustedNets, err = parseTrustedProxies(tc.trusted); err != nil {
+				t.Fatal(err)
+			}
+			msg := helloWithHeaders(t, startTestRouter(t), tc.header)
+			if !tc.welcome {
+				if msg[0] != float64(wamp.ABORT) {
+					t.Errorf("expected an ABORT, got %v", msg)
+				}
+				return
+			}
+			if msg[0] != float64(wamp.WELCOME) {
+				t.Fatalf("expected a WELCOME, got %v", msg)
+			}
+			details := msg[2].(map[string]interface{})
+			if details["authid"] != "alice" || details["authrole"] != "operator" || details["authmethod"] != "trusted-header" {
+				t.Errorf("unexpected WELCOME details %v", details)
+			}
+		})
+	}
+}
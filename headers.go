@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// parseResponseHeaders parses -ws-header values given as "Name: value" into
+// a header.  Headers of the WebSocket handshake itself cannot be set.
+func parseResponseHeaders(values []string) (http.Header, error) {
+	header := http.Header{}
+	for _, s := range values {
+		name, value, ok := strings.Cut(s, ":")
+		name = textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name))
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid header %q, expected Name: value", s)
+		}
+		switch {
+		case name == "Upgrade", name == "Connection", strings.HasPrefix(name, "Sec-Websocket-"):
+			return nil, fmt.Errorf("header %s is part of the WebSocket handshake and cannot be set", name)
+		}
+		header.Add(name, strings.TrimSpace(value))
+	}
+	return header, nil
+}
+
+// responseHeaders returns a handler adding header to the responses of h.
+// nexus passes the response headers to the WebSocket upgrade, so they are
+// also sent with the handshake response.
+func responseHeaders(h http.Handler, header http.Header) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for name, values := range header {
+			w.Header()[name] = append(w.Header()[name], values...)
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestParseResponseHeaders(t *testing.T) {
+	header, err := parseResponseHeaders([]string{"x-frame-options: DENY", "X-Custom: a", "X-Custom: b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Get("X-Frame-Options") != "DENY" || len(header.Values("X-Custom")) != 2 {
+		t.Errorf("unexpected header %v", header)
+	}
+	for _, s := range []string{"X-Frame-Options", ": value", "Bad Name: value", "Connection: close", "Sec-WebSocket-Protocol: wamp.2.json"} {
+		if _, err = parseResponseHeaders([]string{s}); err == nil {
+			t.Errorf("expected an error for %q", s)
+		}
+	}
+}
+
+func TestResponseHeaders(t *testing.T) {
+	startTestRouter(t)
+	header, err := parseResponseHeaders([]string{"Strict-Transport-Security: max-age=63072000", "X-Frame-Options: DENY"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(responseHeaders(newWebsocketServer(wsRouter), header))
+	defer server.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{"wamp.2.json"}}
+	conn, res, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if res.Header.Get("Strict-Transport-Security") != "max-age=63072000" || res.Header.Get("X-Frame-Options") != "DENY" {
+		t.Errorf("expected the headers on the upgrade response, got %v", res.Header)
+	}
+	if res.Header.Get("Sec-WebSocket-Protocol") != "wamp.2.json" {
+		t.Errorf("expected the handshake headers to be kept, got %v", res.Header)
+	}
+
+	// Plain HTTP requests are rejected by the upgrader, with the headers.
+	res, err = server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.Header.Get("X-Frame-Options") != "DENY" {
+		t.Errorf("expected the headers on a failed upgrade, got %v", res.Header)
+	}
+}
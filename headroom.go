@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// headroomStats returns the usage of the router's limits as published in the
+// stats: the remote sessions of each realm against -max-sessions, when set,
+// and the queue of the internal publishers against -publish-buffer.  They are
+// taken anew each time, so closed realms drop out.
+func headroomStats(ctx context.Context) wamp.Dict {
+	headroom := wamp.Dict{"publish_queue": headroomGauge(len(publishQueue), cap(publishQueue))}
+	if maxSessions <= 0 {
+		return headroom
+	}
+	realmCountsMu.Lock()
+	uris := make([]wamp.URI, 0, len(realmCounts))
+	for uri := range realmCounts {
+		uris = append(uris, uri)
+	}
+	realmCountsMu.Unlock()
+	sessions := wamp.Dict{}
+	for _, uri := range uris {
+		// Realms closed by the admin procedures cannot be asked anymore.
+		count, err := realmSessionCount(ctx, uri)
+		if err != nil {
+			continue
+		}
+		sessions[string(uri)] = headroomGauge(int(count), maxSessions)
+	}
+	headroom["sessions"] = sessions
+	return headroom
+}
+
+// headroomGauge returns the used part of a limit, what is left of it and the
+// ratio of the two, 1 meaning the limit is reached.
+func headroomGauge(used, limit int) wamp.Dict {
+	return wamp.Dict{
+		"used":  used,
+		"limit": limit,
+		"free":  limit - used,
+		"ratio": float64(used) / float64(limit),
+	}
+}
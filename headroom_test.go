@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestHeadroomStats(t *testing.T) {
+	saved := maxSessions
+	defer func() { maxSessions = saved }()
+	maxSessions = 4
+
+	url := startTestRouter(t, "closed")
+	connectTestClient(t, url, realm)
+	connectTestClient(t, url, realm)
+	connectTestClient(t, url, "closed")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	headroom := headroomStats(ctx)
+	sessions, _ := wamp.AsDict(headroom["sessions"])
+	gauge, _ := wamp.AsDict(sessions[realm])
+	if gauge["used"] != 2 || gauge["limit"] != 4 || gauge["free"] != 2 || gauge["ratio"] != 0.5 {
+		t.Errorf("expected half of the sessions of %s used, got %v", realm, gauge)
+	}
+	if gauge, _ = wamp.AsDict(headroom["publish_queue"]); gauge["limit"] != publishBuf {
+		t.Errorf("expected the publish buffer as limit, got %v", gauge)
+	}
+
+	// A closed realm drops out.
+	if _, err := closeRealm(ctx, "closed", wamp.CloseNormal, ""); err != nil {
+		t.Fatal(err)
+	}
+	sessions, _ = wamp.AsDict(headroomStats(ctx)["sessions"])
+	if _, ok := sessions["closed"]; ok || len(sessions) != 1 {
+		t.Errorf("expected only %s, got %v", realm, sessions)
+	}
+
+	maxSessions = 0
+	if _, ok := headroomStats(ctx)["sessions"]; ok {
+		t.Error("expected no session headroom without a session limit")
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"runtime/debug"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// version and commit may be set at build time with -ldflags "-X main.version=...".
+// When empty they are taken from the embedded build info.
+var (
+	version = ""
+	commit  = ""
+)
+
+// transports lists the addresses of the started transports.
+var transports = wamp.List{}
+
+func buildVersion() (string, string) {
+	v, c := version, commit
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if v == "" {
+			v = info.Main.Version
+		}
+		for _, s := range info.Settings {
+			if c == "" && s.Key == "vcs.revision" {
+				c = s.Value
+			}
+		}
+	}
+	return v, c
+}
+
+// nexusInfo handles the nexus.info procedure.
+func nexusInfo(ctx context.Context, inv *wamp.Invocation) client.InvokeResult {
+	v, c := buildVersion()
+	return client.InvokeResult{Args: wamp.List{wamp.Dict{
+		"version":     v,
+		"commit":      c,
+		"start_time":  startTime.Format(time.RFC3339),
+		"uptime":      time.Since(startTime).Seconds(),
+		"transports":  transports,
+		"health":      healthStatus(),
+		"meta_events": metaTopics(),
+		"limits":      limits(),
+	}}}
+}
+
+// limits returns the configured runtime limits, so clients can adapt to them.
+// Zero means unlimited or disabled.
+func limits() wamp.Dict {
+	return wamp.Dict{
+		"rs_max_msg_size":      1 << (9 + rsMaxLenExp),
+		"warn_msg_size":        warnMsgSize,
+		"max_sessions":         maxSessions,
+		"max_session_lifetime": maxLifetime.Seconds(),
+		"overload_retry_after": retryAfter.Seconds(),
+		"ws_keepalive":         wsKeepAlive.Seconds(),
+		"rs_keepalive":         rsKeepAlive.Seconds(),
+		"rs_accept_rate":       rsAccRate,
+		"serializer_max_sizes": serializerSizes,
+		"admin_page_size":      adminPage,
+	}
+}
+
+// nexusFeatures handles the nexus.features procedure.
+func nexusFeatures(ctx context.Context, inv *wamp.Invocation) client.InvokeResult {
+	return client.InvokeResult{Args: wamp.List{features()}}
+}
+
+// features returns the optional subsystems and toggles mapped to whether they
+// are enabled and a summary of their configuration.  Proxy mapping URLs and
+// headers are left out, as they can carry credentials.
+func features() wamp.Dict {
+	return wamp.Dict{
+		"dev_echo":     wamp.Dict{"enabled": devEcho},
+		"dev_time":     wamp.Dict{"enabled": devTime, "always": devTimeAll, "format": dtimeFormat},
+		"dev_wildcard": wamp.Dict{"enabled": devWildcard},
+		"proxy":        wamp.Dict{"enabled": proxyConfig != "", "config": proxyConfig, "http_proxy": httpProxy != "", "insecure_skip_verify": skipVerify},
+		"stats":        wamp.Dict{"enabled": statsTopic != "", "topic": statsTopic, "interval": statsEvery.Seconds()},
+		"count_conns":  wamp.Dict{"enabled": countConns},
+		"diag":         wamp.Dict{"enabled": diagTopic != "", "topic": diagTopic},
+		"trusted_header_auth": wamp.Dict{
+			"enabled":         len(trustedNets) != 0,
+			"trusted_proxies": trustedCfg,
+			"user_header":     userHeader,
+			"role_header":     roleHeader,
+		},
+		"ticket_auth":      wamp.Dict{"enabled": tickets != nil, "file": ticketFile},
+		"unique_authid":    wamp.Dict{"enabled": uniqueMode != "", "mode": uniqueMode},
+		"abort_reasons":    wamp.Dict{"enabled": abortReason},
+		"dashboard":        wamp.Dict{"enabled": dashEnable, "path": dashPath},
+		"serializers":      wamp.Dict{"enabled": len(realmSerializers) != 0, "realms": realmSerCfg},
+		"topology":         wamp.Dict{"enabled": topoEvery > 0, "interval": topoEvery.Seconds(), "depth": topoDepth},
+		"client_agents":    wamp.Dict{"enabled": agentsAllowed != nil || agentsDenied != nil, "allow": agentAllow, "deny": agentDeny, "log": logAgents},
+		"meta_roles":       wamp.Dict{"enabled": metaRoles != nil, "roles": metaRoleCfg},
+		"external_auth":    wamp.Dict{"enabled": extAuthSock != "", "socket": extAuthSock, "timeout": extAuthWait.Seconds()},
+		"on_join":          wamp.Dict{"enabled": onJoinProc != "" || onJoinTopic != "", "proc": onJoinProc, "topic": onJoinTopic},
+		"reconnect":        wamp.Dict{"enabled": reconTopic != "", "topic": reconTopic, "window": reconWindow.Seconds()},
+		"debug_log":        wamp.Dict{"enabled": debugLogLen > 0, "size": debugLogLen, "payloads": debugArgs},
+		"session_registry": wamp.Dict{"enabled": sessRegFile != "", "file": sessRegFile},
+		"disabled_file":    wamp.Dict{"enabled": disableFile != "", "file": disableFile},
+		"uri_prefix":       wamp.Dict{"enabled": uriPrefix != "", "prefix": uriPrefix},
+		"strict_uri":       wamp.Dict{"enabled": strictURI},
+		"normalize_realms": wamp.Dict{"enabled": normRealms},
+		"ws_compression":   wamp.Dict{"enabled": wsCompress},
+		"ws_write_pool":    wamp.Dict{"enabled": wsWritePool},
+		"ws_strict_paths":  wamp.Dict{"enabled": strictPaths},
+		"count_bytes":      wamp.Dict{"enabled": countBytes},
+		"count_fanout":     wamp.Dict{"enabled": countFanout},
+		"publish_acks":     wamp.Dict{"enabled": countAcks, "log": logAcks},
+		"maintenance":      wamp.Dict{"enabled": inMaintenance.Load()},
+		"accept_paused":    wamp.Dict{"enabled": acceptPaused.Load()},
+		"whoami":           wamp.Dict{"enabled": whoamiOn},
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestNexusInfo(t *testing.T) {
+	savedVersion := version
+	defer func() { version = savedVersion }()
+	version = "v1.2.3"
+
+	url := startTestRouter(t)
+	if err := createLocalCallee(getLocalClient(), "nexus.info", nexusInfo); err != nil {
+		t.Fatal(err)
+	}
+	res, err := testCall(connectTestClient(t, url, realm), "nexus.info", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, _ := wamp.AsDict(res.Arguments[0])
+	if info["version"] != "v1.2.3" {
+		t.Errorf("expected the ldflags version, got %v", info["version"])
+	}
+	if uptime, ok := wamp.AsFloat64(info["uptime"]); !ok || uptime < 0 {
+		t.Errorf("expected a non-negative uptime, got %v", info["uptime"])
+	}
+	if _, ok := wamp.AsString(info["start_time"]); !ok {
+		t.Errorf("expected a start_time, got %v", info["start_time"])
+	}
+}
+
+func TestNexusInfoLimits(t *testing.T) {
+	savedExp, savedMax, savedWarn := rsMaxLenExp, maxSessions, warnMsgSize
+	defer func() { rsMaxLenExp, maxSessions, warnMsgSize = savedExp, savedMax, savedWarn }()
+	rsMaxLenExp, maxSessions, warnMsgSize = 7, 50, 4096
+
+	url := startTestRouter(t)
+	if err := createLocalCallee(getLocalClient(), "nexus.info", nexusInfo); err != nil {
+		t.Fatal(err)
+	}
+	res, err := testCall(connectTestClient(t, url, realm), "nexus.info", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, _ := wamp.AsDict(res.Arguments[0])
+	limits, _ := wamp.AsDict(info["limits"])
+	for key, want := range map[string]int64{
+		"rs_max_msg_size": 1 << 16,
+		"max_sessions":    50,
+		"warn_msg_size":   4096,
+	} {
+		if got, _ := wamp.AsInt64(limits[key]); got != want {
+			t.Errorf("%s: expected %d, got %v", key, want, limits[key])
+		}
+	}
+	if got, _ := wamp.AsFloat64(limits["overload_retry_after"]); got != retryAfter.Seconds() {
+		t.Errorf("overload_retry_after: expected %v, got %v", retryAfter.Seconds(), limits["overload_retry_after"])
+	}
+}
+
+func TestNexusFeatures(t *testing.T) {
+	savedTopic := statsTopic
+	defer func() { statsTopic = savedTopic }()
+	statsTopic = ""
+
+	url := startTestRouter(t)
+	if err := createLocalCallee(getLocalClient(), "nexus.features", nexusFeatures); err != nil {
+		t.Fatal(err)
+	}
+	c := connectTestClient(t, url, realm)
+	stats := func() wamp.Dict {
+		res, err := testCall(c, "nexus.features", nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		features, _ := wamp.AsDict(res.Arguments[0])
+		stats, _ := wamp.AsDict(features["stats"])
+		return stats
+	}
+	if got := stats(); got["enabled"] != false {
+		t.Errorf("expected stats to be disabled, got %v", got)
+	}
+	statsTopic = "router.stats"
+	if got := stats(); got["enabled"] != true || got["topic"] != "router.stats" {
+		t.Errorf("expected stats to be enabled on router.stats, got %v", got)
+	}
+}
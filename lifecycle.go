@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// Phases of the router lifecycle, in order.
+const (
+	lifecycleStarting  = "starting"
+	lifecycleListening = "listening"
+	lifecycleReady     = "ready"
+	lifecycleDraining  = "draining"
+	lifecycleStopped   = "stopped"
+)
+
+var (
+	lifecycleMu sync.Mutex
+	// lifecycleOut receives the lifecycle events as JSON lines with
+	// -lifecycle-stdout.
+	lifecycleOut io.Writer
+)
+
+// emitLifecycle writes a lifecycle event of the phase with its details to
+// lifecycleOut and publishes it on -lifecycle-topic.  The topic only gets the
+// events while the local client is connected, from listening to draining;
+// the event is published right away, as the publish queue stops first on
+// shutdown.
+func emitLifecycle(phase string, details wamp.Dict) {
+	event := wamp.Dict{"phase": phase, "time": time.Now().UTC().Format(time.RFC3339Nano)}
+	for k, v := range details {
+		event[k] = v
+	}
+	if lifecycleOut != nil {
+		data, err := json.Marshal(event)
+		if err != nil {
+			logger.Printf("failed to encode lifecycle event: %s\n", err)
+		} else {
+			lifecycleMu.Lock()
+			lifecycleOut.Write(append(data, '\n'))
+			lifecycleMu.Unlock()
+		}
+	}
+	if lifeTopic == "" {
+		return
+	}
+	if c := getLocalClient(); c != nil && c.Connected() {
+		if err := c.Publish(lifeTopic, nil, wamp.List{event}, nil); err != nil {
+			logger.Printf("failed to publish on %s: %s\n", lifeTopic, err)
+		}
+	}
+}
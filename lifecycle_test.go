@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestLifecycleEvents(t *testing.T) {
+	savedOut, savedTopic := lifecycleOut, lifeTopic
+	defer func() { lifecycleOut, lifeTopic = savedOut, savedTopic }()
+	var out bytes.Buffer
+	lifecycleOut, lifeTopic = &out, "test.lifecycle"
+
+	emitLifecycle(lifecycleStarting, nil)
+	url := startTestRouter(t)
+	events := make(chan *wamp.Event, 4)
+	if err := connectTestClient(t, url, realm).SubscribeChan(lifeTopic, events, nil); err != nil {
+		t.Fatal(err)
+	}
+	emitLifecycle(lifecycleListening, wamp.Dict{"url": url})
+	logStartup([]wamp.URI{wamp.URI(realm)})
+	stopRouter(shutdownSteps([]wamp.URI{wamp.URI(realm)}, []io.Closer{}), 5*time.Second)
+	wsRouter = nil
+
+	var phases []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var event map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("invalid lifecycle line %q: %s", line, err)
+		}
+		if _, err := time.Parse(time.RFC3339Nano, event["time"].(string)); err != nil {
+			t.Errorf("invalid time in %v", event)
+		}
+		phases = append(phases, event["phase"].(string))
+	}
+	want := []string{lifecycleStarting, lifecycleListening, lifecycleReady, lifecycleDraining, lifecycleStopped}
+	if strings.Join(phases, ",") != strings.Join(want, ",") {
+		t.Errorf("expected phases %v, got %v", want, phases)
+	}
+
+	// The subscriber is disconnected while draining, after the event.
+	var published []string
+	for len(published) < 3 {
+		select {
+		case event := <-events:
+			details, _ := wamp.AsDict(event.Arguments[0])
+			published = append(published, details["phase"].(string))
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected the listening, ready and draining events on the topic, got %v", published)
+		}
+	}
+	if strings.Join(published, ",") != "listening,ready,draining" {
+		t.Errorf("unexpected published phases %v", published)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+var (
+	expiryMu sync.Mutex
+	// expiryTimers holds the pending expiry of each session by session ID,
+	// stopped when the session leaves.
+	expiryTimers = map[wamp.ID]*time.Timer{}
+)
+
+// expireSession kills the session of the realm once it has been joined for
+// -max-session-lifetime, whatever its activity.  Sessions that left without
+// an on_leave event are not found and ignored.
+func expireSession(uri wamp.URI, sid wamp.ID) {
+	lifetime := maxLifetime
+	if lifetime <= 0 {
+		return
+	}
+	expiryMu.Lock()
+	defer expiryMu.Unlock()
+	expiryTimers[sid] = time.AfterFunc(lifetime, func() {
+		expiryMu.Lock()
+		delete(expiryTimers, sid)
+		expiryMu.Unlock()
+		killed, err := killSession(uri, sid, closeReauthenticate, fmt.Sprintf("session exceeded its maximum lifetime of %s", lifetime))
+		if err != nil {
+			logger.Printf("failed to expire session %d of %s: %s\n", sid, uri, err)
+		} else if killed {
+			logger.Printf("expired session %d of %s after %s\n", sid, uri, lifetime)
+		}
+	})
+}
+
+// watchExpiries stops the expiry of the sessions of the realm that leave.
+func watchExpiries(uri wamp.URI) error {
+	return onRealmMetaEvent(uri, wamp.MetaEventSessionOnLeave, func(event *wamp.Event) {
+		if len(event.Arguments) == 0 {
+			return
+		}
+		if sid, ok := wamp.AsID(event.Arguments[0]); ok {
+			expiryMu.Lock()
+			if timer := expiryTimers[sid]; timer != nil {
+				timer.Stop()
+				delete(expiryTimers, sid)
+			}
+			expiryMu.Unlock()
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"log"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestMaxSessionLifetime(t *testing.T) {
+	saved := maxLifetime
+	defer func() { maxLifetime = saved }()
+	maxLifetime = 300 * time.Millisecond
+
+	url := startTestRouter(t)
+	expired := &countingWriter{match: "expired session"}
+	logger = log.New(expired, "", 0)
+	c := connectTestClient(t, url, realm)
+	joined := time.Now()
+	select {
+	case <-c.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("session not disconnected after its maximum lifetime")
+	}
+	if elapsed := time.Since(joined); elapsed < maxLifetime/2 {
+		t.Errorf("session disconnected after %s, before its maximum lifetime", elapsed)
+	}
+	if goodbye := c.RouterGoodbye(); goodbye == nil || goodbye.Reason != closeReauthenticate {
+		t.Errorf("expected a %s goodbye, got %v", closeReauthenticate, goodbye)
+	}
+	// The expiry is logged once the kill returns.
+	if !waitFor(t, 5*time.Second, func() bool { return atomic.LoadInt32(&expired.count) == 1 }) {
+		t.Error("expiry not logged")
+	}
+	// The local client is not expired.
+	if !getLocalClient().Connected() {
+		t.Error("local client disconnected")
+	}
+}
+
+func TestSessionExpiryStopped(t *testing.T) {
+	saved := maxLifetime
+	defer func() { maxLifetime = saved }()
+	maxLifetime = time.Hour
+
+	url := startTestRouter(t)
+	if err := watchExpiries(wamp.URI(realm)); err != nil {
+		t.Fatal(err)
+	}
+	c := connectTestClient(t, url, realm)
+	expiryMu.Lock()
+	timer := expiryTimers[c.ID()]
+	expiryMu.Unlock()
+	if timer == nil {
+		t.Fatal("no expiry pending for the joined session")
+	}
+	c.Close()
+	stopped := waitFor(t, 5*time.Second, func() bool {
+		expiryMu.Lock()
+		defer expiryMu.Unlock()
+		return expiryTimers[c.ID()] == nil
+	})
+	if !stopped {
+		t.Fatal("expiry still pending after the session left")
+	}
+	if timer.Stop() {
+		t.Error("expiry timer not stopped")
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// loadgenConfig is the configuration of a load generator run, from the flags
+// of the loadgen subcommand.
+type loadgenConfig struct {
+	url      string
+	realm    string
+	clients  int
+	rate     float64
+	duration time.Duration
+	mode     string
+	topic    string
+}
+
+// loadgenStats is the result of a load generator run.
+type loadgenStats struct {
+	ops      int
+	errors   int
+	elapsed  time.Duration
+	p50, p90 time.Duration
+	p99, max time.Duration
+}
+
+// throughput returns the successful operations per second.
+func (s loadgenStats) throughput() float64 {
+	if s.elapsed <= 0 {
+		return 0
+	}
+	return float64(s.ops) / s.elapsed.Seconds()
+}
+
+// runLoadgen runs the loadgen subcommand with its arguments and writes the
+// report to out.
+func runLoadgen(args []string, out io.Writer) error {
+	cfg := loadgenConfig{}
+	fs := flag.NewFlagSet("loadgen", flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.StringVar(&cfg.url, "url", "ws://localhost:8951/", "Router to connect to, ws:// or tcp:// for RawSocket")
+	fs.StringVar(&cfg.realm, "realm", "default", "Realm to join")
+	fs.IntVar(&cfg.clients, "clients", 10, "Number of clients")
+	fs.Float64Var(&cfg.rate, "rate", 1000, "Operations per second across all clients (0 for as fast as possible)")
+	fs.DurationVar(&cfg.duration, "duration", 10*time.Second, "Length of the run")
+	fs.StringVar(&cfg.mode, "mode", "publish", "Operation: publish, acknowledged by the router, or call, to a procedure registered by the load generator")
+	fs.StringVar(&cfg.topic, "topic", "loadgen", "Topic to publish on, or procedure to call")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if cfg.clients <= 0 || cfg.rate < 0 || cfg.duration <= 0 {
+		return fmt.Errorf("loadgen needs a positive -clients and -duration and a non-negative -rate")
+	}
+	if cfg.mode != "publish" && cfg.mode != "call" {
+		return fmt.Errorf("invalid loadgen mode %q, expected publish or call", cfg.mode)
+	}
+	stats, err := loadgen(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%s: %d ok, %d errors in %s, %.1f ops/s\n", cfg.mode, stats.ops, stats.errors, stats.elapsed.Round(time.Millisecond), stats.throughput())
+	fmt.Fprintf(out, "latency: p50 %s, p90 %s, p99 %s, max %s\n", stats.p50, stats.p90, stats.p99, stats.max)
+	return nil
+}
+
+// loadgen connects the clients and runs publishes or calls until the duration
+// is over.  For calls, the first client registers the called procedure.  The
+// latency of a publish is the time until the router acknowledges it.
+func loadgen(ctx context.Context, cfg loadgenConfig) (loadgenStats, error) {
+	quiet := log.New(io.Discard, "", 0)
+	clients := make([]*client.Client, 0, cfg.clients)
+	defer func() {
+		for _, c := range clients {
+			c.Close()
+		}
+	}()
+	for i := 0; i < cfg.clients; i++ {
+		c, err := client.ConnectNet(ctx, cfg.url, client.Config{Realm: cfg.realm, Logger: quiet})
+		if err != nil {
+			return loadgenStats{}, fmt.Errorf("loadgen client %d failed to connect: %s", i, err)
+		}
+		clients = append(clients, c)
+	}
+	if cfg.mode == "call" {
+		echo := func(_ context.Context, inv *wamp.Invocation) client.InvokeResult {
+			return client.InvokeResult{Args: inv.Arguments}
+		}
+		if err := clients[0].Register(cfg.topic, echo, nil); err != nil {
+			return loadgenStats{}, fmt.Errorf("loadgen failed to register %s: %s", cfg.topic, err)
+		}
+	}
+
+	// Each client runs its share of the rate.
+	var interval time.Duration
+	if cfg.rate > 0 {
+		interval = time.Duration(float64(time.Second) * float64(cfg.clients) / cfg.rate)
+	}
+	var mu sync.Mutex
+	var latencies []time.Duration
+	var errors int
+	var wg sync.WaitGroup
+	start := time.Now()
+	end := start.Add(cfg.duration)
+	ack := wamp.Dict{wamp.OptAcknowledge: true}
+	for _, c := range clients {
+		wg.Add(1)
+		go func(c *client.Client) {
+			defer wg.Done()
+			var own []time.Duration
+			failed := 0
+			// Without a rate, next stays at the start and the clock alone
+			// ends the run.
+			for next := time.Now(); next.Before(end) && time.Now().Before(end); next = next.Add(interval) {
+				if interval > 0 {
+					time.Sleep(time.Until(next))
+				}
+				sent := time.Now()
+				var err error
+				if cfg.mode == "call" {
+					callCtx, cancel := context.WithDeadline(ctx, end.Add(time.Second))
+					_, err = c.Call(callCtx, cfg.topic, nil, wamp.List{sent.UnixNano()}, nil, nil)
+					cancel()
+				} else {
+					err = c.Publish(cfg.topic, ack, wamp.List{sent.UnixNano()}, nil)
+				}
+				if err != nil {
+					failed++
+					continue
+				}
+				own = append(own, time.Since(sent))
+			}
+			mu.Lock()
+			latencies = append(latencies, own...)
+			errors += failed
+			mu.Unlock()
+		}(c)
+	}
+	wg.Wait()
+
+	stats := loadgenStats{ops: len(latencies), errors: errors, elapsed: time.Since(start)}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	stats.p50 = percentile(latencies, 0.50)
+	stats.p90 = percentile(latencies, 0.90)
+	stats.p99 = percentile(latencies, 0.99)
+	stats.max = percentile(latencies, 1)
+	return stats, nil
+}
+
+// percentile returns the nearest-rank percentile p of the sorted latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadgen(t *testing.T) {
+	url := startTestRouter(t)
+	for _, mode := range []string{"publish", "call"} {
+		cfg := loadgenConfig{url: url, realm: realm, clients: 3, rate: 300, duration: 200 * time.Millisecond, mode: mode, topic: "test.loadgen." + mode}
+		stats, err := loadgen(context.Background(), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.ops == 0 || stats.errors != 0 || stats.throughput() <= 0 {
+			t.Errorf("%s: expected successful operations, got %+v", mode, stats)
+		}
+		if stats.p50 <= 0 || stats.p50 > stats.p99 || stats.p99 > stats.max {
+			t.Errorf("%s: inconsistent latency percentiles %+v", mode, stats)
+		}
+		// At most the configured rate, give or take the first operation of
+		// each client.
+		if limit := int(cfg.rate*cfg.duration.Seconds()) + cfg.clients; stats.ops > limit {
+			t.Errorf("%s: expected at most %d operations at %.0f/s, got %d", mode, limit, cfg.rate, stats.ops)
+		}
+	}
+}
+
+func TestLoadgenUnlimitedRate(t *testing.T) {
+	url := startTestRouter(t)
+	cfg := loadgenConfig{url: url, realm: realm, clients: 2, duration: 200 * time.Millisecond, mode: "publish", topic: "test.loadgen.unlimited"}
+	done := make(chan struct{})
+	var stats loadgenStats
+	var err error
+	go func() {
+		defer close(done)
+		stats, err = loadgen(context.Background(), cfg)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("loadgen with -rate 0 did not end after its duration")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.ops == 0 || stats.errors != 0 {
+		t.Errorf("expected successful operations, got %+v", stats)
+	}
+}
+
+func TestRunLoadgen(t *testing.T) {
+	url := startTestRouter(t)
+	var out bytes.Buffer
+	if err := runLoadgen([]string{"-url", url, "-realm", realm, "-clients", "2", "-duration", "100ms"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "ops/s") || !strings.Contains(out.String(), "p99") {
+		t.Errorf("unexpected report %q", out.String())
+	}
+	if err := runLoadgen([]string{"-mode", "subscribe"}, &out); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	if p := percentile(sorted, 0.5); p != 5 {
+		t.Errorf("expected p50 of 5, got %d", p)
+	}
+	if p := percentile(sorted, 0.99); p != 10 {
+		t.Errorf("expected p99 of 10, got %d", p)
+	}
+	if p := percentile(nil, 0.5); p != 0 {
+		t.Errorf("expected 0 without latencies, got %d", p)
+	}
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+var (
+	// localMu guards localClient and extraLocalClients, which are replaced
+	// when they reconnect.
+	localMu sync.Mutex
+	// localCallees holds the procedures registered by the local client so
+	// they can be registered again after a reconnect.
+	localCallees = map[string]client.InvocationHandler{}
+	// localSubscribers holds the subscriptions of the local client for the
+	// same purpose.
+	localSubscribers = map[string]localSubscriber{}
+	// extraLocalClients are the local clients of the -local-realm realms.
+	// They register the same procedures as the local client.
+	extraLocalClients = map[wamp.URI]*client.Client{}
+	// reconnectMu makes sure only one reconnect runs at a time.
+	reconnectMu sync.Mutex
+
+	healthMu sync.Mutex
+	// health holds the state of each monitored subsystem, "ok" or the last
+	// failure.
+	health = map[string]string{}
+)
+
+const (
+	minReconnectBackoff = 100 * time.Millisecond
+	maxReconnectBackoff = 30 * time.Second
+	leavePollInterval   = 10 * time.Millisecond
+)
+
+type localSubscriber struct {
+	handler client.EventHandler
+	options wamp.Dict
+}
+
+// getLocalClient returns the current local client.
+func getLocalClient() *client.Client {
+	localMu.Lock()
+	defer localMu.Unlock()
+	return localClient
+}
+
+func connectLocalClient() (*client.Client, error) {
+	return client.ConnectLocal(wsRouter, client.Config{
+		Realm:  realm,
+		Logger: logger,
+	})
+}
+
+// isLocalRealm reports whether a local client is joined to the realm.
+func isLocalRealm(uri wamp.URI) bool {
+	localMu.Lock()
+	defer localMu.Unlock()
+	_, ok := extraLocalClients[uri]
+	return ok || uri == wamp.URI(realm)
+}
+
+// connectExtraLocalClient joins a local client to the realm and registers the
+// procedures of the local client on it.  A client already joined to the realm
+// is replaced.
+func connectExtraLocalClient(uri wamp.URI) error {
+	c, err := client.ConnectLocal(wsRouter, client.Config{Realm: string(uri), Logger: logger})
+	if err != nil {
+		return err
+	}
+	localMu.Lock()
+	old := extraLocalClients[uri]
+	extraLocalClients[uri] = c
+	callees := make(map[string]client.InvocationHandler, len(localCallees))
+	for procedure, callback := range localCallees {
+		callees[procedure] = callback
+	}
+	localMu.Unlock()
+	if old != nil {
+		old.Close()
+		waitSessionLeft(c, old.ID())
+	}
+
+	for procedure, callback := range callees {
+		if err = c.Register(procedure, callback, nil); err != nil {
+			c.Close()
+			return fmt.Errorf("failed to register %q in %s: %s", procedure, uri, err)
+		}
+	}
+	logger.Printf("local client joined %s, registered %d procedures\n", uri, len(callees))
+	return nil
+}
+
+// ensureExtraLocalClients reconnects the extra local clients that have
+// disconnected.
+func ensureExtraLocalClients() {
+	localMu.Lock()
+	var uris []wamp.URI
+	for uri, c := range extraLocalClients {
+		if !c.Connected() {
+			uris = append(uris, uri)
+		}
+	}
+	localMu.Unlock()
+	for _, uri := range uris {
+		logger.Printf("watchdog: local client of %s disconnected, reconnecting\n", uri)
+		err := connectExtraLocalClient(uri)
+		setHealth("local_client."+string(uri), err)
+	}
+}
+
+// closeExtraLocalClients closes the extra local clients.
+func closeExtraLocalClients() {
+	localMu.Lock()
+	defer localMu.Unlock()
+	for _, c := range extraLocalClients {
+		c.Close()
+	}
+}
+
+// createLocalSubscriber subscribes the local client to topic.  The
+// subscription is restored whenever the local client reconnects.
+func createLocalSubscriber(topic string, handler client.EventHandler, options wamp.Dict) error {
+	if err := getLocalClient().Subscribe(topic, handler, options); err != nil {
+		return fmt.Errorf("failed to subscribe %q: %s", topic, err)
+	}
+	localMu.Lock()
+	localSubscribers[topic] = localSubscriber{handler, options}
+	localMu.Unlock()
+	logger.Printf("subscribed: %s\n", topic)
+	return nil
+}
+
+// reconnectLocalClient replaces the local client with a new connection and
+// restores its registrations and subscriptions.  If any of them fails the new
+// client is closed again.
+func reconnectLocalClient() error {
+	c, err := connectLocalClient()
+	if err != nil {
+		return err
+	}
+	localMu.Lock()
+	old := localClient
+	localClient = c
+	callees := make(map[string]client.InvocationHandler, len(localCallees))
+	for procedure, callback := range localCallees {
+		callees[procedure] = callback
+	}
+	subscribers := make(map[string]localSubscriber, len(localSubscribers))
+	for topic, sub := range localSubscribers {
+		subscribers[topic] = sub
+	}
+	localMu.Unlock()
+	old.Close()
+	waitSessionLeft(c, old.ID())
+
+	for procedure, callback := range callees {
+		if err = c.Register(procedure, callback, nil); err != nil {
+			c.Close()
+			return fmt.Errorf("failed to re-register %q: %s", procedure, err)
+		}
+	}
+	for topic, sub := range subscribers {
+		if err = c.Subscribe(topic, sub.handler, sub.options); err != nil {
+			c.Close()
+			return fmt.Errorf("failed to re-subscribe %q: %s", topic, err)
+		}
+	}
+	logger.Printf("local client reconnected, re-registered %d procedures and %d subscriptions\n", len(callees), len(subscribers))
+	return nil
+}
+
+// waitSessionLeft waits up to reregWait for the router to remove the session
+// from the realm of c.  A closed local client gets the GOODBYE reply before
+// its registrations are removed, so registering the same procedures on a new
+// client right away can fail with wamp.error.procedure_already_exists.
+func waitSessionLeft(c *client.Client, sid wamp.ID) {
+	if reregWait <= 0 {
+		return
+	}
+	deadline := time.Now().Add(reregWait)
+	for time.Now().Before(deadline) {
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		_, err := c.Call(ctx, string(wamp.MetaProcSessionGet), nil, wamp.List{sid}, nil, nil)
+		cancel()
+		if errors.Is(translateCallError(err), errCallNotFound) {
+			return
+		}
+		time.Sleep(leavePollInterval)
+	}
+	logger.Printf("session %d still joined after %s, registering anyway\n", sid, reregWait)
+}
+
+// ensureLocalClient reconnects the local client, with exponential backoff
+// between attempts, until it is connected or quit is closed.
+func ensureLocalClient(quit <-chan struct{}) {
+	reconnectMu.Lock()
+	defer reconnectMu.Unlock()
+	backoff := minReconnectBackoff
+	for !getLocalClient().Connected() {
+		select {
+		case <-quit:
+			return
+		default:
+		}
+		err := reconnectLocalClient()
+		setHealth("local_client", err)
+		if err == nil {
+			return
+		}
+		logger.Printf("local client reconnect failed, retrying in %s: %s\n", backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-quit:
+			return
+		}
+		if backoff *= 2; backoff > maxReconnectBackoff {
+			backoff = maxReconnectBackoff
+		}
+	}
+}
+
+// superviseLocalClient reconnects the local client as soon as it disconnects,
+// until quit is closed.
+func superviseLocalClient(quit <-chan struct{}) {
+	for {
+		select {
+		case <-getLocalClient().Done():
+			select {
+			case <-quit:
+				return
+			default:
+			}
+			logger.Println("local client disconnected, reconnecting")
+			setHealth("local_client", errors.New("disconnected"))
+			ensureLocalClient(quit)
+		case <-quit:
+			return
+		}
+	}
+}
+
+func setHealth(subsystem string, err error) {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	if err != nil {
+		health[subsystem] = err.Error()
+	} else {
+		health[subsystem] = "ok"
+	}
+}
+
+func healthStatus() map[string]string {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	status := make(map[string]string, len(health))
+	for k, v := range health {
+		status[k] = v
+	}
+	return status
+}
+
+// publisher is a background goroutine publishing through the local client.
+type publisher struct {
+	name string
+	run  func(quit <-chan struct{})
+	done chan struct{}
+}
+
+var (
+	publishers     []*publisher
+	publishersQuit = make(chan struct{})
+)
+
+// startPublisher runs fn in a goroutine that is restarted by the watchdog if
+// it exits before publishersQuit is closed.
+func startPublisher(name string, fn func(quit <-chan struct{})) {
+	p := &publisher{name: name, run: fn}
+	publishers = append(publishers, p)
+	p.start()
+}
+
+func (p *publisher) start() {
+	p.done = make(chan struct{})
+	go func() {
+		defer close(p.done)
+		defer func() {
+			if r := recover(); r != nil {
+				logPanic("publisher "+p.name, r)
+			}
+		}()
+		p.run(publishersQuit)
+	}()
+}
+
+// watchdog checks the local client and publishers every interval, and
+// re-establishes them when they have stopped.
+func watchdog(interval time.Duration, quit <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !getLocalClient().Connected() {
+				logger.Println("watchdog: local client disconnected, reconnecting")
+				ensureLocalClient(quit)
+			}
+			ensureExtraLocalClients()
+			for _, p := range publishers {
+				select {
+				case <-p.done:
+					logger.Printf("watchdog: publisher %s stopped, restarting\n", p.name)
+					setHealth("publisher."+p.name, fmt.Errorf("restarted at %s", time.Now().Format(time.RFC3339)))
+					p.start()
+				default:
+				}
+			}
+		case <-quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// waitFor polls cond until it is true or the timeout expires.
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) bool {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return cond()
+}
+
+func TestWatchdog(t *testing.T) {
+	url := startTestRouter(t)
+	err := createLocalCallee(getLocalClient(), "test.proc", func(context.Context, *wamp.Invocation) client.InvokeResult {
+		return client.InvokeResult{Args: wamp.List{"pong"}}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan *wamp.Event, 1)
+	err = createLocalSubscriber("test.topic", func(event *wamp.Event) { events <- event }, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var runs int32
+	startPublisher("test", func(quit <-chan struct{}) {
+		if atomic.AddInt32(&runs, 1) == 1 {
+			panic("first run fails")
+		}
+		<-quit
+	})
+
+	old := getLocalClient()
+	old.Close()
+	runUntilEnd(t, func(quit <-chan struct{}) { watchdog(20*time.Millisecond, quit) })
+
+	c := connectTestClient(t, url, realm)
+	if !waitFor(t, 5*time.Second, func() bool {
+		_, err := testCall(c, "test.proc", nil, nil)
+		return err == nil
+	}) {
+		t.Fatal("procedure was not registered again")
+	}
+	if getLocalClient() == old || !getLocalClient().Connected() {
+		t.Error("local client was not replaced")
+	}
+	if err = c.Publish("test.topic", nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-events:
+	case <-time.After(5 * time.Second):
+		t.Error("subscription was not restored")
+	}
+	if !waitFor(t, 5*time.Second, func() bool { return atomic.LoadInt32(&runs) >= 2 }) {
+		t.Error("stopped publisher was not restarted")
+	}
+	if status := healthStatus(); status["local_client"] != "ok" {
+		t.Errorf("expected local client health ok, got %v", status)
+	}
+}
+
+// countingWriter counts the log lines containing a string.
+type countingWriter struct {
+	match string
+	count int32
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	if strings.Contains(string(p), w.match) {
+		atomic.AddInt32(&w.count, 1)
+	}
+	return len(p), nil
+}
+
+func TestSuperviseLocalClient(t *testing.T) {
+	url := startTestRouter(t)
+	err := createLocalCallee(getLocalClient(), "test.proc", func(context.Context, *wamp.Invocation) client.InvokeResult {
+		return client.InvokeResult{}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runUntilEnd(t, superviseLocalClient)
+	getLocalClient().Close()
+
+	c := connectTestClient(t, url, realm)
+	if !waitFor(t, 5*time.Second, func() bool {
+		_, err := testCall(c, "test.proc", nil, nil)
+		return err == nil
+	}) {
+		t.Fatal("local client did not reconnect")
+	}
+}
+
+func TestReregisterAfterReconnect(t *testing.T) {
+	startTestRouter(t)
+	err := createLocalCallee(getLocalClient(), "test.proc", func(context.Context, *wamp.Invocation) client.InvokeResult {
+		return client.InvokeResult{}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		if err = reconnectLocalClient(); err != nil {
+			t.Fatalf("reconnect %d: %s", i, err)
+		}
+	}
+
+	c := getLocalClient()
+	res, err := testCall(c, string(wamp.MetaProcRegLookup), wamp.List{"test.proc"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = testCall(c, string(wamp.MetaProcRegListCallees), wamp.List{res.Arguments[0]}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if callees, _ := wamp.AsList(res.Arguments[0]); len(callees) != 1 || callees[0] != c.ID() {
+		t.Errorf("expected the current local client as only callee, got %v", res.Arguments[0])
+	}
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	savedRealm, savedLogger := realm, logger
+	defer func() { realm, logger = savedRealm, savedLogger }()
+	startTestRouter(t)
+	getLocalClient().Close()
+
+	// Joining a realm that does not exist makes every attempt fail.
+	realm = "missing"
+	w := &countingWriter{match: "reconnect failed"}
+	logger = log.New(w, "", 0)
+	quit := make(chan struct{})
+	time.AfterFunc(3*minReconnectBackoff+minReconnectBackoff/2, func() { close(quit) })
+	ensureLocalClient(quit)
+	// Attempts are made after 0, 1 and 3 times the minimum backoff.
+	if attempts := atomic.LoadInt32(&w.count); attempts != 3 {
+		t.Errorf("expected 3 attempts with exponential backoff, got %d", attempts)
+	}
+	if status := healthStatus(); status["local_client"] == "ok" {
+		t.Errorf("expected failed local client health, got %v", status)
+	}
+
+	realm = savedRealm
+	ensureLocalClient(make(chan struct{}))
+	if !getLocalClient().Connected() {
+		t.Error("local client did not reconnect once the realm is back")
+	}
+}
+
+func TestExtraLocalRealms(t *testing.T) {
+	saved := anonRole
+	defer func() { anonRole = saved }()
+	anonRole = adminRole
+
+	url := startTestRouter(t, "tenant", "closable", "plain")
+	for _, procedure := range []string{"nexus.info", adminPrefix + ".realm.close"} {
+		callback := nexusInfo
+		if procedure != "nexus.info" {
+			callback = adminRealmClose
+		}
+		if err := createLocalCallee(getLocalClient(), procedure, callback); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, uri := range []wamp.URI{"tenant", "closable"} {
+		if err := connectExtraLocalClient(uri); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tenant := connectTestClient(t, url, "tenant")
+	if _, err := testCall(tenant, "nexus.info", nil, nil); err != nil {
+		t.Errorf("nexus.info not available in the local realm: %s", err)
+	}
+	if _, err := testCall(connectTestClient(t, url, "plain"), "nexus.info", nil, nil); errorURI(err) != wamp.ErrNoSuchProcedure {
+		t.Errorf("expected no procedures in a realm without local client, got %v", err)
+	}
+	_, err := testCall(tenant, adminPrefix+".realm.close", wamp.List{"closable"}, nil)
+	if errorURI(err) != wamp.ErrInvalidArgument {
+		t.Errorf("expected local realms to be protected from closing, got %v", err)
+	}
+	if _, err = testCall(tenant, adminPrefix+".realm.close", wamp.List{"plain"}, nil); err != nil {
+		t.Errorf("admin procedure failed in the local realm: %s", err)
+	}
+
+	// The watchdog restores a disconnected extra local client.
+	localMu.Lock()
+	extraLocalClients["tenant"].Close()
+	localMu.Unlock()
+	runUntilEnd(t, func(quit <-chan struct{}) { watchdog(20*time.Millisecond, quit) })
+	if !waitFor(t, 5*time.Second, func() bool {
+		_, err := testCall(tenant, "nexus.info", nil, nil)
+		return err == nil
+	}) {
+		t.Error("extra local client was not reconnected")
+	}
+}
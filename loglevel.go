@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// Log levels set with -log-level.  Each level logs everything the levels
+// before it do.
+const (
+	levelInfo = iota
+	// levelDebug logs the type and URI of every message from remote
+	// sessions.
+	levelDebug
+	// levelTrace also logs the serialized payload of those messages.
+	levelTrace
+)
+
+var logLevels = map[string]int32{"info": levelInfo, "debug": levelDebug, "trace": levelTrace}
+
+// logLevelID is the level selected with -log-level or the loglevel admin
+// procedure.
+var logLevelID atomic.Int32
+
+// traceRedacted holds the dict keys whose values are replaced in traced
+// payloads, from -trace-redact.
+var traceRedacted = map[string]bool{}
+
+// parseLogLevel sets logLevelID and traceRedacted from the -log-level and
+// -trace-redact flags.
+func parseLogLevel(level, redact string) error {
+	id, ok := logLevels[level]
+	if !ok {
+		return fmt.Errorf("unknown log level %q, expected info, debug or trace", level)
+	}
+	logLevelID.Store(id)
+	traceRedacted = map[string]bool{}
+	for _, key := range strings.Split(redact, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			traceRedacted[key] = true
+		}
+	}
+	return nil
+}
+
+// logMessage logs a message from a remote session at debug level, with its
+// payload at trace level.
+func logMessage(sess *wamp.Session, msg wamp.Message) {
+	level := logLevelID.Load()
+	if level < levelDebug {
+		return
+	}
+	uri, _ := messageURI(msg)
+	if level < levelTrace {
+		logger.Printf("debug: session %d sent %s %s\n", sess.ID, msg.MessageType(), uri)
+		return
+	}
+	args, kwargs, ok := messagePayload(msg)
+	if !ok {
+		logger.Printf("trace: session %d sent %s %s\n", sess.ID, msg.MessageType(), uri)
+		return
+	}
+	logger.Printf("trace: session %d sent %s %s %s\n", sess.ID, msg.MessageType(), uri, formatPayload(args, kwargs))
+}
+
+// formatPayload returns the redacted args and kwargs as JSON, cut after
+// traceMaxLen bytes.
+func formatPayload(args wamp.List, kwargs wamp.Dict) []byte {
+	payload, err := json.Marshal(wamp.Dict{"args": redactPayload(args), "kwargs": redactPayload(kwargs)})
+	if err != nil {
+		payload = []byte(fmt.Sprintf("unserializable payload: %s", err))
+	}
+	if traceMaxLen > 0 && len(payload) > traceMaxLen {
+		payload = append(payload[:traceMaxLen:traceMaxLen], fmt.Sprintf("... (%d bytes)", len(payload))...)
+	}
+	return payload
+}
+
+// redactPayload returns a copy of v with the values of the traceRedacted keys
+// of any nested dict replaced.
+func redactPayload(v interface{}) interface{} {
+	if list, ok := wamp.AsList(v); ok {
+		out := make(wamp.List, len(list))
+		for i, item := range list {
+			out[i] = redactPayload(item)
+		}
+		return out
+	}
+	if dict, ok := wamp.AsDict(v); ok {
+		out := make(wamp.Dict, len(dict))
+		for k, item := range dict {
+			if traceRedacted[k] {
+				out[k] = "[redacted]"
+			} else {
+				out[k] = redactPayload(item)
+			}
+		}
+		return out
+	}
+	return v
+}
+
+// logLevelName returns the name of a log level.
+func logLevelName(id int32) string {
+	for name, level := range logLevels {
+		if level == id {
+			return name
+		}
+	}
+	return fmt.Sprint(id)
+}
+
+// adminLogLevel handles <admin-prefix>.loglevel.  It sets the log level to its
+// level name argument and returns the previous and the new level.
+func adminLogLevel(ctx context.Context, inv *wamp.Invocation) client.InvokeResult {
+	if len(inv.Arguments) == 0 {
+		return client.InvokeResult{Err: wamp.ErrInvalidArgument, Args: wamp.List{"missing log level argument"}}
+	}
+	name, _ := wamp.AsString(inv.Arguments[0])
+	id, ok := logLevels[name]
+	if !ok {
+		return client.InvokeResult{Err: wamp.ErrInvalidArgument, Args: wamp.List{fmt.Sprintf("unknown log level %q, expected info, debug or trace", name)}}
+	}
+	previous := logLevelName(logLevelID.Swap(id))
+	if previous != name {
+		logger.Printf("log level set to %s\n", name)
+	}
+	return client.InvokeResult{Args: wamp.List{previous, name}}
+}
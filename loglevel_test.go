@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestLogLevel(t *testing.T) {
+	savedLogger, savedMax := logger, traceMaxLen
+	defer func() {
+		logger, traceMaxLen = savedLogger, savedMax
+		parseLogLevel("info", "")
+	}()
+	var buf bytes.Buffer
+	logger = log.New(&buf, "", 0)
+	sess := &wamp.Session{ID: 42}
+	pub := &wamp.Publish{
+		Topic:       "test.topic",
+		Arguments:   wamp.List{"hello", wamp.Dict{"password": "hunter2"}},
+		ArgumentsKw: wamp.Dict{"email": "user@example.com"},
+	}
+
+	if err := parseLogLevel("debug", "password"); err != nil {
+		t.Fatal(err)
+	}
+	logMessage(sess, pub)
+	if out := buf.String(); !strings.Contains(out, "test.topic") || strings.Contains(out, "hello") {
+		t.Errorf("expected the topic without the payload at debug level, got %q", out)
+	}
+
+	buf.Reset()
+	if err := parseLogLevel("trace", "password"); err != nil {
+		t.Fatal(err)
+	}
+	logMessage(sess, pub)
+	out := buf.String()
+	if !strings.Contains(out, "hello") || !strings.Contains(out, "user@example.com") {
+		t.Errorf("expected the payload at trace level, got %q", out)
+	}
+	if strings.Contains(out, "hunter2") || !strings.Contains(out, "[redacted]") {
+		t.Errorf("expected the password to be redacted, got %q", out)
+	}
+
+	buf.Reset()
+	traceMaxLen = 10
+	logMessage(sess, pub)
+	if out := buf.String(); strings.Contains(out, "user@example.com") || !strings.Contains(out, "bytes)") {
+		t.Errorf("expected a truncated payload, got %q", out)
+	}
+
+	buf.Reset()
+	if err := parseLogLevel("info", ""); err != nil {
+		t.Fatal(err)
+	}
+	logMessage(sess, pub)
+	if buf.Len() != 0 {
+		t.Errorf("expected nothing logged at info level, got %q", buf.String())
+	}
+	if err := parseLogLevel("verbose", ""); err == nil {
+		t.Error("expected an error for an unknown log level")
+	}
+}
+
+func TestAdminLogLevel(t *testing.T) {
+	savedLogger, savedRole := logger, anonRole
+	defer func() {
+		logger, anonRole = savedLogger, savedRole
+		parseLogLevel("info", "")
+	}()
+	anonRole = adminRole
+
+	url := startTestRouter(t)
+	if err := createLocalCallee(getLocalClient(), adminPrefix+".loglevel", adminLogLevel); err != nil {
+		t.Fatal(err)
+	}
+	logged := &countingWriter{match: "sent CALL wamp.session.count"}
+	logger = log.New(logged, "", 0)
+	c := connectTestClient(t, url, realm)
+	countCall := func() int32 {
+		atomic.StoreInt32(&logged.count, 0)
+		if _, err := testCall(c, string(wamp.MetaProcSessionCount), nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		return atomic.LoadInt32(&logged.count)
+	}
+
+	if n := countCall(); n != 0 {
+		t.Errorf("expected nothing logged at info level, got %d lines", n)
+	}
+	res, err := testCall(c, adminPrefix+".loglevel", wamp.List{"debug"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Arguments) != 2 || res.Arguments[0] != "info" || res.Arguments[1] != "debug" {
+		t.Errorf("expected previous and new level, got %v", res.Arguments)
+	}
+	if n := countCall(); n != 1 {
+		t.Errorf("expected the call logged at debug level, got %d lines", n)
+	}
+	if _, err = testCall(c, adminPrefix+".loglevel", wamp.List{"info"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := countCall(); n != 0 {
+		t.Errorf("expected nothing logged after going back to info, got %d lines", n)
+	}
+	if _, err = testCall(c, adminPrefix+".loglevel", wamp.List{"verbose"}, nil); err == nil {
+		t.Error("expected an error for an unknown log level")
+	}
+}
@@ -4,19 +4,33 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gammazero/nexus/v3/client"
 	"github.com/gammazero/nexus/v3/router"
+	"github.com/gammazero/nexus/v3/router/auth"
 	"github.com/gammazero/nexus/v3/wamp"
+	"github.com/gorilla/websocket"
 )
 
 var (
 	realm       = "default"
+	extraRealms stringList
+	localRealms stringList
+	tapTopics   stringList
+	rsListens   stringList
+	wsHeaders   stringList
+	adminRole   = "admin"
+	adminPrefix = "nexus.admin"
 	wsEnable    = true
 	wsHost      = "localhost"
 	wsPort      = 8951
@@ -24,132 +38,941 @@ var (
 	rsHost      = "127.0.0.1"
 	rsPort      = 8952
 	rsProto     = "tcp"
+	wsRouter    router.Router
 	localClient *client.Client
 	logger      *log.Logger
 	devEcho     = false
 	devTime     = false
+	devWildcard = false
+	devPrefix   = "dev"
+	proxyConfig = ""
+	errorMap    = ""
+	statsTopic  = ""
+	statsEvery  = 10 * time.Second
+	countConns  = false
+	anonAuthID  = ""
+	anonRole    = "anonymous"
+	trustedCfg  = ""
+	disableFile = ""
+	userHeader  = "X-Auth-User"
+	roleHeader  = "X-Auth-Role"
+	uriPrefix   = ""
+	wsKeepAlive = 30 * time.Second
+	wsCompress  = true
+	rsKeepAlive = 30 * time.Second
+	rsMaxLenExp = 15
+	rsAccRate   = 0
+	watchEvery  = 10 * time.Second
+	maxSessions = 0
+	retryAfter  = 5 * time.Second
+	warnMsgSize = 0
+	stopTimeout = 10 * time.Second
+	topicLimits = ""
+	serializers = "json,msgpack,cbor"
+	dumpFile    = "nexus-simple-router.dump"
+	wsWritePool = false
+	wsSerParam  = ""
+	debugLogLen = 0
+	debugArgs   = false
+	reregWait   = 5 * time.Second
+	diagTopic   = ""
+	maxSubs     = 0
+	maxRegs     = 0
+	wsHandshake = 10 * time.Second
+	failOnDev   = false
+	normRealms  = false
+	callLimits  = ""
+	strictURI   = false
+	maintenance = false
+	logLevel    = "info"
+	traceMaxLen = 1024
+	traceRedact = ""
+	agent       = ""
+	welcomeInfo = ""
+	realmQuotas = ""
+	quotaWindow = time.Minute
+	sessRegFile = ""
+	devTimeAll  = false
+	dtimeFormat = timeRFC3339
+	discloseCfg = ""
+	matchCfg    = ""
+	prefixCfg   = ""
+	onJoinProc  = ""
+	onJoinTopic = ""
+	panicExit   = false
+	whoamiOn    = false
+	startPaused = false
+	countBytes  = false
+	serMaxSizes = ""
+	reqFeatures = ""
+	publishBuf  = 64
+	logHsErrors = false
+	selfCheckIv = time.Duration(0)
+	authxKeep   = "*"
+	authxDeny   = ""
+	maxLifetime = time.Duration(0)
+	countFanout = false
+	ticketFile  = ""
+	lifeStdout  = false
+	lifeTopic   = ""
+	dedupTopics = ""
+	dedupWindow = time.Second
+	callAllowed = ""
+	reconTopic  = ""
+	reconWindow = 5 * time.Second
+	countAcks   = false
+	logAcks     = false
+	extAuthSock = ""
+	extAuthWait = 2 * time.Second
+	metaRoleCfg = ""
+	uniqueMode  = ""
+	abortReason = false
+	dashEnable  = false
+	dashPath    = "/dashboard/"
+	dashAuth    = ""
+	adminPage   = 100
+	realmSerCfg = ""
+	httpProxy   = ""
+	httpCAFile  = ""
+	skipVerify  = false
+	topoEvery   = time.Duration(0)
+	topoDepth   = 2
+	agentAllow  = ""
+	agentDeny   = ""
+	logAgents   = false
+	strictPaths = false
+	dashFile    = ""
 )
 
+// welcomeExtra holds the -welcome-details added to every WELCOME.
+var welcomeExtra = wamp.Dict{}
+
+// subsLimit and regsLimit cap the subscriptions and registrations of each
+// session when -max-subs-per-session and -max-regs-per-session are set.
+var (
+	subsLimit *sessionLimit
+	regsLimit *sessionLimit
+)
+
+// wsBufferPool is shared by all WebSocket connections when -ws-write-pool is
+// set, so idle connections do not each hold a write buffer.
+var wsBufferPool websocket.BufferPool = &sync.Pool{}
+
 func main() {
+	// The load generator is a separate subcommand, with its own flags.
+	if len(os.Args) > 1 && os.Args[1] == "loadgen" {
+		if err := runLoadgen(os.Args[2:], os.Stdout); err == flag.ErrHelp {
+			os.Exit(0)
+		} else if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	flag.StringVar(&realm, realm, "default", "Realm to be created")
+	flag.StringVar(&realm, "realm", realm, "Realm to be created")
+	flag.Var(&extraRealms, "add-realm", "Additional realm to be created, as uri or uri=prefix to restrict it to a URI prefix (repeatable)")
+	flag.Var(&tapTopics, "tap", "Topic of the default realm whose events the local client logs, with their payload (repeatable)")
+	flag.Var(&localRealms, "local-realm", "Additional realm to join with a local client providing the router procedures (repeatable)")
+	flag.StringVar(&metaRoleCfg, "meta-roles", metaRoleCfg, "Comma separated authroles allowed to call wamp.* meta procedures and subscribe to meta events, checked before any other rule (empty for all roles)")
+	flag.StringVar(&adminRole, "admin-role", adminRole, "Authrole allowed to call admin procedures, sessions get it with -anon-authrole, trusted headers, a -ticket-file entry or the -external-auth-socket co-process")
+	flag.StringVar(&adminPrefix, "admin-prefix", adminPrefix, "URI prefix of the admin procedures")
+	flag.IntVar(&adminPage, "admin-page-size", adminPage, "Largest page of the admin list procedures, smaller limits can be asked for")
 	flag.BoolVar(&wsEnable, "ws", wsEnable, "Should WebSocket transport be started")
-	flag.StringVar(&wsHost, "ws-host", wsHost, "WebSocket host to listen on")
+	flag.StringVar(&wsHost, "ws-host", wsHost, "WebSocket host to listen on, or unix:/path/to.sock for a Unix socket")
 	flag.IntVar(&wsPort, "ws-port", wsPort, "WebSocket port to listen on")
 	flag.BoolVar(&rsEnable, "rs", rsEnable, "Should RawSocket transport be started")
 	flag.StringVar(&rsHost, "rs-host", rsHost, "RawSocket host to listen on")
 	flag.IntVar(&rsPort, "rs-port", rsPort, "RawSocket port to listen on")
 	flag.StringVar(&rsProto, "rs-proto", rsProto, "RawSocket protocol (tcp,tcp4,tcp6,unix,unixpacket)")
-	flag.BoolVar(&devEcho, "decho", devEcho, "Should dev.echo RPC be registered")
-	flag.BoolVar(&devTime, "dtime", devTime, "Should the time be regularly published on dev.time")
-	flag.Parse()
+	flag.Var(&rsListens, "rs-listen", "Additional RawSocket listener as network:address, e.g. unix:/run/nexus.sock (repeatable)")
+	flag.BoolVar(&devEcho, "decho", devEcho, "Should <dev-prefix>.echo RPC be registered")
+	flag.BoolVar(&devTime, "dtime", devTime, "Should the time be regularly published on <dev-prefix>.time")
+	flag.BoolVar(&devWildcard, "dwildcard", devWildcard, "Should events be regularly published on hierarchical <dev-prefix>.wildcard.<group>.tick topics")
+	flag.StringVar(&devPrefix, "dev-prefix", devPrefix, "URI prefix of the development procedures and topics")
+	flag.StringVar(&proxyConfig, "proxy-config", proxyConfig, "JSON file mapping procedures to HTTP endpoints")
+	flag.StringVar(&httpProxy, "http-proxy", httpProxy, "URL of the proxy the -proxy-config requests go through, except for hosts in the NO_PROXY environment variable (defaults to the HTTP_PROXY and HTTPS_PROXY environment variables)")
+	flag.StringVar(&httpCAFile, "http-ca-file", httpCAFile, "PEM file of CA certificates trusted for the -proxy-config requests, in addition to the system ones")
+	flag.BoolVar(&skipVerify, "http-insecure-skip-verify", skipVerify, "Do not verify the TLS certificates of the -proxy-config endpoints and proxy")
+	flag.StringVar(&errorMap, "error-map", errorMap, "Comma separated uri=status pairs setting the WAMP error returned for an HTTP status by proxy procedures")
+	flag.StringVar(&statsTopic, "stats-topic", statsTopic, "Topic in the default realm to periodically publish router stats on (empty to disable)")
+	flag.DurationVar(&statsEvery, "stats-interval", statsEvery, "Interval between router stats publications")
+	flag.BoolVar(&countConns, "count-connections", countConns, "Should the connections attached to the router be counted by transport and serializer, published as connections in the stats")
+	flag.DurationVar(&topoEvery, "topology-interval", topoEvery, "Interval between logs of the subscriptions, subscribers and registrations of each realm per URI prefix, also added to the stats (0 to disable)")
+	flag.IntVar(&topoDepth, "topology-depth", topoDepth, "Number of URI components of the prefixes -topology-interval counts per, e.g. 2 for app.chat")
+	flag.IntVar(&publishBuf, "publish-buffer", publishBuf, "Number of publishes of the dev, stats and diagnostics publishers queued for the local client, further ones are dropped while the router is congested")
+	flag.StringVar(&anonAuthID, "anon-authid", anonAuthID, "Authid assigned to anonymous sessions (empty for a unique one per session)")
+	flag.StringVar(&anonRole, "anon-authrole", anonRole, "Authrole assigned to anonymous sessions")
+	flag.StringVar(&extAuthSock, "external-auth-socket", extAuthSock, "Unix socket of a co-process deciding on sessions asking for the external auth method, from their HELLO details, alongside anonymous ones (empty to disable)")
+	flag.DurationVar(&extAuthWait, "external-auth-timeout", extAuthWait, "Time the -external-auth-socket co-process has to answer, sessions are rejected after it")
+	flag.BoolVar(&dashEnable, "dashboard", dashEnable, "Serve the admin dashboard on the WebSocket listener, requires -dashboard-auth or -dashboard-auth-file")
+	flag.StringVar(&dashPath, "dashboard-path", dashPath, "Path of the -dashboard on the WebSocket listener, starting and ending with /")
+	flag.StringVar(&dashAuth, "dashboard-auth", dashAuth, "Basic auth credentials of the -dashboard, as user:password, shown in the process list unlike -dashboard-auth-file")
+	flag.StringVar(&dashFile, "dashboard-auth-file", dashFile, "File holding the -dashboard-auth credentials, as user:password")
+	flag.BoolVar(&abortReason, "abort-reasons", abortReason, "Refuse joins over the session limit, of an authid in use, missing a required client feature, with a serializer the realm does not allow or from a client agent that is not allowed with nexus.error.session_limit, nexus.error.authid_in_use, nexus.error.missing_client_feature, nexus.error.serializer_not_allowed or nexus.error.agent_not_allowed instead of wamp.error.authentication_failed")
+	flag.StringVar(&uniqueMode, "unique-authid", uniqueMode, "Keep one session per authid in each realm for sessions that are not anonymous: reject-new fails the join of another session, kick-old kills the previous one (empty to allow several)")
+	flag.StringVar(&ticketFile, "ticket-file", ticketFile, "JSON file mapping authids to their ticket and role, e.g. {\"alice\": {\"ticket\": \"secret\", \"role\": \"user\"}}, authenticating sessions asking for the ticket method alongside anonymous ones (empty to disable)")
+	flag.StringVar(&trustedCfg, "trusted-proxies", trustedCfg, "Comma separated IP addresses and CIDR ranges of reverse proxies whose identity headers authenticate WebSocket sessions with the trusted-header method (empty to disable)")
+	flag.StringVar(&userHeader, "auth-user-header", userHeader, "Header carrying the authid set by a trusted proxy")
+	flag.StringVar(&roleHeader, "auth-role-header", roleHeader, "Header carrying the authrole set by a trusted proxy")
+	flag.StringVar(&uriPrefix, "uri-prefix", uriPrefix, "Restrict the default realm's topics and procedures to this URI prefix")
+	flag.DurationVar(&wsKeepAlive, "ws-keepalive", wsKeepAlive, "Interval between WebSocket pings, the connection is closed after 2 intervals without a pong (0 to disable)")
+	flag.BoolVar(&wsCompress, "ws-compression", wsCompress, "Should WebSocket per-message deflate be negotiated")
+	flag.DurationVar(&rsKeepAlive, "rs-keepalive", rsKeepAlive, "TCP keepalive period for RawSocket connections (0 to disable)")
+	flag.IntVar(&rsAccRate, "rs-accept-rate", rsAccRate, "Maximum RawSocket connections joining per second, further ones wait their turn (0 for no limit)")
+	flag.IntVar(&rsMaxLenExp, "rs-max-length-exp", rsMaxLenExp, "RawSocket max message length exponent, the limit is 2^(9+exp) bytes (0-15), the only message size limit of the router")
+	flag.DurationVar(&selfCheckIv, "self-check-interval", selfCheckIv, "Interval between calls of the local client to itself through the router, reported as self_check in the health status (0 to disable)")
+	flag.DurationVar(&watchEvery, "watchdog-interval", watchEvery, "Interval between local client and publisher health checks (0 to disable)")
+	flag.DurationVar(&maxLifetime, "max-session-lifetime", maxLifetime, "Time after which remote sessions are killed with the nexus.close.reauthenticate reason whatever their activity, so clients join again (0 for no limit)")
+	flag.IntVar(&maxSessions, "max-sessions", maxSessions, "Maximum number of remote sessions per realm, further joins are aborted (0 for no limit)")
+	flag.DurationVar(&retryAfter, "overload-retry-after", retryAfter, "Retry hint included in the abort message of joins refused by -max-sessions")
+	flag.IntVar(&warnMsgSize, "warn-msg-size", warnMsgSize, "Log a rate limited warning for messages larger than this many bytes, encoded as JSON (0 to disable)")
+	flag.DurationVar(&stopTimeout, "shutdown-timeout", stopTimeout, "Maximum time each shutdown step may take")
+	flag.StringVar(&callAllowed, "call-allowlist", callAllowed, "Comma separated procedure=authid+authid entries restricting who may call the procedures, e.g. svc.payroll=alice+bob (procedures default to any authid)")
+	flag.StringVar(&dedupTopics, "dedup-topics", dedupTopics, "Comma separated topics whose publishes from remote sessions are dropped when identical to another within -dedup-window")
+	flag.DurationVar(&dedupWindow, "dedup-window", dedupWindow, "Window within which identical publishes to -dedup-topics are dropped")
+	flag.StringVar(&topicLimits, "topic-size-limits", topicLimits, "Comma separated topic=bytes pairs limiting the publish payload size of topics")
+	flag.StringVar(&serMaxSizes, "serializer-max-sizes", serMaxSizes, "Comma separated serializer=bytes pairs limiting the size of calls and publishes of WebSocket sessions using the serializer, e.g. json=65536,msgpack=1048576")
+	flag.StringVar(&serializers, "serializer-preference", serializers, "Comma separated WebSocket serializer preference, the first one also offered by the client is used")
+	flag.StringVar(&dumpFile, "dump-file", dumpFile, "File the diagnostic snapshot is written to on SIGQUIT before exiting")
+	flag.BoolVar(&wsWritePool, "ws-write-pool", wsWritePool, "Should WebSocket connections share a pool of write buffers instead of holding one each")
+	flag.Var(&wsHeaders, "ws-header", "Header added to WebSocket responses as Name: value, e.g. X-Frame-Options: DENY (repeatable)")
+	flag.StringVar(&wsSerParam, "ws-serializer-param", wsSerParam, "Query parameter choosing the serializer of WebSocket clients that send no subprotocol, e.g. serializer for ?serializer=msgpack (empty to disable)")
+	flag.BoolVar(&strictPaths, "ws-strict-paths", strictPaths, "Answer 404 to HTTP requests on the WebSocket listener whose path has duplicate slashes, dot segments or a wrong trailing slash, instead of redirecting them to the canonical path")
+	flag.BoolVar(&countAcks, "count-publish-acks", countAcks, "Should the replies to acknowledged publishes of remote sessions be timed, publishing a latency histogram and the failures in the stats")
+	flag.BoolVar(&logAcks, "log-publish-acks", logAcks, "Should each reply to an acknowledged publish of a remote session be logged with its latency")
+	flag.BoolVar(&countFanout, "count-fanout", countFanout, "Should the events delivered to remote sessions be counted, publishing a histogram of the subscribers each publication reaches and the failed deliveries in the stats")
+	flag.BoolVar(&countBytes, "count-bytes", countBytes, "Should the bytes of WebSocket sessions on TCP be counted, returned by the sessions.get admin procedure and published per realm in the stats")
+	flag.IntVar(&debugLogLen, "debug-log-size", debugLogLen, "Number of recent messages from remote sessions kept for the debuglog admin procedure (0 to disable)")
+	flag.BoolVar(&debugArgs, "debug-log-payloads", debugArgs, "Should the debug log keep message arguments, which may be sensitive")
+	flag.DurationVar(&reregWait, "reregister-wait", reregWait, "Maximum time a reconnecting local client waits for the registrations of its old session to be removed before registering again (0 to not wait)")
+	flag.BoolVar(&logHsErrors, "log-handshake-errors", logHsErrors, "Should failed WebSocket upgrades and RawSocket handshakes be logged below debug level, they are counted either way")
+	flag.StringVar(&diagTopic, "diag-topic", diagTopic, "Topic in the default realm to publish transport handshake, join and protocol errors on (empty to disable)")
+	flag.IntVar(&maxSubs, "max-subs-per-session", maxSubs, "Maximum number of subscriptions of each remote session, further subscribes fail (0 for no limit)")
+	flag.IntVar(&maxRegs, "max-regs-per-session", maxRegs, "Maximum number of registrations of each remote session, further registers fail (0 for no limit)")
+	flag.DurationVar(&wsHandshake, "ws-handshake-timeout", wsHandshake, "Maximum time for reading a WebSocket upgrade request and writing its response (0 for no limit)")
+	flag.BoolVar(&failOnDev, "fail-on-dev-error", failOnDev, "Should the router exit when a development procedure cannot be registered, instead of logging it")
+	flag.BoolVar(&normRealms, "normalize-realms", normRealms, "Should realm URIs be lowercased and trimmed of dots, in the flags and when clients join")
+	flag.StringVar(&callLimits, "call-timeouts", callLimits, "Comma separated procedure=duration pairs limiting how long calls to a procedure may run")
+	flag.BoolVar(&strictURI, "strict-uri", strictURI, "Should topics and procedures be restricted to lowercase letters, digits and underscores, instead of any characters but whitespace, # and dots")
+	flag.StringVar(&disableFile, "disabled-file", disableFile, "JSON file keeping the topics and procedures disabled with the admin procedures across restarts (empty to keep them in memory)")
+	flag.BoolVar(&startPaused, "accept-paused", startPaused, "Should the router start refusing new remote connections until the accept.resume admin procedure is called")
+	flag.BoolVar(&maintenance, "maintenance", maintenance, "Should the router start in maintenance mode, rejecting publishes and registrations of remote sessions")
+	flag.StringVar(&logLevel, "log-level", logLevel, "Log level: info, debug to log every message from remote sessions, or trace to also log their payloads, which may be sensitive")
+	flag.IntVar(&traceMaxLen, "trace-max-size", traceMaxLen, "Maximum number of payload bytes logged per message at trace level or by -tap (0 for no limit)")
+	flag.StringVar(&traceRedact, "trace-redact", traceRedact, "Comma separated dict keys whose values are redacted in payloads logged at trace level or by -tap")
+	flag.StringVar(&reqFeatures, "required-client-features", reqFeatures, "Comma separated client roles and role features that clients must advertise in their HELLO to join, e.g. caller,callee.call_canceling")
+	flag.StringVar(&agentAllow, "client-agent-allow", agentAllow, "Comma separated patterns of the only client agents, from the agent of the HELLO, allowed to join, * matching any characters, e.g. autobahn-js/* (empty for any agent)")
+	flag.StringVar(&agentDeny, "client-agent-deny", agentDeny, "Comma separated patterns of client agents refused to join, checked before -client-agent-allow, e.g. myapp/1.*")
+	flag.BoolVar(&logAgents, "log-client-agents", logAgents, "Should the client agent of each remote session be logged when it joins")
+	flag.StringVar(&authxKeep, "authextra-keys", authxKeep, "Comma separated authextra keys of the HELLO kept in session details and the meta API, * for all (empty to strip authextra)")
+	flag.StringVar(&authxDeny, "authextra-deny", authxDeny, "Comma separated authextra keys always stripped from session details, e.g. password,token")
+	flag.StringVar(&agent, "agent", agent, "Agent string advertised in the WELCOME (empty for nexus-simple-router/<version>)")
+	flag.StringVar(&welcomeInfo, "welcome-details", welcomeInfo, "Comma separated key=value string details added to the WELCOME")
+	flag.StringVar(&realmQuotas, "realm-quotas", realmQuotas, "Comma separated realm=calls:publishes entries limiting the calls and publishes of remote sessions per -quota-window (0 for no limit)")
+	flag.DurationVar(&quotaWindow, "quota-window", quotaWindow, "Window after which the -realm-quotas counts reset")
+	flag.BoolVar(&lifeStdout, "lifecycle-stdout", lifeStdout, "Should lifecycle events (starting, listening, ready, draining, stopped) be written to stdout as JSON lines")
+	flag.StringVar(&lifeTopic, "lifecycle-topic", lifeTopic, "Topic the lifecycle events from listening to draining are published on (empty to disable)")
+	flag.StringVar(&sessRegFile, "session-registry-file", sessRegFile, "File the remote sessions are recorded in on graceful shutdown, returned by the registry admin procedure (empty to disable)")
+	flag.StringVar(&dtimeFormat, "dtime-format", dtimeFormat, "Format of the times published on <dev-prefix>.time and in the stats: rfc3339, unix, unixmilli or dict")
+	flag.BoolVar(&devTimeAll, "dev-time-always", devTimeAll, "Should the dev.time publisher publish even when the topic has no subscribers")
+	flag.StringVar(&onJoinProc, "on-join-proc", onJoinProc, "Procedure called with the details of each remote session joining the default realm, its result is the -on-join-topic event (empty to disable)")
+	flag.StringVar(&reconTopic, "reconnect-topic", reconTopic, "Topic of the joined, reconnected and left events of the remote sessions of the default realm, correlating reconnects by authid (empty to disable)")
+	flag.DurationVar(&reconWindow, "reconnect-window", reconWindow, "Time a session that left is held back from -reconnect-topic as a session of its authid joining within it is reported as reconnected")
+	flag.StringVar(&onJoinTopic, "on-join-topic", onJoinTopic, "Topic of a welcome event sent to each remote session of the default realm once it subscribes to it (empty to disable)")
+	flag.StringVar(&prefixCfg, "realm-prefix-overlap", prefixCfg, "Comma separated realm=policy pairs for overlapping prefix registrations: most-specific routes calls to the longest prefix, reject refuses overlapping registrations (realms default to most-specific)")
+	flag.StringVar(&matchCfg, "realm-match-policies", matchCfg, "Comma separated realm=policy+policy entries restricting the match policies of subscriptions and registrations, e.g. tenant=exact+prefix (realms default to all)")
+	flag.StringVar(&realmSerCfg, "realm-serializers", realmSerCfg, "Comma separated realm=serializer+serializer entries restricting the serializers of WebSocket sessions joining the realm, e.g. backend=msgpack+cbor (realms default to all)")
+	flag.StringVar(&discloseCfg, "realm-disclosure", discloseCfg, "Comma separated realm=mode pairs: forbid rejects identity disclosure, allow honors requests for it, force discloses every caller and publisher (realms default to allow)")
+	flag.BoolVar(&whoamiOn, "whoami", whoamiOn, "Should nexus.whoami be registered, returning the authid, authrole and permissions of the calling session")
+	flag.BoolVar(&panicExit, "panic-exit", panicExit, "Should a panic of a router procedure or publisher shut the router down and exit with status 1, e.g. for a supervisor to restart it, instead of failing the call or restarting the publisher")
+	// Parse errors are reported by parseFlags, with a suggestion for
+	// mistyped flags.
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	if err := parseFlags(flag.CommandLine, os.Args[1:]); err == flag.ErrHelp {
+		os.Exit(0)
+	} else if err != nil {
+		os.Exit(2)
+	}
+	if err := validateFlags(flag.CommandLine); err != nil {
+		panic(err)
+	}
 
-	if !wsEnable && !rsEnable {
-		panic("one of WebSocket (-ws) or RawSocket (-rs) transports must be enabled")
+	if !wamp.URI(adminPrefix).ValidURI(false, "") {
+		panic(fmt.Sprintf("invalid admin prefix (-admin-prefix) %q", adminPrefix))
+	}
+	if !wamp.URI(devPrefix).ValidURI(false, "") {
+		panic(fmt.Sprintf("invalid dev prefix (-dev-prefix) %q", devPrefix))
 	}
 
-	wsAddr := fmt.Sprintf("%s:%d", wsHost, wsPort)
-	rsAddr := fmt.Sprintf("%s:%d", rsHost, rsPort)
+	prefixes := []string{uriPrefix}
+	for _, s := range extraRealms {
+		_, prefix, _ := strings.Cut(s, "=")
+		prefixes = append(prefixes, prefix)
+	}
+	for _, prefix := range prefixes {
+		if prefix != "" && !wamp.URI(prefix).ValidURI(false, "") {
+			panic(fmt.Sprintf("invalid realm URI prefix %q", prefix))
+		}
+	}
+
+	var rsListeners []rawSocketListener
+	for _, s := range rsListens {
+		l, err := parseRawSocketListener(s)
+		if err != nil {
+			panic(err)
+		}
+		rsListeners = append(rsListeners, l)
+	}
 
-	logger = log.New(os.Stdout, "", log.LstdFlags)
+	if rsAccRate < 0 {
+		panic(fmt.Sprintf("RawSocket accept rate (-rs-accept-rate) cannot be negative, got %d", rsAccRate))
+	}
+	if publishBuf < 1 {
+		panic(fmt.Sprintf("publish buffer (-publish-buffer) must be at least 1, got %d", publishBuf))
+	}
+	if rsMaxLenExp < 0 || rsMaxLenExp > 15 {
+		panic(fmt.Sprintf("RawSocket max length exponent (-rs-max-length-exp) must be between 0 and 15, got %d", rsMaxLenExp))
+	}
+
+	if err := parseErrorMap(errorMap); err != nil {
+		panic(err)
+	}
 
-	routerConfig := &router.Config{
-		RealmConfigs: []*router.RealmConfig{
-			{
-				URI:           wamp.URI(realm),
-				AnonymousAuth: true,
-				AllowDisclose: true,
-			},
-		},
+	if err := parseTopicSizes(topicLimits); err != nil {
+		panic(err)
+	}
+	metaRoles = parseMetaRoles(metaRoleCfg)
+	if dashEnable {
+		credentials, err := dashboardCredentials(dashAuth, dashFile)
+		if err != nil {
+			panic(err)
+		}
+		if dashUser, dashPassword, err = checkDashboard(dashPath, credentials); err != nil {
+			panic(err)
+		}
+	}
+	if err := checkUniqueMode(uniqueMode); err != nil {
+		panic(err)
+	}
+	if err := parseCallAllowlist(callAllowed); err != nil {
+		panic(err)
+	}
+	if err := parseDedupTopics(dedupTopics); err != nil {
+		panic(err)
+	}
+	if adminPage < 1 {
+		panic(fmt.Sprintf("admin page size (-admin-page-size) must be at least 1, got %d", adminPage))
+	}
+	if topoDepth < 1 {
+		panic(fmt.Sprintf("topology depth (-topology-depth) must be at least 1, got %d", topoDepth))
+	}
+	if reconWindow <= 0 {
+		panic(fmt.Sprintf("reconnect window (-reconnect-window) must be positive, got %s", reconWindow))
+	}
+	if dedupWindow <= 0 {
+		panic(fmt.Sprintf("dedup window (-dedup-window) must be positive, got %s", dedupWindow))
+	}
+	if err := parseSerializerSizes(serMaxSizes); err != nil {
+		panic(err)
 	}
 
-	wsRouter, err := router.NewRouter(routerConfig, logger)
+	if err := parseCallTimeouts(callLimits); err != nil {
+		panic(err)
+	}
+	if err := parseLogLevel(logLevel, traceRedact); err != nil {
+		panic(err)
+	}
+	if agent == "" {
+		agent = defaultAgent()
+	}
+	agentsAllowed, agentsDenied = parseAgentPatterns(agentAllow), parseAgentPatterns(agentDeny)
+	if err := parseRequiredFeatures(reqFeatures); err != nil {
+		panic(err)
+	}
+	if err := parseAuthExtra(authxKeep, authxDeny); err != nil {
+		panic(err)
+	}
+	if err := parseDisclosure(discloseCfg); err != nil {
+		panic(err)
+	}
+	nets, err := parseTrustedProxies(trustedCfg)
 	if err != nil {
 		panic(err)
 	}
-	defer wsRouter.Close()
+	trustedNets = nets
+	if ticketFile != "" {
+		if tickets, err = loadTickets(ticketFile); err != nil {
+			panic(err)
+		}
+	}
+	if extAuthWait <= 0 {
+		panic(fmt.Sprintf("external auth timeout (-external-auth-timeout) must be positive, got %s", extAuthWait))
+	}
+	if err := checkTimeFormat(dtimeFormat); err != nil {
+		panic(err)
+	}
+	if err := parseMatchPolicies(matchCfg); err != nil {
+		panic(err)
+	}
+	if err := parseRealmSerializers(realmSerCfg); err != nil {
+		panic(err)
+	}
+	if err := parsePrefixPolicies(prefixCfg); err != nil {
+		panic(err)
+	}
+	if err := parseRealmQuotas(realmQuotas); err != nil {
+		panic(err)
+	}
+	if quotaWindow <= 0 {
+		panic(fmt.Sprintf("invalid quota window (-quota-window) %s", quotaWindow))
+	}
+	extra, err := parseWelcomeDetails(welcomeInfo)
+	if err != nil {
+		panic(err)
+	}
+	welcomeExtra = extra
+
+	if _, err := subprotocolOrder(serializers); err != nil {
+		panic(fmt.Sprintf("invalid serializer preference (-serializer-preference): %s", err))
+	}
+
+	rsAddr := fmt.Sprintf("%s:%d", rsHost, rsPort)
+
+	var diagEvents chan wamp.Dict
+	if diagTopic != "" {
+		diagEvents = make(chan wamp.Dict, 64)
+	}
+	logger = log.New(&diagWriter{os.Stdout, diagEvents}, "", log.LstdFlags)
+	if lifeStdout {
+		lifecycleOut = os.Stdout
+	}
+	emitLifecycle(lifecycleStarting, nil)
+	inMaintenance.Store(maintenance)
+	acceptPaused.Store(startPaused)
+	if disableFile != "" {
+		if err := loadDisabled(disableFile); err != nil {
+			panic(err)
+		}
+	}
+	if logLevelID.Load() == levelTrace {
+		logger.Printf("warning: trace logging is on, message payloads of remote sessions are logged\n")
+	}
 
-	clientConfig := client.Config{
-		Realm:  realm,
-		Logger: logger,
+	if normRealms {
+		realm = normalizeRealm(realm)
+		extraRealms = normalizeRealmList(extraRealms)
+		for i, uri := range localRealms {
+			localRealms[i] = normalizeRealm(uri)
+		}
+		quotas = normalizeKeys(quotas)
+		discloseModes = normalizeKeys(discloseModes)
+		matchPolicies = normalizeKeys(matchPolicies)
+		realmSerializers = normalizeKeys(realmSerializers)
+		prefixPolicies = normalizeKeys(prefixPolicies)
+	}
+
+	if maxSubs > 0 {
+		subsLimit = newSessionLimit("subscriptions", maxSubs)
 	}
-	localClient, err = client.ConnectLocal(wsRouter, clientConfig)
+	if maxRegs > 0 {
+		regsLimit = newSessionLimit("registrations", maxRegs)
+	}
+
+	routerConfig := &router.Config{RealmConfigs: realmConfigs(extraRealms)}
+
+	wsRouter, err = router.NewRouter(routerConfig, logger)
 	if err != nil {
 		panic(err)
 	}
-	defer localClient.Close()
+	// Once the router runs, a panic of the rest of the setup or of the
+	// signal handling closes it before exiting.
+	var listeners []io.Closer
+	defer func() {
+		if r := recover(); r != nil {
+			abortRouter(r, listeners)
+			os.Exit(1)
+		}
+	}()
+
+	localClient, err = connectLocalClient()
+	if err != nil {
+		panic(err)
+	}
+	setHealth("local_client", nil)
+
+	if onJoinProc != "" || onJoinTopic != "" {
+		if err = watchJoins(); err != nil {
+			panic(err)
+		}
+	}
+	if reconTopic != "" {
+		if err = watchReconnects(); err != nil {
+			panic(err)
+		}
+	}
+	if err = startTaps(tapTopics); err != nil {
+		panic(err)
+	}
+
+	for _, config := range routerConfig.RealmConfigs {
+		if maxSessions > 0 {
+			if err = watchSessionLeaves(config.URI); err != nil {
+				panic(err)
+			}
+		}
+		if maxLifetime > 0 {
+			if err = watchExpiries(config.URI); err != nil {
+				panic(err)
+			}
+		}
+		if uniqueMode != "" {
+			if err = watchUniqueLeaves(config.URI); err != nil {
+				panic(err)
+			}
+		}
+		if subsLimit != nil {
+			if err = subsLimit.watch(config.URI, wamp.MetaEventSubOnSubscribe, wamp.MetaEventSubOnUnsubscribe); err != nil {
+				panic(err)
+			}
+		}
+		if guard := newPrefixGuard(config.URI); guard != nil {
+			if err = guard.watch(); err != nil {
+				panic(err)
+			}
+		}
+		if regsLimit != nil {
+			if err = regsLimit.watch(config.URI, wamp.MetaEventRegOnRegister, wamp.MetaEventRegOnUnregister); err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	// Clients join through transportRouter, which normalizes the realm of
+	// their HELLO with -normalize-realms and counts their connections with
+	// -count-connections.
+	var transportRouter router.Router = wsRouter
+	if abortReason {
+		transportRouter = reasonRouter{transportRouter}
+	}
+	if countBytes {
+		transportRouter = countingRouter{transportRouter}
+	}
+	if countFanout {
+		transportRouter = fanoutRouter{transportRouter}
+	}
+	if countAcks || logAcks {
+		transportRouter = ackRouter{transportRouter}
+	}
+	if normRealms {
+		transportRouter = normalizingRouter{transportRouter}
+	}
+	if countConns {
+		transportRouter = connRouter{transportRouter}
+	}
 
 	if wsEnable {
-		wsServer := router.NewWebsocketServer(wsRouter)
-		wsServer.Upgrader.EnableCompression = true
-		wsServer.Upgrader.CheckOrigin = func(res *http.Request) bool {
-			return true
-		}
-		wsServer.EnableTrackingCookie = true
-		wsServer.KeepAlive = 30 * time.Second
-		wsCloser, err := wsServer.ListenAndServe(wsAddr)
+		var wsHandler http.Handler = newWebsocketServer(transportRouter)
+		if wsSerParam != "" {
+			wsHandler = serializerParam(wsHandler, wsSerParam)
+		}
+		if len(wsHeaders) != 0 {
+			header, err := parseResponseHeaders(wsHeaders)
+			if err != nil {
+				panic(err)
+			}
+			wsHandler = responseHeaders(wsHandler, header)
+		}
+		wsHandler = normalizePaths(dashboardMux(rootIndex(acceptGate(wsHandler))))
+		wsCloser, wsURL, err := startWebsocket(wsHandler)
 		if err != nil {
 			panic(err)
 		}
-		defer wsCloser.Close()
-		logger.Printf("listening on ws://%s\n", wsAddr)
+		listeners = append(listeners, wsCloser)
+		transports = append(transports, wsURL)
+		if logLevelID.Load() >= levelDebug {
+			logger.Printf("listening on %s\n", wsURL)
+		}
+		emitLifecycle(lifecycleListening, wamp.Dict{"url": wsURL})
 	}
 
 	if rsEnable {
-		rsServer := router.NewRawSocketServer(wsRouter)
-		rsServer.KeepAlive = 30 * time.Second
-		rsCloser, err := rsServer.ListenAndServe(rsProto, rsAddr)
-		if err != nil {
+		rsRouter := transportRouter
+		if rsAccRate > 0 {
+			rsRouter = throttledRouter{transportRouter, newAcceptLimiter(rsAccRate)}
+		}
+		rsServer := newRawSocketServer(pausableRouter{rsRouter})
+		for _, l := range append([]rawSocketListener{{rsProto, rsAddr}}, rsListeners...) {
+			rsCloser, rsURL, err := startRawSocket(rsServer, l)
+			if err != nil {
+				panic(err)
+			}
+			listeners = append(listeners, rsCloser)
+			transports = append(transports, rsURL)
+			if logLevelID.Load() >= levelDebug {
+				logger.Printf("listening on %s\n", rsURL)
+			}
+			emitLifecycle(lifecycleListening, wamp.Dict{"url": rsURL})
+		}
+	}
+
+	if err = createLocalCallee(localClient, "nexus.info", nexusInfo); err != nil {
+		panic(err)
+	}
+	if err = createLocalCallee(localClient, "nexus.features", nexusFeatures); err != nil {
+		panic(err)
+	}
+	if whoamiOn {
+		if err = createLocalCallee(localClient, whoamiProc, nexusWhoami); err != nil {
+			panic(err)
+		}
+	}
+	if err = createLocalCallee(localClient, "nexus.util.multipublish", multiPublish); err != nil {
+		panic(err)
+	}
+	if err = createLocalCallee(localClient, adminPrefix+".realm.close", adminRealmClose); err != nil {
+		panic(err)
+	}
+	if err = createLocalCallee(localClient, adminPrefix+".realm.clear", adminRealmClear); err != nil {
+		panic(err)
+	}
+	if err = createLocalCallee(localClient, adminPrefix+".publish", adminPublish); err != nil {
+		panic(err)
+	}
+	if err = createLocalCallee(localClient, adminPrefix+".sessions.get", adminSessionsGet); err != nil {
+		panic(err)
+	}
+	if err = createLocalCallee(localClient, adminPrefix+".sessions.list", adminSessionsList); err != nil {
+		panic(err)
+	}
+	if err = createLocalCallee(localClient, adminPrefix+".registrations.list", adminRegistrationsList); err != nil {
+		panic(err)
+	}
+	if err = createLocalCallee(localClient, adminPrefix+".maintenance", adminMaintenance); err != nil {
+		panic(err)
+	}
+	if err = createLocalCallee(localClient, adminPrefix+".loglevel", adminLogLevel); err != nil {
+		panic(err)
+	}
+	if err = createLocalCallee(localClient, adminPrefix+".accept.pause", adminAccept(true)); err != nil {
+		panic(err)
+	}
+	if err = createLocalCallee(localClient, adminPrefix+".accept.resume", adminAccept(false)); err != nil {
+		panic(err)
+	}
+	if err = createLocalCallee(localClient, adminPrefix+".topic.disable", adminDisable(disabledTopics, true)); err != nil {
+		panic(err)
+	}
+	if err = createLocalCallee(localClient, adminPrefix+".topic.enable", adminDisable(disabledTopics, false)); err != nil {
+		panic(err)
+	}
+	if err = createLocalCallee(localClient, adminPrefix+".proc.disable", adminDisable(disabledProcs, true)); err != nil {
+		panic(err)
+	}
+	if err = createLocalCallee(localClient, adminPrefix+".proc.enable", adminDisable(disabledProcs, false)); err != nil {
+		panic(err)
+	}
+	if debugLogLen > 0 {
+		if err = createLocalCallee(localClient, adminPrefix+".debuglog", adminDebugLog); err != nil {
+			panic(err)
+		}
+	}
+	if sessRegFile != "" {
+		if err = createLocalCallee(localClient, adminPrefix+".registry", adminRegistry); err != nil {
+			panic(err)
+		}
+	}
+	if selfCheckIv > 0 {
+		if err = createLocalCallee(localClient, selfCheckProc(), adminSelfCheck); err != nil {
 			panic(err)
 		}
-		defer rsCloser.Close()
-		logger.Printf("listening on %s://%s\n", rsProto, rsAddr)
 	}
 
 	if devEcho {
-		err = createLocalCallee(localClient, "dev.echo", func(ctx context.Context, inv *wamp.Invocation) client.InvokeResult {
-			time.Sleep(2 * time.Second)
-			res := client.InvokeResult{
-				Args:   inv.Arguments,
-				Kwargs: inv.ArgumentsKw,
-			}
-			fmt.Printf("dev.echo %v %v\n", res, inv.Details)
-			return res
-		})
+		if err = registerDevEcho(localClient); err != nil {
+			panic(err)
+		}
+	}
+	if devTime {
+		if err = registerDevCallee(localClient, devPrefix+".time.now", devTimeNow); err != nil {
+			panic(err)
+		}
+	}
+
+	if proxyConfig != "" {
+		noProxy := os.Getenv("NO_PROXY")
+		if noProxy == "" {
+			noProxy = os.Getenv("no_proxy")
+		}
+		if httpClient, err = newHTTPClient(httpProxy, noProxy, httpCAFile, skipVerify); err != nil {
+			panic(err)
+		}
+		mappings, err := loadProxyMappings(proxyConfig)
 		if err != nil {
 			panic(err)
 		}
+		if err = checkProxyEndpoints(mappings); err != nil {
+			panic(err)
+		}
+		for _, m := range mappings {
+			if err = createProxyCallee(localClient, m); err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	for _, uri := range localRealms {
+		if err = connectExtraLocalClient(wamp.URI(uri)); err != nil {
+			panic(fmt.Sprintf("failed to join local realm %q: %s", uri, err))
+		}
 	}
 
+	startPublishQueue(publishBuf, publishersQuit)
+
 	if devTime {
-		ticker := time.NewTicker(time.Second * 5)
-		tickerQuit := make(chan struct{})
-		go func() {
-			for {
-				select {
-				case <-ticker.C:
-					now := time.Now()
-					nowStr := now.Format(time.RFC3339)
-					logger.Printf("dev.time: %s\n", nowStr)
-					localClient.Publish("dev.time", wamp.Dict{}, wamp.List{nowStr}, wamp.Dict{})
-				case <-tickerQuit:
-					ticker.Stop()
-					return
-				}
-			}
-		}()
+		startPublisher(devPrefix+".time", func(quit <-chan struct{}) {
+			publishDevTime(time.Second*5, quit)
+		})
+	}
+
+	if devWildcard {
+		startPublisher(devPrefix+".wildcard", func(quit <-chan struct{}) {
+			publishDevWildcard(time.Second*5, quit)
+		})
+	}
+
+	if statsTopic != "" {
+		startPublisher("stats", func(quit <-chan struct{}) {
+			publishStats(statsTopic, statsEvery, quit)
+		})
+	}
+
+	if diagTopic != "" {
+		startPublisher("diagnostics", func(quit <-chan struct{}) {
+			publishDiagnostics(diagTopic, diagEvents, quit)
+		})
+	}
+
+	if selfCheckIv > 0 {
+		startPublisher("self_check", func(quit <-chan struct{}) {
+			runSelfCheck(selfCheckIv, quit)
+		})
+	}
+
+	go superviseLocalClient(publishersQuit)
+
+	if watchEvery > 0 {
+		go watchdog(watchEvery, publishersQuit)
+	}
+
+	var realms []wamp.URI
+	for _, config := range routerConfig.RealmConfigs {
+		realms = append(realms, config.URI)
 	}
+	logStartup(realms)
+
+	if topoEvery > 0 {
+		startPublisher("topology", func(quit <-chan struct{}) {
+			runTopology(realms, topoEvery, topoDepth, quit)
+		})
+	}
+
+	// SIGQUIT writes a snapshot and exits without the graceful shutdown, for
+	// when the router is wedged.  Go's own SIGQUIT stack dump is replaced by
+	// the goroutines section of the snapshot.
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGQUIT)
+	go func() {
+		<-quit
+		if err := writeDump(dumpFile, realms); err != nil {
+			logger.Printf("failed to write dump: %s\n", err)
+		} else {
+			logger.Printf("wrote dump to %s\n", dumpFile)
+		}
+		os.Exit(2)
+	}()
 
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt)
 
-	<-shutdown
+	select {
+	case <-shutdown:
+	case <-panicked:
+		logger.Printf("shutting down after a panic\n")
+		stopRouter(shutdownSteps(realms, listeners), stopTimeout)
+		os.Exit(1)
+	}
+
+	stopRouter(shutdownSteps(realms, listeners), stopTimeout)
+}
+
+// listenWebsocket serves the WebSocket handler on a TCP or Unix socket.  As
+// with RawSocket Unix listeners, a Unix socket is created with the process
+// umask and its file is removed when the returned closer is closed.
+func listenWebsocket(h http.Handler, network, address string) (io.Closer, net.Addr, error) {
+	l, err := net.Listen(network, address)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Unix socket clients share one remote address, so cannot be told apart.
+	if countBytes && network == "tcp" {
+		l = countingListener{l}
+	}
+	return serveWebsocket(h, l), l.Addr(), nil
+}
+
+// startWebsocket serves the WebSocket handler on -ws-host and -ws-port.  It
+// returns the URL of the bound address, which has the port picked by the OS
+// with -ws-port 0.
+func startWebsocket(h http.Handler) (io.Closer, string, error) {
+	if strings.HasPrefix(wsHost, "unix:") {
+		path := strings.TrimPrefix(wsHost, "unix:")
+		closer, _, err := listenWebsocket(h, "unix", path)
+		return closer, "ws+unix://" + path, err
+	}
+	closer, addr, err := listenWebsocket(h, "tcp", fmt.Sprintf("%s:%d", wsHost, wsPort))
+	if err != nil {
+		return nil, "", err
+	}
+	return closer, "ws://" + addr.String(), nil
+}
+
+// startRawSocket serves the RawSocket server on the listener address.  It
+// returns the URL of the bound address, which has the port picked by the OS
+// for port 0.
+func startRawSocket(s *router.RawSocketServer, l rawSocketListener) (io.Closer, string, error) {
+	closer, err := s.ListenAndServe(l.network, l.address)
+	if err != nil {
+		return nil, "", err
+	}
+	return closer, l.network + "://" + closer.(net.Listener).Addr().String(), nil
+}
+
+// serveWebsocket serves the WebSocket handler on l.  The listener is reported
+// as listener.<address> in the health status, which shows the error if the
+// server stops accepting connections before it is closed.
+func serveWebsocket(h http.Handler, l net.Listener) io.Closer {
+	// gorilla's HandshakeTimeout only bounds writing the upgrade response, so
+	// the same timeout also applies to reading the request headers.
+	server := &http.Server{Handler: h, ReadHeaderTimeout: wsHandshake}
+	subsystem := "listener." + l.Addr().String()
+	setHealth(subsystem, nil)
+	go func() {
+		if err := server.Serve(l); err != http.ErrServerClosed {
+			logger.Printf("WebSocket server on %s stopped: %s\n", l.Addr(), err)
+			setHealth(subsystem, err)
+		}
+	}()
+	return server
+}
+
+// serializerParam returns a handler that takes the serializer from the query
+// parameter named param, such as ?serializer=msgpack, for upgrade requests
+// without a subprotocol.  This is for clients behind reverse proxies that
+// strip the Sec-WebSocket-Protocol header; requests with the header are left
+// alone.
+func serializerParam(h http.Handler, param string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Sec-WebSocket-Protocol") == "" {
+			name := r.URL.Query().Get(param)
+			for _, p := range wsSubprotocols {
+				if p.name == name {
+					r.Header.Set("Sec-WebSocket-Protocol", p.subprotocol)
+				}
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// newWebsocketServer returns the WebSocket server configured from the flags.
+func newWebsocketServer(r router.Router) *router.WebsocketServer {
+	s := router.NewWebsocketServer(r)
+	// gorilla picks the first of the server's subprotocols offered by the
+	// client.  The preference was validated at startup.
+	s.Upgrader.Subprotocols, _ = subprotocolOrder(serializers)
+	s.Upgrader.EnableCompression = wsCompress
+	s.Upgrader.HandshakeTimeout = wsHandshake
+	// Trusted header authentication reads the headers of the captured
+	// upgrade request, -count-bytes and -external-auth-socket its remote
+	// address and -serializer-max-sizes, -realm-serializers and
+	// -count-connections its subprotocols.  nexus leaves it out of the
+	// session meta API.
+	s.EnableRequestCapture = len(trustedNets) != 0 || countBytes || countConns || len(serializerSizes) != 0 || len(realmSerializers) != 0 || extAuthSock != ""
+	if wsWritePool {
+		s.Upgrader.WriteBufferPool = wsBufferPool
+	}
+	s.Upgrader.CheckOrigin = func(res *http.Request) bool {
+		return true
+	}
+	s.EnableTrackingCookie = true
+	s.KeepAlive = wsKeepAlive
+	return s
+}
+
+// newRawSocketServer returns the RawSocket server configured from the flags.
+func newRawSocketServer(r router.Router) *router.RawSocketServer {
+	s := router.NewRawSocketServer(r)
+	s.KeepAlive = rsKeepAlive
+	s.RecvLimit = 1 << (9 + rsMaxLenExp)
+	return s
+}
+
+// realmConfigs returns the configs of the default realm and of the extra
+// realms, each given as uri or uri=prefix.
+func realmConfigs(extra []string) []*router.RealmConfig {
+	configs := []*router.RealmConfig{newRealmConfig(wamp.URI(realm), uriPrefix)}
+	for _, s := range extra {
+		uri, prefix, _ := strings.Cut(s, "=")
+		configs = append(configs, newRealmConfig(wamp.URI(uri), prefix))
+	}
+	return configs
+}
+
+func newRealmConfig(uri wamp.URI, prefix string) *router.RealmConfig {
+	authz := &authorizer{uriPrefix: prefix, adminRole: adminRole, counts: newRealmCount(uri), subLimit: subsLimit, regLimit: regsLimit, matches: newRealmMatchPolicy(uri), prefixes: newPrefixGuard(uri), quota: newRealmQuota(uri, quotaWindow), disclose: discloseMode(uri) == discloseForce}
+	if len(topicSizes) != 0 {
+		authz.publishChecks = append(authz.publishChecks, checkTopicSize)
+	}
+	if len(dedupTopicSet) != 0 {
+		authz.publishChecks = append(authz.publishChecks, newPublishDedup(dedupWindow).check)
+	}
+	authenticators := []auth.Authenticator{
+		&anonymousAuth{realm: uri, authID: anonAuthID, authRole: anonRole, agent: agent, extra: welcomeExtra},
+	}
+	if len(trustedNets) != 0 {
+		authenticators = append(authenticators, &headerAuth{realm: uri, trusted: trustedNets, userHeader: userHeader, roleHeader: roleHeader, agent: agent, extra: welcomeExtra})
+	}
+	if tickets != nil {
+		authenticators = append(authenticators, newTicketAuth(uri, tickets, agent, welcomeExtra))
+	}
+	if extAuthSock != "" {
+		authenticators = append(authenticators, &extAuth{realm: uri, socket: extAuthSock, timeout: extAuthWait, agent: agent, extra: welcomeExtra})
+	}
+	if abortReason {
+		for i, a := range authenticators {
+			authenticators[i] = reasonAuth{a}
+		}
+	}
+	return &router.RealmConfig{
+		URI:            uri,
+		AnonymousAuth:  true,
+		AllowDisclose:  discloseMode(uri) != discloseForbid,
+		Authenticators: authenticators,
+		Authorizer:     authz,
+		EnableMetaKill: true,
+		StrictURI:      strictURI,
+	}
 }
 
 func createLocalCallee(client *client.Client, procedure string, callback func(ctx context.Context, inv *wamp.Invocation) client.InvokeResult) error {
+	callback = recoverHandler(procedure, callback)
 	if err := client.Register(procedure, callback, nil); err != nil {
 		return fmt.Errorf("failed to register %q: %s", procedure, err)
 	}
+	localMu.Lock()
+	localCallees[procedure] = callback
+	localMu.Unlock()
 	logger.Printf("registered RPC: %s\n", procedure)
 	return nil
 }
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/router"
+	"github.com/gammazero/nexus/v3/wamp"
+	"github.com/gorilla/websocket"
+)
+
+// startTestRouter creates the router with the default realm and any extra
+// realms, given as for -add-realm, connects the local client and returns the URL of a WebSocket
+// server for remote clients.  Everything is torn down when the test ends.
+func startTestRouter(t *testing.T, extra ...string) string {
+	t.Helper()
+	logger = log.New(io.Discard, "", 0)
+	localCallees = map[string]client.InvocationHandler{}
+	localSubscribers = map[string]localSubscriber{}
+	health = map[string]string{}
+	// Realm clients of the previous test may still be handling events.
+	metaMu.Lock()
+	metaHandlers = map[wamp.URI][]client.EventHandler{}
+	realmMetaHandlers = map[wamp.URI]map[wamp.URI][]client.EventHandler{}
+	metaMu.Unlock()
+	realmClients = map[wamp.URI]*client.Client{}
+	extraLocalClients = map[wamp.URI]*client.Client{}
+	realmSessions = map[wamp.URI]map[wamp.ID]time.Time{}
+	authCounts = map[string]*authCount{}
+	realmCounts = map[wamp.URI]*realmCount{}
+	debugLog, debugLogNext = nil, 0
+	publishers = nil
+	publishersQuit = make(chan struct{})
+	startPublishQueue(publishBuf, publishersQuit)
+
+	var err error
+	if wsRouter, err = router.NewRouter(&router.Config{RealmConfigs: realmConfigs(extra)}, logger); err != nil {
+		t.Fatal(err)
+	}
+	if localClient, err = connectLocalClient(); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(newWebsocketServer(wsRouter))
+	r, quit := wsRouter, publishersQuit
+	t.Cleanup(func() {
+		select {
+		case <-quit:
+		default:
+			close(quit)
+		}
+		closeExtraLocalClients()
+		getLocalClient().Close()
+		server.Close()
+		// Tests closing the router themselves set wsRouter to nil.
+		if wsRouter != nil {
+			r.Close()
+		}
+	})
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+// runUntilEnd runs fn in a goroutine and waits for it to return when the test
+// ends, after closing the quit channel passed to it.
+func runUntilEnd(t *testing.T, fn func(quit <-chan struct{})) {
+	quit := publishersQuit
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(quit)
+	}()
+	t.Cleanup(func() {
+		select {
+		case <-quit:
+		default:
+			close(quit)
+		}
+		<-done
+	})
+}
+
+// countPings opens a WebSocket connection to url without sending anything and
+// returns the number of pings received from the router within d.
+func countPings(t *testing.T, url string, d time.Duration) int {
+	t.Helper()
+	dialer := websocket.Dialer{Subprotocols: []string{"wamp.2.json"}}
+	conn, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	pings := 0
+	conn.SetPingHandler(func(data string) error {
+		pings++
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	conn.SetReadDeadline(time.Now().Add(d))
+	for {
+		if _, _, err = conn.ReadMessage(); err != nil {
+			return pings
+		}
+	}
+}
+
+func TestWebsocketKeepAlive(t *testing.T) {
+	saved := wsKeepAlive
+	defer func() { wsKeepAlive = saved }()
+
+	wsKeepAlive = 0
+	if pings := countPings(t, startTestRouter(t), 300*time.Millisecond); pings != 0 {
+		t.Errorf("expected no pings with keepalive disabled, got %d", pings)
+	}
+	wsKeepAlive = 50 * time.Millisecond
+	if pings := countPings(t, startTestRouter(t), 300*time.Millisecond); pings == 0 {
+		t.Error("expected pings with keepalive enabled")
+	}
+}
+
+func TestServerKeepAlive(t *testing.T) {
+	savedWS, savedRS := wsKeepAlive, rsKeepAlive
+	defer func() { wsKeepAlive, rsKeepAlive = savedWS, savedRS }()
+	wsKeepAlive, rsKeepAlive = 20*time.Second, 45*time.Second
+
+	if ws := newWebsocketServer(nil); ws.KeepAlive != wsKeepAlive {
+		t.Errorf("expected WebSocket keepalive %s, got %s", wsKeepAlive, ws.KeepAlive)
+	}
+	if rs := newRawSocketServer(nil); rs.KeepAlive != rsKeepAlive {
+		t.Errorf("expected RawSocket keepalive %s, got %s", rsKeepAlive, rs.KeepAlive)
+	}
+}
+
+func TestWebsocketCompression(t *testing.T) {
+	saved := wsCompress
+	defer func() { wsCompress = saved }()
+
+	for _, wsCompress = range []bool{true, false} {
+		url := startTestRouter(t)
+		dialer := websocket.Dialer{Subprotocols: []string{"wamp.2.json"}, EnableCompression: true}
+		conn, res, err := dialer.Dial(url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+		ext := res.Header.Get("Sec-WebSocket-Extensions")
+		if negotiated := strings.Contains(ext, "permessage-deflate"); negotiated != wsCompress {
+			t.Errorf("compression %t: unexpected extensions %q", wsCompress, ext)
+		}
+	}
+}
+
+// countingPool is a buffer pool counting new and reused buffers.
+type countingPool struct {
+	sync.Mutex
+	pool        sync.Pool
+	gets, reuse int
+}
+
+func (p *countingPool) Get() interface{} {
+	p.Lock()
+	defer p.Unlock()
+	p.gets++
+	v := p.pool.Get()
+	if v != nil {
+		p.reuse++
+	}
+	return v
+}
+
+func (p *countingPool) Put(v interface{}) {
+	p.pool.Put(v)
+}
+
+func TestWebsocketWritePool(t *testing.T) {
+	savedEnable, savedPool := wsWritePool, wsBufferPool
+	defer func() { wsWritePool, wsBufferPool = savedEnable, savedPool }()
+
+	wsWritePool = false
+	if s := newWebsocketServer(nil); s.Upgrader.WriteBufferPool != nil {
+		t.Error("expected no write buffer pool by default")
+	}
+
+	pool := &countingPool{}
+	wsWritePool, wsBufferPool = true, pool
+	url := startTestRouter(t)
+	for i := 0; i < 3; i++ {
+		c := connectTestClient(t, url, realm)
+		if _, err := testCall(c, string(wamp.MetaProcSessionCount), nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+	}
+	pool.Lock()
+	defer pool.Unlock()
+	if pool.gets == 0 {
+		t.Fatal("write buffers were not taken from the pool")
+	}
+	if pool.reuse == 0 {
+		t.Errorf("no write buffer was reused in %d writes", pool.gets)
+	}
+}
+
+func TestRawSocketMaxLength(t *testing.T) {
+	saved := rsMaxLenExp
+	defer func() { rsMaxLenExp = saved }()
+	startTestRouter(t)
+
+	for _, rsMaxLenExp = range []int{0, 5, 15} {
+		closer, err := newRawSocketServer(wsRouter).ListenAndServe("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn, err := net.Dial("tcp", closer.(net.Listener).Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Request the largest length with JSON serialization.
+		conn.Write([]byte{0x7f, 0xf1, 0, 0})
+		var reply [4]byte
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if _, err = io.ReadFull(conn, reply[:]); err != nil {
+			t.Fatal(err)
+		}
+		if exp := int(reply[1] >> 4); exp != rsMaxLenExp {
+			t.Errorf("expected negotiated exponent %d, got %d", rsMaxLenExp, exp)
+		}
+		conn.Close()
+		closer.Close()
+	}
+}
+
+func TestUnixWebsocket(t *testing.T) {
+	startTestRouter(t)
+	path := filepath.Join(t.TempDir(), "ws.sock")
+	closer, _, err := listenWebsocket(newWebsocketServer(wsRouter), "unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dialer := websocket.Dialer{
+		Subprotocols: []string{"wamp.2.json"},
+		NetDial: func(string, string) (net.Conn, error) {
+			return net.Dial("unix", path)
+		},
+	}
+	conn, _, err := dialer.Dial("ws://localhost/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hello := `[1, "` + realm + `", {"roles": {"caller": {}}}]`
+	if err = conn.WriteMessage(websocket.TextMessage, []byte(hello)); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, msg, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(msg), "[2,") {
+		t.Errorf("expected WELCOME, got %s", msg)
+	}
+	conn.Close()
+
+	if err = closer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the socket file to be removed, got %v", err)
+	}
+}
+
+func TestWebsocketHandshakeTimeout(t *testing.T) {
+	saved := wsHandshake
+	defer func() { wsHandshake = saved }()
+	wsHandshake = 100 * time.Millisecond
+
+	startTestRouter(t)
+	if s := newWebsocketServer(wsRouter); s.Upgrader.HandshakeTimeout != wsHandshake {
+		t.Errorf("expected handshake timeout %s, got %s", wsHandshake, s.Upgrader.HandshakeTimeout)
+	}
+	path := filepath.Join(t.TempDir(), "ws.sock")
+	closer, _, err := listenWebsocket(newWebsocketServer(wsRouter), "unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// Start the upgrade request without ever finishing its headers.
+	conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\n"))
+	start := time.Now()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	io.Copy(io.Discard, conn)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("stalled handshake was not dropped, waited %s", elapsed)
+	}
+}
+
+// failingListener is a listener whose Accept fails once failed is closed.
+type failingListener struct {
+	net.Listener
+	failed chan struct{}
+}
+
+func (l *failingListener) Accept() (net.Conn, error) {
+	<-l.failed
+	return nil, errors.New("too many open files")
+}
+
+func TestWebsocketListenerFailure(t *testing.T) {
+	startTestRouter(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	failing := &failingListener{Listener: l, failed: make(chan struct{})}
+	closer := serveWebsocket(newWebsocketServer(wsRouter), failing)
+	defer closer.Close()
+
+	subsystem := "listener." + l.Addr().String()
+	if status := healthStatus()[subsystem]; status != "ok" {
+		t.Errorf("expected %s to be ok, got %q", subsystem, status)
+	}
+	close(failing.failed)
+	if !waitFor(t, 5*time.Second, func() bool { return healthStatus()[subsystem] == "too many open files" }) {
+		t.Errorf("listener failure not reported, health is %v", healthStatus())
+	}
+}
+
+// connectTestClient joins a remote client to the realm through the WebSocket
+// server, so that the realm's authenticator and authorizer apply to it.
+func connectTestClient(t *testing.T, url, realm string) *client.Client {
+	t.Helper()
+	c, err := client.ConnectNet(context.Background(), url, client.Config{Realm: realm, Logger: logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// testCall calls procedure with a short timeout.
+func testCall(c *client.Client, procedure string, args wamp.List, kwargs wamp.Dict) (*wamp.Result, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return c.Call(ctx, procedure, nil, args, kwargs, nil)
+}
+
+// errorURI returns the error URI of a failed call.
+func errorURI(err error) wamp.URI {
+	if rpcErr, ok := err.(client.RPCError); ok {
+		return rpcErr.Err.Error
+	}
+	return ""
+}
+
+func TestWebsocketRandomPort(t *testing.T) {
+	savedHost, savedPort := wsHost, wsPort
+	defer func() { wsHost, wsPort = savedHost, savedPort }()
+	wsHost, wsPort = "127.0.0.1", 0
+
+	startTestRouter(t)
+	closer, url, err := startWebsocket(newWebsocketServer(wsRouter))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+	_, port, err := net.SplitHostPort(strings.TrimPrefix(url, "ws://"))
+	if err != nil || port == "0" {
+		t.Fatalf("expected the bound port in %s: %v", url, err)
+	}
+	c := connectTestClient(t, url, realm)
+	if _, err = testCall(c, "wamp.session.count", nil, nil); err != nil {
+		t.Error(err)
+	}
+}
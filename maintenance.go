@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// inMaintenance is set while the router is in maintenance mode, started with
+// -maintenance and toggled with the maintenance admin procedure.
+var inMaintenance atomic.Bool
+
+// errMaintenance rejects publishes and registrations in maintenance mode.
+var errMaintenance = errors.New("maintenance: the router is in read-only maintenance mode")
+
+// checkMaintenance rejects publishes and registrations of remote sessions
+// while the router is in maintenance mode.  Calls and subscribes pass.
+func checkMaintenance(msg wamp.Message) error {
+	if !inMaintenance.Load() {
+		return nil
+	}
+	switch msg.(type) {
+	case *wamp.Publish, *wamp.Register:
+		return errMaintenance
+	}
+	return nil
+}
+
+// adminMaintenance handles <admin-prefix>.maintenance.  An optional boolean
+// argument turns maintenance mode on or off.  It returns whether the router
+// is in maintenance mode.
+func adminMaintenance(ctx context.Context, inv *wamp.Invocation) client.InvokeResult {
+	if len(inv.Arguments) != 0 {
+		on, ok := inv.Arguments[0].(bool)
+		if !ok {
+			return client.InvokeResult{Err: wamp.ErrInvalidArgument, Args: wamp.List{"argument must be a boolean"}}
+		}
+		if inMaintenance.Swap(on) != on {
+			logger.Printf("maintenance mode set to %t\n", on)
+		}
+	}
+	return client.InvokeResult{Args: wamp.List{inMaintenance.Load()}}
+}
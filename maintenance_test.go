@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestMaintenance(t *testing.T) {
+	savedRole := anonRole
+	defer func() {
+		anonRole = savedRole
+		inMaintenance.Store(false)
+	}()
+	anonRole = adminRole
+
+	url := startTestRouter(t)
+	if err := createLocalCallee(getLocalClient(), adminPrefix+".maintenance", adminMaintenance); err != nil {
+		t.Fatal(err)
+	}
+	c := connectTestClient(t, url, realm)
+	ack := wamp.Dict{wamp.OptAcknowledge: true}
+	noop := func(context.Context, *wamp.Invocation) client.InvokeResult { return client.InvokeResult{} }
+
+	res, err := testCall(c, adminPrefix+".maintenance", wamp.List{true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Arguments[0] != true {
+		t.Errorf("expected maintenance mode on, got %v", res.Arguments[0])
+	}
+	err = c.Publish("test.topic", ack, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), string(wamp.ErrAuthorizationFailed)) || !strings.Contains(err.Error(), "maintenance") {
+		t.Errorf("expected a maintenance error for a publish, got %v", err)
+	}
+	if err = c.Register("test.proc", noop, nil); err == nil || !strings.Contains(err.Error(), "maintenance") {
+		t.Errorf("expected a maintenance error for a register, got %v", err)
+	}
+	if err = c.Subscribe("test.topic", func(*wamp.Event) {}, nil); err != nil {
+		t.Errorf("subscribe failed in maintenance mode: %s", err)
+	}
+	if res, err = testCall(c, adminPrefix+".maintenance", nil, nil); err != nil || res.Arguments[0] != true {
+		t.Errorf("call failed in maintenance mode: %v %v", res, err)
+	}
+
+	if _, err = testCall(c, adminPrefix+".maintenance", wamp.List{false}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Publish("test.topic", ack, nil, nil); err != nil {
+		t.Errorf("publish failed after maintenance mode: %s", err)
+	}
+	if err = c.Register("test.proc", noop, nil); err != nil {
+		t.Errorf("register failed after maintenance mode: %s", err)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// matchPolicies maps realms to the match policies their subscriptions and
+// registrations may use.  Realms missing from it allow all of them.
+var matchPolicies = map[wamp.URI]map[string]bool{}
+
+// parseMatchPolicies merges a comma separated list of realm=policy+policy
+// entries into matchPolicies.
+func parseMatchPolicies(s string) error {
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		uri, list, ok := strings.Cut(entry, "=")
+		if !ok || !wamp.URI(uri).ValidURI(false, "") || list == "" {
+			return fmt.Errorf("invalid realm match policies %q, expected realm=policy+policy", entry)
+		}
+		allowed := map[string]bool{}
+		for _, policy := range strings.Split(list, "+") {
+			switch policy {
+			case wamp.MatchExact, wamp.MatchPrefix, wamp.MatchWildcard:
+				allowed[policy] = true
+			default:
+				return fmt.Errorf("invalid match policy %q in %q, expected exact, prefix or wildcard", policy, entry)
+			}
+		}
+		matchPolicies[wamp.URI(uri)] = allowed
+	}
+	return nil
+}
+
+// realmMatchPolicy restricts the match policies of the subscriptions and
+// registrations of a realm.
+type realmMatchPolicy struct {
+	realm   wamp.URI
+	allowed map[string]bool
+}
+
+// newRealmMatchPolicy returns the match policy restriction of the realm, or
+// nil if it has none.
+func newRealmMatchPolicy(uri wamp.URI) *realmMatchPolicy {
+	allowed, ok := matchPolicies[uri]
+	if !ok {
+		return nil
+	}
+	return &realmMatchPolicy{realm: uri, allowed: allowed}
+}
+
+// check returns an error for subscribes and registers with a match policy the
+// realm does not allow.  Other messages pass.  A nil restriction allows every
+// policy.
+func (p *realmMatchPolicy) check(msg wamp.Message) error {
+	if p == nil {
+		return nil
+	}
+	var options wamp.Dict
+	var what string
+	switch msg := msg.(type) {
+	case *wamp.Subscribe:
+		options, what = msg.Options, "subscriptions"
+	case *wamp.Register:
+		options, what = msg.Options, "registrations"
+	default:
+		return nil
+	}
+	policy, _ := wamp.AsString(options[wamp.OptMatch])
+	if policy == "" {
+		policy = wamp.MatchExact
+	}
+	if !p.allowed[policy] {
+		return fmt.Errorf("match_policy_forbidden: %s %s are not allowed in realm %s", policy, what, p.realm)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestParseMatchPolicies(t *testing.T) {
+	defer func() { matchPolicies = map[wamp.URI]map[string]bool{} }()
+	if err := parseMatchPolicies("tenant=exact+prefix, other=wildcard"); err != nil {
+		t.Fatal(err)
+	}
+	if p := matchPolicies["tenant"]; !p["exact"] || !p["prefix"] || p["wildcard"] {
+		t.Errorf("unexpected tenant policies %v", p)
+	}
+	if p := matchPolicies["other"]; len(p) != 1 || !p["wildcard"] {
+		t.Errorf("unexpected other policies %v", p)
+	}
+	for _, s := range []string{"tenant", "tenant=", "tenant=exact+regex", "=exact"} {
+		if err := parseMatchPolicies(s); err == nil {
+			t.Errorf("expected an error for %q", s)
+		}
+	}
+}
+
+func TestRealmMatchPolicy(t *testing.T) {
+	defer func() { matchPolicies = map[wamp.URI]map[string]bool{} }()
+	matchPolicies = map[wamp.URI]map[string]bool{"tenant": {"exact": true, "prefix": true}}
+
+	url := startTestRouter(t, "tenant")
+	c := connectTestClient(t, url, "tenant")
+	handler := func(*wamp.Event) {}
+	noop := func(context.Context, *wamp.Invocation) client.InvokeResult { return client.InvokeResult{} }
+
+	err := c.Subscribe("test.", handler, wamp.Dict{wamp.OptMatch: wamp.MatchWildcard})
+	if err == nil || !strings.Contains(err.Error(), "match_policy_forbidden") {
+		t.Errorf("expected a wildcard subscription to be rejected, got %v", err)
+	}
+	if err = c.Register("test..proc", noop, wamp.Dict{wamp.OptMatch: wamp.MatchWildcard}); err == nil {
+		t.Error("expected a wildcard registration to be rejected")
+	}
+	if err = c.Subscribe("test.topic", handler, nil); err != nil {
+		t.Errorf("exact subscription rejected: %s", err)
+	}
+	if err = c.Subscribe("test.prefix", handler, wamp.Dict{wamp.OptMatch: wamp.MatchPrefix}); err != nil {
+		t.Errorf("prefix subscription rejected: %s", err)
+	}
+
+	// Realms without a restriction allow every policy.
+	other := connectTestClient(t, url, realm)
+	if err = other.Subscribe("test..topic", handler, wamp.Dict{wamp.OptMatch: wamp.MatchWildcard}); err != nil {
+		t.Errorf("wildcard subscription rejected in an unrestricted realm: %s", err)
+	}
+}
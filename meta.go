@@ -0,0 +1,86 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+var (
+	metaMu       sync.Mutex
+	metaHandlers = map[wamp.URI][]client.EventHandler{}
+	// realmMetaHandlers holds the meta event handlers of realms other than
+	// the default one, by realm and topic.
+	realmMetaHandlers = map[wamp.URI]map[wamp.URI][]client.EventHandler{}
+)
+
+// onMetaEvent adds a handler for a meta event topic.  Features that need meta
+// events register their handlers when they are enabled, and the local client
+// holds a single subscription per topic that has at least one handler, so a
+// minimal router receives no meta events at all.
+func onMetaEvent(topic wamp.URI, handler client.EventHandler) error {
+	metaMu.Lock()
+	_, subscribed := metaHandlers[topic]
+	metaHandlers[topic] = append(metaHandlers[topic], handler)
+	metaMu.Unlock()
+	if subscribed {
+		return nil
+	}
+	return createLocalSubscriber(string(topic), func(event *wamp.Event) {
+		dispatchMetaEvent(topic, event)
+	}, nil)
+}
+
+// onRealmMetaEvent adds a handler for a meta event topic of a realm.  Events
+// of realms other than the default one are received by their realm client,
+// again with a single subscription per topic.
+func onRealmMetaEvent(uri, topic wamp.URI, handler client.EventHandler) error {
+	if uri == wamp.URI(realm) {
+		return onMetaEvent(topic, handler)
+	}
+	c, err := realmClient(uri)
+	if err != nil {
+		return err
+	}
+	metaMu.Lock()
+	handlers := realmMetaHandlers[uri]
+	if handlers == nil {
+		handlers = map[wamp.URI][]client.EventHandler{}
+		realmMetaHandlers[uri] = handlers
+	}
+	_, subscribed := handlers[topic]
+	handlers[topic] = append(handlers[topic], handler)
+	metaMu.Unlock()
+	if subscribed {
+		return nil
+	}
+	return c.Subscribe(string(topic), func(event *wamp.Event) {
+		metaMu.Lock()
+		handlers := realmMetaHandlers[uri][topic]
+		metaMu.Unlock()
+		for _, handler := range handlers {
+			handler(event)
+		}
+	}, nil)
+}
+
+func dispatchMetaEvent(topic wamp.URI, event *wamp.Event) {
+	metaMu.Lock()
+	handlers := metaHandlers[topic]
+	metaMu.Unlock()
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
+
+// metaTopics returns the meta event topics the local client is subscribed to.
+func metaTopics() wamp.List {
+	metaMu.Lock()
+	defer metaMu.Unlock()
+	topics := make(wamp.List, 0, len(metaHandlers))
+	for topic := range metaHandlers {
+		topics = append(topics, topic)
+	}
+	return topics
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// lookupSubscription returns whether the realm has a subscription on topic.
+func lookupSubscription(t *testing.T, topic wamp.URI) bool {
+	t.Helper()
+	res, err := testCall(getLocalClient(), string(wamp.MetaProcSubLookup), wamp.List{topic}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := wamp.AsID(res.Arguments[0])
+	return id != 0
+}
+
+func TestNoMetaSubscriptionsByDefault(t *testing.T) {
+	startTestRouter(t)
+	if topics := metaTopics(); len(topics) != 0 {
+		t.Errorf("expected no meta subscriptions, got %v", topics)
+	}
+	for _, topic := range []wamp.URI{wamp.MetaEventSessionOnJoin, wamp.MetaEventSessionOnLeave} {
+		if lookupSubscription(t, topic) {
+			t.Errorf("unexpected subscription on %s", topic)
+		}
+	}
+}
+
+func TestMetaSubscriptionShared(t *testing.T) {
+	startTestRouter(t)
+	for i := 0; i < 2; i++ {
+		if err := watchSessionLeaves(wamp.URI(realm)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	topics := metaTopics()
+	if len(topics) != 1 || topics[0] != wamp.MetaEventSessionOnLeave {
+		t.Errorf("expected a single on_leave topic, got %v", topics)
+	}
+	if !lookupSubscription(t, wamp.MetaEventSessionOnLeave) {
+		t.Error("expected a subscription on on_leave")
+	}
+	if len(localSubscribers) != 1 {
+		t.Errorf("expected one local subscription shared by the handlers, got %d", len(localSubscribers))
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// metaRoles holds the -meta-roles authroles allowed to call the meta
+// procedures and subscribe to the meta events, or nil to allow every role.
+var metaRoles map[string]bool
+
+// parseMetaRoles parses the comma separated authroles of -meta-roles.  An
+// empty list allows every role.
+func parseMetaRoles(s string) map[string]bool {
+	var roles map[string]bool
+	for _, role := range strings.Split(s, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			if roles == nil {
+				roles = map[string]bool{}
+			}
+			roles[role] = true
+		}
+	}
+	return roles
+}
+
+// allowedMeta reports whether the session may send msg when it calls a meta
+// procedure or subscribes to meta events, whose URIs start with wamp.
+// Pattern subscriptions that can match meta events count as meta ones.
+// Other messages are left to the rest of the authorizer.
+func allowedMeta(sess *wamp.Session, msg wamp.Message) bool {
+	if metaRoles == nil {
+		return true
+	}
+	meta := false
+	switch msg := msg.(type) {
+	case *wamp.Call:
+		meta = strings.HasPrefix(string(msg.Procedure), "wamp.")
+	case *wamp.Subscribe:
+		meta = matchesMeta(msg.Topic, msg.Options)
+	}
+	if !meta {
+		return true
+	}
+	authrole, _ := wamp.AsString(sess.Details["authrole"])
+	return metaRoles[authrole]
+}
+
+// matchesMeta reports whether a subscription to topic with the options can
+// receive meta events.
+func matchesMeta(topic wamp.URI, options wamp.Dict) bool {
+	t := string(topic)
+	switch wamp.OptionString(options, wamp.OptMatch) {
+	case wamp.MatchPrefix:
+		return strings.HasPrefix(t, "wamp.") || strings.HasPrefix("wamp.", t)
+	case wamp.MatchWildcard:
+		first, _, _ := strings.Cut(t, ".")
+		return first == "" || first == "wamp"
+	}
+	return strings.HasPrefix(t, "wamp.")
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestMetaRoles(t *testing.T) {
+	savedRoles, savedTickets := metaRoles, tickets
+	defer func() { metaRoles, tickets = savedRoles, savedTickets }()
+	metaRoles = parseMetaRoles(" ops, " + adminRole)
+	if !metaRoles["ops"] || !metaRoles[adminRole] || len(metaRoles) != 2 {
+		t.Fatalf("unexpected meta roles %v", metaRoles)
+	}
+	tickets = ticketStore{"alice": {Ticket: "a", Role: adminRole}, "bob": {Ticket: "b", Role: "user"}}
+
+	url := startTestRouter(t)
+	err := createLocalCallee(getLocalClient(), "test.open", func(context.Context, *wamp.Invocation) client.InvokeResult {
+		return client.InvokeResult{}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	join := func(authid string) *client.Client {
+		c, err := client.ConnectNet(context.Background(), url, client.Config{
+			Realm:        realm,
+			Logger:       logger,
+			HelloDetails: wamp.Dict{"authid": authid},
+			AuthHandlers: map[string]client.AuthFunc{
+				"ticket": func(*wamp.Challenge) (string, wamp.Dict) { return tickets[authid].Ticket, wamp.Dict{} },
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { c.Close() })
+		return c
+	}
+	alice, bob := join("alice"), join("bob")
+
+	if _, err := testCall(alice, string(wamp.MetaProcSessionList), nil, nil); err != nil {
+		t.Errorf("expected the admin role to list sessions, got %v", err)
+	}
+	_, err = testCall(bob, string(wamp.MetaProcSessionList), nil, nil)
+	if uri := errorURI(err); uri != wamp.ErrNotAuthorized {
+		t.Errorf("expected %s for the user role, got %v", wamp.ErrNotAuthorized, err)
+	}
+	if _, err = testCall(bob, "test.open", nil, nil); err != nil {
+		t.Errorf("expected other procedures to stay callable, got %v", err)
+	}
+	if err = bob.Subscribe(string(wamp.MetaEventSessionOnJoin), func(*wamp.Event) {}, nil); err == nil {
+		t.Error("expected the user role to be denied meta events")
+	}
+	if err = alice.Subscribe(string(wamp.MetaEventSessionOnJoin), func(*wamp.Event) {}, nil); err != nil {
+		t.Errorf("expected the admin role to get meta events, got %v", err)
+	}
+	authz := &authorizer{adminRole: adminRole}
+	if permissions, _ := wamp.AsDict(authz.whoami(&wamp.Session{Details: wamp.Dict{"authrole": "user"}})["permissions"]); permissions["meta"] != false {
+		t.Errorf("expected whoami to deny the meta API to the user role, got %v", permissions)
+	}
+}
+
+func TestMatchesMeta(t *testing.T) {
+	for _, c := range []struct {
+		topic string
+		match string
+		want  bool
+	}{
+		{"wamp.session.on_join", "", true},
+		{"app.wamp", "", false},
+		{"wamp", wamp.MatchPrefix, true},
+		{"wamp.session", wamp.MatchPrefix, true},
+		{"app", wamp.MatchPrefix, false},
+		{".session.on_join", wamp.MatchWildcard, true},
+		{"wamp..on_join", wamp.MatchWildcard, true},
+		{"app..update", wamp.MatchWildcard, false},
+	} {
+		if got := matchesMeta(wamp.URI(c.topic), wamp.Dict{wamp.OptMatch: c.match}); got != c.want {
+			t.Errorf("%s with match %q: expected %v, got %v", c.topic, c.match, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gammazero/nexus/v3/transport/serialize"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// sizeWarnInterval is the minimum time between two large message warnings.
+const sizeWarnInterval = time.Second
+
+// largeMessageCount is the number of messages received from remote sessions
+// that were larger than warnMsgSize.
+var largeMessageCount uint64
+
+var (
+	sizeWarnMu sync.Mutex
+	// lastSizeWarn is when the last large message warning was logged, and
+	// suppressedSizeWarns the number of warnings skipped since.
+	lastSizeWarn        time.Time
+	suppressedSizeWarns int
+)
+
+// checkMessageSize counts and logs messages larger than warnMsgSize.  The
+// size is that of the message encoded as JSON, whatever serializer the session
+// uses.  Nothing is enforced, the message is delivered as usual.
+func checkMessageSize(sess *wamp.Session, msg wamp.Message) {
+	if warnMsgSize <= 0 {
+		return
+	}
+	data, err := (&serialize.JSONSerializer{}).Serialize(msg)
+	if err != nil || len(data) <= warnMsgSize {
+		return
+	}
+	atomic.AddUint64(&largeMessageCount, 1)
+
+	sizeWarnMu.Lock()
+	defer sizeWarnMu.Unlock()
+	if time.Since(lastSizeWarn) < sizeWarnInterval {
+		suppressedSizeWarns++
+		return
+	}
+	logger.Printf("large %s message from session %d: %d bytes, over %d (%d warnings suppressed)\n",
+		msg.MessageType(), sess.ID, len(data), warnMsgSize, suppressedSizeWarns)
+	lastSizeWarn = time.Now()
+	suppressedSizeWarns = 0
+}
+
+// topicSizes maps topics to the maximum size of their publish payload.
+var topicSizes = map[wamp.URI]int{}
+
+// parseTopicSizes merges a comma separated list of topic=bytes pairs into
+// topicSizes.
+func parseTopicSizes(s string) error {
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		topic, size, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("invalid topic size limit %q, expected topic=bytes", pair)
+		}
+		if !wamp.URI(topic).ValidURI(false, "") {
+			return fmt.Errorf("invalid topic in size limit %q", pair)
+		}
+		n, err := strconv.Atoi(size)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid size in size limit %q", pair)
+		}
+		topicSizes[wamp.URI(topic)] = n
+	}
+	return nil
+}
+
+// checkTopicSize is a publishCheck rejecting payloads larger than the limit
+// of their topic.  The payload size is that of the args and kwargs encoded as
+// JSON.
+func checkTopicSize(topic wamp.URI, args wamp.List, kwargs wamp.Dict) error {
+	limit, ok := topicSizes[topic]
+	if !ok {
+		return nil
+	}
+	size := 0
+	for _, v := range []interface{}{args, kwargs} {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("cannot encode payload: %s", err)
+		}
+		size += len(data)
+	}
+	if size > limit {
+		return fmt.Errorf("payload of %d bytes exceeds the limit of %d for %s", size, limit, topic)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestWarnMsgSize(t *testing.T) {
+	savedSize, savedLogger := warnMsgSize, logger
+	defer func() { warnMsgSize, logger = savedSize, savedLogger }()
+	warnMsgSize = 256
+
+	url := startTestRouter(t)
+	w := &countingWriter{match: "large PUBLISH message from session"}
+	logger = log.New(w, "", 0)
+	lastSizeWarn = time.Time{}
+	before := atomic.LoadUint64(&largeMessageCount)
+
+	sub := connectTestClient(t, url, realm)
+	events := make(chan *wamp.Event, 2)
+	if err := sub.SubscribeChan("test.size", events, nil); err != nil {
+		t.Fatal(err)
+	}
+	pub := connectTestClient(t, url, realm)
+	for _, payload := range []string{"small", strings.Repeat("x", 1024)} {
+		if err := pub.Publish("test.size", nil, wamp.List{payload}, nil); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case event := <-events:
+			if event.Arguments[0] != payload {
+				t.Errorf("unexpected payload of %d bytes", len(payload))
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("message of %d bytes was not delivered", len(payload))
+		}
+	}
+	if n := atomic.LoadUint64(&largeMessageCount) - before; n != 1 {
+		t.Errorf("expected 1 large message, got %d", n)
+	}
+	if n := atomic.LoadInt32(&w.count); n != 1 {
+		t.Errorf("expected 1 warning, got %d", n)
+	}
+}
+
+func TestTopicSizeLimits(t *testing.T) {
+	saved := topicSizes
+	defer func() { topicSizes = saved }()
+	topicSizes = map[wamp.URI]int{}
+	if err := parseTopicSizes("test.limited=64"); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"test.limited", "test.limited=0", "bad..topic=10"} {
+		if err := parseTopicSizes(s); err == nil {
+			t.Errorf("expected an error for %q", s)
+		}
+	}
+
+	url := startTestRouter(t)
+	sub := connectTestClient(t, url, realm)
+	events := make(chan *wamp.Event, 3)
+	for _, topic := range []string{"test.limited", "test.free"} {
+		if err := sub.SubscribeChan(topic, events, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pub := connectTestClient(t, url, realm)
+	ack := wamp.Dict{wamp.OptAcknowledge: true}
+	large := wamp.List{strings.Repeat("x", 100)}
+
+	if err := pub.Publish("test.limited", ack, large, nil); err == nil || !strings.Contains(err.Error(), string(wamp.ErrAuthorizationFailed)) {
+		t.Errorf("expected the oversized publish to be rejected, got %v", err)
+	}
+	for _, p := range []struct {
+		topic string
+		args  wamp.List
+	}{
+		{"test.limited", wamp.List{"small"}},
+		{"test.free", large},
+	} {
+		if err := pub.Publish(p.topic, ack, p.args, nil); err != nil {
+			t.Errorf("%s: expected the publish to succeed, got %v", p.topic, err)
+		}
+		select {
+		case event := <-events:
+			if event.Arguments[0] != p.args[0] {
+				t.Errorf("unexpected event %v", event.Arguments)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: publish not delivered", p.topic)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// onJoinTimeout bounds the call of the -on-join-proc procedure.
+const onJoinTimeout = 10 * time.Second
+
+// pendingWelcome is the welcome event of a joined session, sent once the
+// payload is known and the session subscribed to -on-join-topic.
+type pendingWelcome struct {
+	args       wamp.List
+	kwargs     wamp.Dict
+	ready      bool
+	subscribed bool
+}
+
+var (
+	welcomesMu sync.Mutex
+	// welcomes holds the sessions of the default realm that have not got
+	// their welcome event yet.
+	welcomes = map[wamp.ID]*pendingWelcome{}
+)
+
+// watchJoins calls -on-join-proc with the details of each remote session
+// joining the default realm and, with -on-join-topic, sends the session the
+// result, or its details without a procedure, as a welcome event.  A session
+// cannot receive events before it subscribes, so the event is sent when the
+// session subscribes to the topic, with the session as the only eligible
+// receiver.
+func watchJoins() error {
+	if err := onMetaEvent(wamp.MetaEventSessionOnJoin, welcomeJoined); err != nil {
+		return err
+	}
+	if onJoinTopic == "" {
+		return nil
+	}
+	if err := onMetaEvent(wamp.MetaEventSubOnSubscribe, welcomeSubscribed); err != nil {
+		return err
+	}
+	return onMetaEvent(wamp.MetaEventSessionOnLeave, func(event *wamp.Event) {
+		if len(event.Arguments) > 0 {
+			if sid, ok := wamp.AsID(event.Arguments[0]); ok {
+				welcomesMu.Lock()
+				delete(welcomes, sid)
+				welcomesMu.Unlock()
+			}
+		}
+	})
+}
+
+// welcomeJoined handles the on_join meta event.
+func welcomeJoined(event *wamp.Event) {
+	if len(event.Arguments) == 0 {
+		return
+	}
+	details, _ := wamp.AsDict(event.Arguments[0])
+	sid, _ := wamp.AsID(details["session"])
+	if sid == 0 || details["authmethod"] == "local" {
+		return
+	}
+	if onJoinTopic != "" {
+		welcomesMu.Lock()
+		welcomes[sid] = &pendingWelcome{}
+		welcomesMu.Unlock()
+	}
+	// The meta event handlers must not block the local client on a call.
+	go func() {
+		args, kwargs := wamp.List{details}, wamp.Dict(nil)
+		if onJoinProc != "" {
+			ctx, cancel := context.WithTimeout(context.Background(), onJoinTimeout)
+			res, err := localCall(ctx, wamp.URI(onJoinProc), wamp.List{details}, nil)
+			cancel()
+			if err != nil {
+				logger.Printf("on join: %s failed for session %d: %s\n", onJoinProc, sid, err)
+				welcomesMu.Lock()
+				delete(welcomes, sid)
+				welcomesMu.Unlock()
+				return
+			}
+			args, kwargs = res.Arguments, res.ArgumentsKw
+		}
+		if onJoinTopic == "" {
+			return
+		}
+		welcomesMu.Lock()
+		defer welcomesMu.Unlock()
+		if w := welcomes[sid]; w != nil {
+			w.args, w.kwargs, w.ready = args, kwargs, true
+			sendWelcome(sid, w)
+		}
+	}()
+}
+
+// welcomeSubscribed handles the on_subscribe meta event, marking sessions
+// waiting for a welcome event as subscribed when the subscription is the one
+// of -on-join-topic.
+func welcomeSubscribed(event *wamp.Event) {
+	if len(event.Arguments) < 2 {
+		return
+	}
+	sid, _ := wamp.AsID(event.Arguments[0])
+	subID, _ := wamp.AsID(event.Arguments[1])
+	welcomesMu.Lock()
+	_, pending := welcomes[sid]
+	welcomesMu.Unlock()
+	if !pending {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), onJoinTimeout)
+		res, err := localCall(ctx, wamp.MetaProcSubGet, wamp.List{subID}, nil)
+		cancel()
+		if err != nil {
+			return
+		}
+		sub, _ := wamp.AsDict(res.Arguments[0])
+		if uri, _ := wamp.AsURI(sub["uri"]); uri != wamp.URI(onJoinTopic) {
+			return
+		}
+		welcomesMu.Lock()
+		defer welcomesMu.Unlock()
+		if w := welcomes[sid]; w != nil {
+			w.subscribed = true
+			sendWelcome(sid, w)
+		}
+	}()
+}
+
+// sendWelcome publishes the welcome event to the session once it is ready
+// and subscribed.  welcomesMu must be held.
+func sendWelcome(sid wamp.ID, w *pendingWelcome) {
+	if !w.ready || !w.subscribed {
+		return
+	}
+	delete(welcomes, sid)
+	options := wamp.Dict{wamp.WhitelistKey: wamp.List{sid}}
+	if err := getLocalClient().Publish(onJoinTopic, options, w.args, w.kwargs); err != nil {
+		logger.Printf("on join: failed to publish welcome to session %d: %s\n", sid, err)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+	"github.com/gorilla/websocket"
+)
+
+// rawSession is a WAMP session driven over a bare WebSocket connection.  The
+// nexus client registers event handlers only after handling the SUBSCRIBED
+// reply, so it can miss an event sent right after it, such as a welcome.
+type rawSession struct {
+	t    *testing.T
+	conn *websocket.Conn
+	id   wamp.ID
+}
+
+// joinRaw joins the default realm with a bare WebSocket connection.
+func joinRaw(t *testing.T, url string) *rawSession {
+	t.Helper()
+	dialer := websocket.Dialer{Subprotocols: []string{"wamp.2.json"}}
+	conn, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	s := &rawSession{t: t, conn: conn}
+	s.send(1, realm, map[string]interface{}{"roles": map[string]interface{}{"subscriber": map[string]interface{}{}}})
+	welcome := s.read(2)
+	s.id = wamp.ID(welcome[1].(float64))
+	return s
+}
+
+func (s *rawSession) send(msg ...interface{}) {
+	s.t.Helper()
+	if err := s.conn.WriteJSON(msg); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+// next returns the next message, which must not be empty.
+func (s *rawSession) next() []interface{} {
+	s.t.Helper()
+	s.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, data, err := s.conn.ReadMessage()
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	var msg []interface{}
+	if err = json.Unmarshal(data, &msg); err != nil {
+		s.t.Fatal(err)
+	}
+	if len(msg) == 0 {
+		s.t.Fatalf("empty message %s", data)
+	}
+	return msg
+}
+
+// read returns the next message, which must be of the message type.
+func (s *rawSession) read(msgType float64) []interface{} {
+	s.t.Helper()
+	msg := s.next()
+	if msg[0] != msgType {
+		s.t.Fatalf("expected message type %v, got %v", msgType, msg)
+	}
+	return msg
+}
+
+// subscribeWelcome subscribes to -on-join-topic and returns the welcome
+// event as [36, subscription, publication, details, args, kwargs].
+func (s *rawSession) subscribeWelcome() []interface{} {
+	s.t.Helper()
+	s.send(32, 1, map[string]interface{}{}, onJoinTopic)
+	s.read(33)
+	return s.read(36)
+}
+
+func TestOnJoinWelcome(t *testing.T) {
+	savedProc, savedTopic := onJoinProc, onJoinTopic
+	t.Cleanup(func() { onJoinProc, onJoinTopic = savedProc, savedTopic })
+	onJoinProc, onJoinTopic = "test.bootstrap", "test.welcome"
+
+	url := startTestRouter(t)
+	welcomes = map[wamp.ID]*pendingWelcome{}
+	err := createLocalCallee(getLocalClient(), onJoinProc, func(_ context.Context, inv *wamp.Invocation) client.InvokeResult {
+		details, _ := wamp.AsDict(inv.Arguments[0])
+		return client.InvokeResult{Args: wamp.List{details["session"]}, Kwargs: wamp.Dict{"config": "v1"}}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = watchJoins(); err != nil {
+		t.Fatal(err)
+	}
+
+	first := joinRaw(t, url)
+	event := first.subscribeWelcome()
+	args, kwargs := event[4].([]interface{}), event[5].(map[string]interface{})
+	if wamp.ID(args[0].(float64)) != first.id || kwargs["config"] != "v1" {
+		t.Errorf("unexpected welcome event %v", event)
+	}
+
+	// The welcome of a later session is only sent to it.
+	second := joinRaw(t, url)
+	event = second.subscribeWelcome()
+	if args := event[4].([]interface{}); wamp.ID(args[0].(float64)) != second.id {
+		t.Errorf("expected the welcome of session %d, got %v", second.id, event)
+	}
+	first.conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, data, err := first.conn.ReadMessage(); err == nil {
+		t.Errorf("unexpected message after the welcome: %s", data)
+	}
+}
+
+func TestOnJoinDetails(t *testing.T) {
+	savedTopic, savedID := onJoinTopic, anonAuthID
+	t.Cleanup(func() { onJoinTopic, anonAuthID = savedTopic, savedID })
+	onJoinTopic, anonAuthID = "test.welcome", "sensor-1"
+
+	url := startTestRouter(t)
+	welcomes = map[wamp.ID]*pendingWelcome{}
+	if err := watchJoins(); err != nil {
+		t.Fatal(err)
+	}
+	event := joinRaw(t, url).subscribeWelcome()
+	details := event[4].([]interface{})[0].(map[string]interface{})
+	if details["authid"] != "sensor-1" {
+		t.Errorf("expected the session details, got %v", event)
+	}
+	welcomesMu.Lock()
+	defer welcomesMu.Unlock()
+	if len(welcomes) != 0 {
+		t.Errorf("expected no pending welcomes, got %v", welcomes)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// httpClient sends the requests of the proxy procedures.  main replaces it
+// with the client configured by -http-proxy, -http-ca-file and
+// -http-insecure-skip-verify.
+var httpClient = &http.Client{Transport: http.DefaultTransport}
+
+// newHTTPClient returns the client of outbound requests.  Without proxyURL,
+// requests go through the proxies of the HTTP_PROXY and HTTPS_PROXY
+// environment variables; with it, every request but those matching noProxy,
+// a NO_PROXY list, goes through proxyURL.  The certificates of caFile are
+// trusted in addition to the system ones.
+func newHTTPClient(proxyURL, noProxy, caFile string, skipVerify bool) (*http.Client, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if proxyURL != "" {
+		u, err := url.Parse(proxyURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid outbound HTTP proxy %q, expected an http or https URL", proxyURL)
+		}
+		rules := strings.Split(noProxy, ",")
+		t.Proxy = func(req *http.Request) (*url.URL, error) {
+			if bypassProxy(req.URL, rules) {
+				return nil, nil
+			}
+			return u, nil
+		}
+	}
+	if caFile != "" || skipVerify {
+		t.TLSClientConfig = &tls.Config{InsecureSkipVerify: skipVerify}
+	}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %q", caFile)
+		}
+		t.TLSClientConfig.RootCAs = pool
+	}
+	return &http.Client{Transport: t}, nil
+}
+
+// bypassProxy returns whether requests to the URL skip -http-proxy: for
+// localhost, loopback addresses and hosts matching a NO_PROXY rule.  Rules are
+// *, hosts that also match their subdomains, with an optional leading dot,
+// IP addresses and CIDR ranges, each optionally with a port.
+func bypassProxy(u *url.URL, rules []string) bool {
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	ip := net.ParseIP(host)
+	if host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return true
+	}
+	for _, rule := range rules {
+		rule = strings.ToLower(strings.TrimSpace(rule))
+		if rule == "*" {
+			return true
+		}
+		if h, p, err := net.SplitHostPort(rule); err == nil {
+			if p != port {
+				continue
+			}
+			rule = h
+		}
+		if rule == "" {
+			continue
+		}
+		if _, cidr, err := net.ParseCIDR(rule); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		rule = strings.TrimPrefix(rule, ".")
+		if host == rule || strings.HasSuffix(host, "."+rule) {
+			return true
+		}
+	}
+	return false
+}
+
+// outboundProxy returns the proxy httpClient sends requests to the URL
+// through, nil for direct requests.
+func outboundProxy(u *url.URL) (*url.URL, error) {
+	t, _ := httpClient.Transport.(*http.Transport)
+	if t == nil || t.Proxy == nil {
+		return nil, nil
+	}
+	return t.Proxy(&http.Request{URL: u})
+}
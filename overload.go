@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+var (
+	realmClientsMu sync.Mutex
+	// realmClients are local clients used for meta API access to realms other
+	// than the one of the local client.
+	realmClients = map[wamp.URI]*client.Client{}
+
+	sessionsMu sync.Mutex
+	// realmSessions holds the remote sessions admitted to each realm, with the
+	// time they were admitted.  They are added when they authenticate and
+	// removed when they leave.
+	realmSessions = map[wamp.URI]map[wamp.ID]time.Time{}
+
+	// admitGrace is the time an admitted session has to finish joining before
+	// it is dropped for missing from the realm's session list.
+	admitGrace = 10 * time.Second
+)
+
+// realmClient returns a local client joined to the realm.
+func realmClient(uri wamp.URI) (*client.Client, error) {
+	if uri == wamp.URI(realm) {
+		return getLocalClient(), nil
+	}
+	realmClientsMu.Lock()
+	defer realmClientsMu.Unlock()
+	if c, ok := realmClients[uri]; ok && c.Connected() {
+		return c, nil
+	}
+	c, err := client.ConnectLocal(wsRouter, client.Config{Realm: string(uri), Logger: logger})
+	if err != nil {
+		return nil, err
+	}
+	realmClients[uri] = c
+	return c, nil
+}
+
+// realmSessionIDs asks the realm meta API for the sessions joined to the
+// realm, not counting the local client used to ask for them.
+func realmSessionIDs(ctx context.Context, uri wamp.URI) (map[wamp.ID]struct{}, error) {
+	c, err := realmClient(uri)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.Call(ctx, string(wamp.MetaProcSessionList), nil, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	list, _ := wamp.AsList(res.Arguments[0])
+	ids := make(map[wamp.ID]struct{}, len(list))
+	for _, v := range list {
+		if id, ok := wamp.AsID(v); ok && id != c.ID() {
+			ids[id] = struct{}{}
+		}
+	}
+	return ids, nil
+}
+
+// realmSessionCount returns the number of sessions in the realm, not counting
+// the local client used to ask for it.
+func realmSessionCount(ctx context.Context, uri wamp.URI) (int64, error) {
+	ids, err := realmSessionIDs(ctx, uri)
+	return int64(len(ids)), err
+}
+
+// watchSessionLeaves removes sessions of the realm from realmSessions when
+// they leave.
+func watchSessionLeaves(uri wamp.URI) error {
+	handler := func(event *wamp.Event) {
+		if len(event.Arguments) == 0 {
+			return
+		}
+		if id, ok := wamp.AsID(event.Arguments[0]); ok {
+			sessionsMu.Lock()
+			delete(realmSessions[uri], id)
+			sessionsMu.Unlock()
+		}
+	}
+	return onRealmMetaEvent(uri, wamp.MetaEventSessionOnLeave, handler)
+}
+
+// admitSession adds the session to the realm, or returns an error with a
+// retry hint when the realm is at its session limit.  The check and the add
+// happen under one lock so concurrent joins cannot exceed the limit.
+//
+// Sessions that leave without an on_leave event, such as those disconnected
+// by wamp.session.kill_all or failing to join after authentication, are
+// dropped by checking the realm's session list before a join is refused.
+func admitSession(uri wamp.URI, sid wamp.ID) error {
+	if maxSessions <= 0 {
+		return nil
+	}
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	sessions := realmSessions[uri]
+	if sessions == nil {
+		sessions = map[wamp.ID]time.Time{}
+		realmSessions[uri] = sessions
+	}
+	if len(sessions) >= maxSessions {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		joined, err := realmSessionIDs(ctx, uri)
+		cancel()
+		if err != nil {
+			logger.Printf("failed to list sessions of %s: %s\n", uri, err)
+		} else {
+			for id, admitted := range sessions {
+				if _, ok := joined[id]; !ok && time.Since(admitted) > admitGrace {
+					delete(sessions, id)
+				}
+			}
+		}
+	}
+	if len(sessions) >= maxSessions {
+		return &abortError{abortSessionLimit, fmt.Errorf("realm %s is overloaded at its limit of %d sessions, retry after %s", uri, maxSessions, retryAfter)}
+	}
+	sessions[sid] = time.Now()
+	return nil
+}
+
+// forgetRealm drops the session tracking of a removed realm.
+func forgetRealm(uri wamp.URI) {
+	sessionsMu.Lock()
+	delete(realmSessions, uri)
+	sessionsMu.Unlock()
+	uniqueMu.Lock()
+	delete(uniqueSessions, uri)
+	uniqueMu.Unlock()
+	realmClientsMu.Lock()
+	delete(realmClients, uri)
+	realmClientsMu.Unlock()
+	metaMu.Lock()
+	delete(realmMetaHandlers, uri)
+	metaMu.Unlock()
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestMaxSessions(t *testing.T) {
+	saved := maxSessions
+	defer func() { maxSessions = saved }()
+	maxSessions = 2
+
+	url := startTestRouter(t, "other")
+	for _, uri := range []wamp.URI{wamp.URI(realm), "other"} {
+		if err := watchSessionLeaves(uri); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first := connectTestClient(t, url, realm)
+	connectTestClient(t, url, realm)
+	_, err := client.ConnectNet(context.Background(), url, client.Config{Realm: realm, Logger: logger})
+	if err == nil || !strings.Contains(err.Error(), "retry after "+retryAfter.String()) {
+		t.Fatalf("expected the join to be refused with a retry hint, got %v", err)
+	}
+	// The limit is per realm.
+	connectTestClient(t, url, "other")
+
+	first.Close()
+	if !waitFor(t, 5*time.Second, func() bool {
+		c, err := client.ConnectNet(context.Background(), url, client.Config{Realm: realm, Logger: logger})
+		if err != nil {
+			return false
+		}
+		t.Cleanup(func() { c.Close() })
+		return true
+	}) {
+		t.Error("join still refused after a session left")
+	}
+}
+
+func TestMaxSessionsConcurrent(t *testing.T) {
+	saved := maxSessions
+	defer func() { maxSessions = saved }()
+	maxSessions = 3
+
+	url := startTestRouter(t)
+	var mu sync.Mutex
+	var joined []*client.Client
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, err := client.ConnectNet(context.Background(), url, client.Config{Realm: realm, Logger: logger})
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			joined = append(joined, c)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	for _, c := range joined {
+		c.Close()
+	}
+	if len(joined) != maxSessions {
+		t.Errorf("expected %d concurrent joins to succeed, got %d", maxSessions, len(joined))
+	}
+}
+
+func TestMaxSessionsKillAll(t *testing.T) {
+	saved, savedGrace := maxSessions, admitGrace
+	defer func() { maxSessions, admitGrace = saved, savedGrace }()
+	maxSessions, admitGrace = 1, 0
+
+	url := startTestRouter(t)
+	c := connectTestClient(t, url, realm)
+	if _, err := testCall(getLocalClient(), string(wamp.MetaProcSessionKillAll), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	<-c.Done()
+	// kill_all sends no on_leave events, the stale session is dropped by
+	// checking the session list.
+	connectTestClient(t, url, realm)
+}
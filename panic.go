@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"io"
+	"runtime/debug"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// panicked receives the recovered panics of procedure handlers and
+// publishers when -panic-exit is set, so main shuts the router down.
+var panicked = make(chan interface{}, 1)
+
+// logPanic logs a recovered panic with the stack of the panicking goroutine,
+// and reports it to main with -panic-exit.
+func logPanic(what string, r interface{}) {
+	logger.Printf("%s panicked: %v\n%s", what, r, debug.Stack())
+	if panicExit {
+		select {
+		case panicked <- r:
+		default:
+		}
+	}
+}
+
+// recoverHandler returns callback failing calls with nexus.error.internal
+// when it panics, instead of crashing the router.
+func recoverHandler(procedure string, callback client.InvocationHandler) client.InvocationHandler {
+	return func(ctx context.Context, inv *wamp.Invocation) (result client.InvokeResult) {
+		defer func() {
+			if r := recover(); r != nil {
+				logPanic("procedure "+procedure, r)
+				result = client.InvokeResult{Err: errInternal, Args: wamp.List{"procedure panicked"}}
+			}
+		}()
+		return callback(ctx, inv)
+	}
+}
+
+// abortRouter logs a panic of main with its stack, then closes the listeners
+// and the router so sessions get disconnected rather than dropped.
+func abortRouter(r interface{}, listeners []io.Closer) {
+	logger.Printf("router panicked: %v\n%s", r, debug.Stack())
+	for _, l := range listeners {
+		l.Close()
+	}
+	wsRouter.Close()
+}
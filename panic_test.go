@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestRecoverHandler(t *testing.T) {
+	saved := panicExit
+	defer func() { panicExit = saved }()
+
+	url := startTestRouter(t)
+	logged := &countingWriter{match: "procedure test.panic panicked: boom\ngoroutine "}
+	logger = log.New(logged, "", 0)
+	err := createLocalCallee(getLocalClient(), "test.panic", func(context.Context, *wamp.Invocation) client.InvokeResult {
+		panic("boom")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := connectTestClient(t, url, realm)
+
+	if _, err = testCall(c, "test.panic", nil, nil); errorURI(err) != errInternal {
+		t.Fatalf("expected %s, got %v", errInternal, err)
+	}
+	if atomic.LoadInt32(&logged.count) != 1 {
+		t.Error("expected the panic to be logged with its stack")
+	}
+	select {
+	case r := <-panicked:
+		t.Errorf("panic %v reported without -panic-exit", r)
+	default:
+	}
+
+	// With -panic-exit the panic is also reported for main to shut down.
+	panicExit = true
+	if _, err = testCall(c, "test.panic", nil, nil); errorURI(err) != errInternal {
+		t.Fatalf("expected %s, got %v", errInternal, err)
+	}
+	select {
+	case r := <-panicked:
+		if r != "boom" {
+			t.Errorf("unexpected panic %v", r)
+		}
+	case <-time.After(time.Second):
+		t.Error("panic not reported with -panic-exit")
+	}
+}
+
+func TestAbortRouter(t *testing.T) {
+	url := startTestRouter(t)
+	logged := &countingWriter{match: "router panicked: boom\ngoroutine "}
+	logger = log.New(logged, "", 0)
+	c := connectTestClient(t, url, realm)
+
+	abortRouter("boom", nil)
+	wsRouter = nil
+	select {
+	case <-c.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("session not disconnected")
+	}
+	if atomic.LoadInt32(&logged.count) != 1 {
+		t.Error("expected the panic to be logged with its stack")
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+
+	"github.com/gorilla/websocket"
+)
+
+// httpEndpoint is an endpoint of the WebSocket listener listed by its root
+// index.
+type httpEndpoint struct {
+	Path string `json:"path"`
+	Kind string `json:"kind"`
+}
+
+// httpEndpoints returns the enabled endpoints of the WebSocket listener.
+func httpEndpoints() []httpEndpoint {
+	endpoints := []httpEndpoint{{"/", "websocket"}}
+	if dashEnable {
+		endpoints = append(endpoints, httpEndpoint{dashPath, "dashboard"})
+	}
+	return endpoints
+}
+
+// canonicalPath returns the path with duplicate slashes, dot segments and
+// trailing slashes removed, but for the root and the -dashboard-path
+// directory, which keep theirs.
+func canonicalPath(p string) string {
+	clean := path.Clean("/" + p)
+	if dashEnable && clean+"/" == dashPath {
+		return dashPath
+	}
+	return clean
+}
+
+// normalizePaths returns a handler redirecting requests that are not
+// WebSocket upgrades to the canonical form of their path, keeping the method
+// and query, or answering 404 with -ws-strict-paths.  Upgrades reach h
+// whatever their path.
+func normalizePaths(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := canonicalPath(r.URL.Path)
+		if p == r.URL.Path || websocket.IsWebSocketUpgrade(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if strictPaths {
+			writeIndex(w, http.StatusNotFound)
+			return
+		}
+		u := *r.URL
+		u.Path, u.RawPath = p, ""
+		http.Redirect(w, r, u.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+// rootIndex returns a handler passing WebSocket upgrades to ws and answering
+// other requests with the index of the enabled endpoints, with 404 for paths
+// other than the root.
+func rootIndex(ws http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case websocket.IsWebSocketUpgrade(r):
+			ws.ServeHTTP(w, r)
+		case r.URL.Path == "/":
+			writeIndex(w, http.StatusOK)
+		default:
+			writeIndex(w, http.StatusNotFound)
+		}
+	})
+}
+
+// writeIndex answers with the status and the endpoints as JSON, along with an
+// error for statuses other than 200.
+func writeIndex(w http.ResponseWriter, status int) {
+	index := map[string]interface{}{"endpoints": httpEndpoints()}
+	if status != http.StatusOK {
+		index["error"] = http.StatusText(status)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(index)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCanonicalPath(t *testing.T) {
+	saved := dashEnable
+	defer func() { dashEnable = saved }()
+	dashEnable = true
+	for p, want := range map[string]string{
+		"/":                        "/",
+		"":                         "/",
+		"//":                       "/",
+		"/dashboard":               "/dashboard/",
+		"/dashboard/":              "/dashboard/",
+		"/dashboard//config.json/": "/dashboard/config.json",
+		"/a/../dashboard/./x":      "/dashboard/x",
+		"/ws/":                     "/ws",
+	} {
+		if got := canonicalPath(p); got != want {
+			t.Errorf("%q: expected %q, got %q", p, want, got)
+		}
+	}
+}
+
+func TestHTTPPaths(t *testing.T) {
+	savedEnable, savedUser, savedPassword, savedStrict := dashEnable, dashUser, dashPassword, strictPaths
+	defer func() {
+		dashEnable, dashUser, dashPassword, strictPaths = savedEnable, savedUser, savedPassword, savedStrict
+	}()
+	dashEnable, dashUser, dashPassword = true, "admin", "secret"
+	startTestRouter(t)
+	server := httptest.NewServer(normalizePaths(dashboardMux(rootIndex(newWebsocketServer(wsRouter)))))
+	defer server.Close()
+
+	// get requests the path with the dashboard credentials, following
+	// redirects.
+	get := func(path string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		req.SetBasicAuth(dashUser, dashPassword)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(body)
+	}
+
+	for _, p := range []string{"/dashboard", "/dashboard/", "//dashboard//"} {
+		if status, body := get(p); status != http.StatusOK || !strings.Contains(body, "<title>nexus dashboard</title>") {
+			t.Errorf("%s: expected the dashboard index, got %d %q", p, status, body)
+		}
+	}
+	for _, p := range []string{"/dashboard/config.json", "/dashboard/config.json/"} {
+		if status, body := get(p); status != http.StatusOK || !strings.Contains(body, `"admin_prefix"`) {
+			t.Errorf("%s: expected the dashboard config, got %d %q", p, status, body)
+		}
+	}
+
+	// endpoints decodes the endpoints of an index.
+	endpoints := func(body string) map[string]string {
+		var index struct{ Endpoints []httpEndpoint }
+		if err := json.Unmarshal([]byte(body), &index); err != nil {
+			t.Fatalf("invalid index %q: %s", body, err)
+		}
+		kinds := map[string]string{}
+		for _, e := range index.Endpoints {
+			kinds[e.Path] = e.Kind
+		}
+		return kinds
+	}
+	status, body := get("/")
+	if kinds := endpoints(body); status != http.StatusOK || kinds["/"] != "websocket" || kinds[dashPath] != "dashboard" {
+		t.Errorf("expected the root to list the endpoints, got %d %q", status, body)
+	}
+	for _, p := range []string{"/unknown", "/unknown/"} {
+		status, body := get(p)
+		if kinds := endpoints(body); status != http.StatusNotFound || len(kinds) != 2 || !strings.Contains(body, `"error"`) {
+			t.Errorf("%s: expected 404 with the index, got %d %q", p, status, body)
+		}
+	}
+	connectTestClient(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws/", realm)
+
+	strictPaths = true
+	if status, _ := get("/dashboard"); status != http.StatusNotFound {
+		t.Errorf("expected 404 for a non-canonical path with strict paths, got %d", status)
+	}
+	if status, _ := get("/dashboard/"); status != http.StatusOK {
+		t.Errorf("expected the canonical path to pass with strict paths, got %d", status)
+	}
+}
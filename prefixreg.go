@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// Policies for overlapping prefix registrations of a realm, set with
+// -realm-prefix-overlap.
+const (
+	// prefixMostSpecific lets prefix registrations overlap, calls going to
+	// the one with the longest matching prefix.  This is how nexus routes
+	// calls and the default.
+	prefixMostSpecific = "most-specific"
+	// prefixReject rejects prefix registrations overlapping another one.
+	prefixReject = "reject"
+)
+
+// prefixPolicies maps realms to their prefix overlap policy.  Realms missing
+// from it use prefixMostSpecific.
+var prefixPolicies = map[wamp.URI]string{}
+
+var (
+	prefixGuardsMu sync.Mutex
+	// prefixGuards holds the prefix registration guards of the realms with
+	// the reject policy.
+	prefixGuards = map[wamp.URI]*prefixGuard{}
+)
+
+// parsePrefixPolicies merges a comma separated list of realm=policy pairs
+// into prefixPolicies.
+func parsePrefixPolicies(s string) error {
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		uri, policy, ok := strings.Cut(pair, "=")
+		if !ok || !wamp.URI(uri).ValidURI(false, "") {
+			return fmt.Errorf("invalid realm prefix overlap policy %q, expected realm=policy", pair)
+		}
+		if policy != prefixMostSpecific && policy != prefixReject {
+			return fmt.Errorf("invalid prefix overlap policy in %q, expected most-specific or reject", pair)
+		}
+		prefixPolicies[wamp.URI(uri)] = policy
+	}
+	return nil
+}
+
+// prefixGuard tracks the prefix registrations of a realm to reject
+// overlapping ones.
+type prefixGuard struct {
+	realm wamp.URI
+
+	mu sync.Mutex
+	// prefixes maps the IDs of the realm's prefix registrations to their
+	// URI.
+	prefixes map[wamp.ID]wamp.URI
+}
+
+// newPrefixGuard returns the prefix registration guard of the realm, creating
+// it if needed, or nil if the realm does not reject overlaps.
+func newPrefixGuard(uri wamp.URI) *prefixGuard {
+	if prefixPolicies[uri] != prefixReject {
+		return nil
+	}
+	prefixGuardsMu.Lock()
+	defer prefixGuardsMu.Unlock()
+	g := prefixGuards[uri]
+	if g == nil {
+		g = &prefixGuard{realm: uri, prefixes: map[wamp.ID]wamp.URI{}}
+		prefixGuards[uri] = g
+	}
+	return g
+}
+
+// check returns an error for a prefix register overlapping a prefix
+// registration of the realm, that is one of the two prefixes starting with
+// the other.  Registering the same prefix again is left to the router.
+// Other messages pass, and a nil guard allows everything.
+func (g *prefixGuard) check(msg wamp.Message) error {
+	reg, ok := msg.(*wamp.Register)
+	if g == nil || !ok {
+		return nil
+	}
+	if match, _ := wamp.AsString(reg.Options[wamp.OptMatch]); match != wamp.MatchPrefix {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, prefix := range g.prefixes {
+		if prefix != reg.Procedure && (strings.HasPrefix(string(reg.Procedure), string(prefix)) || strings.HasPrefix(string(prefix), string(reg.Procedure))) {
+			return fmt.Errorf("registration_overlap: prefix %s overlaps the registered prefix %s in realm %s", reg.Procedure, prefix, g.realm)
+		}
+	}
+	return nil
+}
+
+// watch keeps the prefix registrations up to date from the realm's
+// registration meta events.
+func (g *prefixGuard) watch() error {
+	err := onRealmMetaEvent(g.realm, wamp.MetaEventRegOnCreate, func(event *wamp.Event) {
+		if len(event.Arguments) < 2 {
+			return
+		}
+		details, _ := wamp.AsDict(event.Arguments[1])
+		if match, _ := wamp.AsString(details[wamp.OptMatch]); match != wamp.MatchPrefix {
+			return
+		}
+		id, _ := wamp.AsID(details["id"])
+		uri, _ := wamp.AsURI(details["uri"])
+		g.mu.Lock()
+		g.prefixes[id] = uri
+		g.mu.Unlock()
+	})
+	if err != nil {
+		return err
+	}
+	return onRealmMetaEvent(g.realm, wamp.MetaEventRegOnDelete, func(event *wamp.Event) {
+		if len(event.Arguments) < 2 {
+			return
+		}
+		id, _ := wamp.AsID(event.Arguments[1])
+		g.mu.Lock()
+		delete(g.prefixes, id)
+		g.mu.Unlock()
+	})
+}
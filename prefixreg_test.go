@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// namedCallee returns a handler answering with name.
+func namedCallee(name string) client.InvocationHandler {
+	return func(context.Context, *wamp.Invocation) client.InvokeResult {
+		return client.InvokeResult{Args: wamp.List{name}}
+	}
+}
+
+// calledName calls the procedure and returns the name its callee answered.
+func calledName(t *testing.T, c *client.Client, procedure string) interface{} {
+	t.Helper()
+	res, err := testCall(c, procedure, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.Arguments[0]
+}
+
+func TestParsePrefixPolicies(t *testing.T) {
+	defer func() { prefixPolicies = map[wamp.URI]string{} }()
+	if err := parsePrefixPolicies("tenant=reject, other=most-specific"); err != nil {
+		t.Fatal(err)
+	}
+	if prefixPolicies["tenant"] != prefixReject || prefixPolicies["other"] != prefixMostSpecific {
+		t.Errorf("unexpected policies %v", prefixPolicies)
+	}
+	for _, s := range []string{"tenant", "tenant=shared", "=reject"} {
+		if err := parsePrefixPolicies(s); err == nil {
+			t.Errorf("expected an error for %q", s)
+		}
+	}
+}
+
+func TestPrefixMostSpecific(t *testing.T) {
+	url := startTestRouter(t)
+	prefix := wamp.Dict{wamp.OptMatch: wamp.MatchPrefix}
+	if err := connectTestClient(t, url, realm).Register("test.", namedCallee("short"), prefix); err != nil {
+		t.Fatal(err)
+	}
+	if err := connectTestClient(t, url, realm).Register("test.a.", namedCallee("long"), prefix); err != nil {
+		t.Fatal(err)
+	}
+	caller := connectTestClient(t, url, realm)
+	if name := calledName(t, caller, "test.a.proc"); name != "long" {
+		t.Errorf("expected the longest prefix to win, got %v", name)
+	}
+	if name := calledName(t, caller, "test.b.proc"); name != "short" {
+		t.Errorf("expected the only matching prefix, got %v", name)
+	}
+}
+
+func TestPrefixReject(t *testing.T) {
+	defer func() {
+		prefixPolicies = map[wamp.URI]string{}
+		prefixGuards = map[wamp.URI]*prefixGuard{}
+	}()
+	prefixPolicies = map[wamp.URI]string{"tenant": prefixReject}
+
+	url := startTestRouter(t, "tenant")
+	guard := newPrefixGuard("tenant")
+	if err := guard.watch(); err != nil {
+		t.Fatal(err)
+	}
+	prefix := wamp.Dict{wamp.OptMatch: wamp.MatchPrefix}
+	if err := connectTestClient(t, url, "tenant").Register("test.", namedCallee("short"), prefix); err != nil {
+		t.Fatal(err)
+	}
+	// The registration is tracked from its meta event.
+	tracked := waitFor(t, 5*time.Second, func() bool {
+		guard.mu.Lock()
+		defer guard.mu.Unlock()
+		return len(guard.prefixes) == 1
+	})
+	if !tracked {
+		t.Fatal("prefix registration not tracked")
+	}
+
+	other := connectTestClient(t, url, "tenant")
+	err := other.Register("test.a.", namedCallee("long"), prefix)
+	if err == nil || !strings.Contains(err.Error(), "registration_overlap") {
+		t.Errorf("expected an overlapping prefix to be rejected, got %v", err)
+	}
+	if err = other.Register("other.", namedCallee("other"), prefix); err != nil {
+		t.Errorf("non-overlapping prefix rejected: %s", err)
+	}
+	if err = other.Register("test.a.exact", namedCallee("exact"), nil); err != nil {
+		t.Errorf("exact registration rejected: %s", err)
+	}
+	caller := connectTestClient(t, url, "tenant")
+	if name := calledName(t, caller, "test.a.proc"); name != "short" {
+		t.Errorf("expected the first prefix registration, got %v", name)
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+const defaultProxyTimeout = 10 * time.Second
+
+// proxyMapping maps a WAMP procedure onto an external HTTP endpoint.
+type proxyMapping struct {
+	Procedure string `json:"procedure"`
+	URL       string `json:"url"`
+	Method    string `json:"method"`
+	Timeout   string `json:"timeout"`
+	// Args are sent before the arguments of the call and Kwargs are merged
+	// into its keyword arguments, e.g. to pass API keys callers must not see.
+	Args   wamp.List `json:"args"`
+	Kwargs wamp.Dict `json:"kwargs"`
+	// Override lets callers replace Kwargs, instead of failing such calls.
+	Override bool `json:"override"`
+	// Check is required or optional to have the endpoint's address checked
+	// at startup, failing startup or only the health status when it cannot
+	// be reached.
+	Check string `json:"check"`
+	// Transform reshapes the request and response bodies.
+	Transform proxyTransform `json:"transform"`
+
+	timeout time.Duration
+}
+
+// proxyRequest is the JSON body sent to the HTTP endpoint.
+type proxyRequest struct {
+	Args   wamp.List `json:"args"`
+	Kwargs wamp.Dict `json:"kwargs"`
+}
+
+// loadProxyMappings reads a JSON list of proxy mappings from path.
+func loadProxyMappings(path string) ([]*proxyMapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var mappings []*proxyMapping
+	if err = json.Unmarshal(data, &mappings); err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %s", path, err)
+	}
+	for _, m := range mappings {
+		if m.Procedure == "" || m.URL == "" {
+			return nil, fmt.Errorf("proxy mapping requires procedure and url: %+v", m)
+		}
+		if m.Method == "" {
+			m.Method = http.MethodPost
+		}
+		switch m.Check {
+		case "", checkRequired, checkOptional:
+		default:
+			return nil, fmt.Errorf("invalid check for %q, expected required or optional", m.Procedure)
+		}
+		if err = m.Transform.check(); err != nil {
+			return nil, fmt.Errorf("invalid transform for %q: %s", m.Procedure, err)
+		}
+		m.timeout = defaultProxyTimeout
+		if m.Timeout != "" {
+			if m.timeout, err = time.ParseDuration(m.Timeout); err != nil {
+				return nil, fmt.Errorf("invalid timeout for %q: %s", m.Procedure, err)
+			}
+		}
+	}
+	return mappings, nil
+}
+
+// Startup checks of proxy mappings.
+const (
+	checkRequired = "required"
+	checkOptional = "optional"
+)
+
+// checkProxyEndpoints connects to the address of each mapping with a check,
+// reporting the outcome as proxy:<procedure> in the health status.  It
+// returns the error of the first required endpoint that cannot be reached;
+// unreachable optional ones are logged.
+func checkProxyEndpoints(mappings []*proxyMapping) error {
+	for _, m := range mappings {
+		if m.Check == "" {
+			continue
+		}
+		err := dialEndpoint(m.URL, m.timeout)
+		setHealth("proxy:"+m.Procedure, err)
+		if err == nil {
+			continue
+		}
+		if m.Check == checkRequired {
+			return fmt.Errorf("required proxy endpoint of %s is unreachable: %s", m.Procedure, err)
+		}
+		logger.Printf("warning: optional proxy endpoint of %s is unreachable: %s\n", m.Procedure, err)
+	}
+	return nil
+}
+
+// dialEndpoint opens and closes a TCP connection to the host of the URL, or
+// to the outbound proxy requests to it go through, without sending a request
+// that could have side effects.
+func dialEndpoint(rawURL string, timeout time.Duration) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if proxy, err := outboundProxy(u); err != nil {
+		return err
+	} else if proxy != nil {
+		u = proxy
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(u.Hostname(), port), timeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// createProxyCallee registers a local procedure forwarding invocations to the
+// mapped HTTP endpoint.
+func createProxyCallee(client *client.Client, m *proxyMapping) error {
+	return createLocalCallee(client, m.Procedure, m.invoke)
+}
+
+// inject adds the static arguments of the mapping to those of a call.  It
+// fails when the call sets one of the mapping's kwargs without Override.
+func (m *proxyMapping) inject(args wamp.List, kwargs wamp.Dict) (wamp.List, wamp.Dict, error) {
+	if len(m.Args) != 0 {
+		args = append(append(wamp.List{}, m.Args...), args...)
+	}
+	if len(m.Kwargs) == 0 {
+		return args, kwargs, nil
+	}
+	merged := make(wamp.Dict, len(m.Kwargs)+len(kwargs))
+	for k, v := range m.Kwargs {
+		merged[k] = v
+	}
+	for k, v := range kwargs {
+		if _, ok := m.Kwargs[k]; ok && !m.Override {
+			return nil, nil, fmt.Errorf("kwarg %q is set by the router", k)
+		}
+		merged[k] = v
+	}
+	return args, merged, nil
+}
+
+func (m *proxyMapping) invoke(ctx context.Context, inv *wamp.Invocation) client.InvokeResult {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	var body io.Reader
+	if m.Method != http.MethodGet && m.Method != http.MethodHead {
+		args, kwargs, err := m.inject(inv.Arguments, inv.ArgumentsKw)
+		if err != nil {
+			return client.InvokeResult{Err: wamp.ErrInvalidArgument, Args: wamp.List{err.Error()}}
+		}
+		data, err := json.Marshal(m.Transform.request(args, kwargs))
+		if err != nil {
+			return client.InvokeResult{Err: wamp.ErrInvalidArgument, Args: wamp.List{err.Error()}}
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, m.Method, m.URL, body)
+	if err != nil {
+		return client.InvokeResult{Err: wamp.ErrInvalidArgument, Args: wamp.List{err.Error()}}
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		logger.Printf("proxy %s: %s\n", m.Procedure, err)
+		if ctx.Err() != nil {
+			return client.InvokeResult{Err: wamp.ErrCanceled, Args: wamp.List{"proxy timeout"}}
+		}
+		return client.InvokeResult{Err: wamp.ErrNetworkFailure, Args: wamp.List{err.Error()}}
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return client.InvokeResult{Err: wamp.ErrNetworkFailure, Args: wamp.List{err.Error()}}
+	}
+	var payload interface{}
+	if err = json.Unmarshal(data, &payload); err != nil {
+		payload = string(data)
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return client.InvokeResult{
+			Err:    statusError(res.StatusCode),
+			Args:   wamp.List{payload},
+			Kwargs: wamp.Dict{"status": res.StatusCode},
+		}
+	}
+	if payload, err = m.Transform.response(payload); err != nil {
+		return client.InvokeResult{Err: errInternal, Args: wamp.List{err.Error()}}
+	}
+	return client.InvokeResult{Args: wamp.List{payload}}
+}
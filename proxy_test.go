@@ -0,0 +1,346 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestLoadProxyMappings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "proxy.json")
+	data := `[{"procedure": "svc.a", "url": "http://localhost/a"}, {"procedure": "svc.b", "url": "http://localhost/b", "method": "GET", "timeout": "2s"}]`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	mappings, err := loadProxyMappings(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mappings) != 2 {
+		t.Fatalf("expected 2 mappings, got %d", len(mappings))
+	}
+	if mappings[0].Method != http.MethodPost || mappings[0].timeout != defaultProxyTimeout {
+		t.Errorf("expected POST with default timeout, got %s %s", mappings[0].Method, mappings[0].timeout)
+	}
+	if mappings[1].Method != http.MethodGet || mappings[1].timeout != 2*time.Second {
+		t.Errorf("expected GET with 2s timeout, got %s %s", mappings[1].Method, mappings[1].timeout)
+	}
+
+	if err = os.WriteFile(path, []byte(`[{"procedure": "svc.a"}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = loadProxyMappings(path); err == nil {
+		t.Error("expected an error for a mapping without url")
+	}
+	if err = os.WriteFile(path, []byte(`[{"procedure": "svc.a", "url": "http://localhost/a", "check": "always"}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = loadProxyMappings(path); err == nil {
+		t.Error("expected an error for an invalid check")
+	}
+}
+
+func TestProxyCallee(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": "gone"}`))
+			return
+		}
+		var req proxyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"args": req.Args, "kwargs": req.Kwargs})
+	}))
+	defer backend.Close()
+
+	url := startTestRouter(t)
+	for _, m := range []*proxyMapping{
+		{Procedure: "svc.echo", URL: backend.URL + "/echo", Method: http.MethodPost, timeout: time.Second},
+		{Procedure: "svc.missing", URL: backend.URL + "/missing", Method: http.MethodPost, timeout: time.Second},
+	} {
+		if err := createProxyCallee(getLocalClient(), m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := connectTestClient(t, url, realm)
+
+	res, err := testCall(c, "svc.echo", wamp.List{"x"}, wamp.Dict{"k": "v"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := wamp.AsDict(res.Arguments[0])
+	args, _ := wamp.AsList(body["args"])
+	kwargs, _ := wamp.AsDict(body["kwargs"])
+	if len(args) != 1 || args[0] != "x" || kwargs["k"] != "v" {
+		t.Errorf("unexpected proxied body: %v", body)
+	}
+
+	_, err = testCall(c, "svc.missing", nil, nil)
+	if uri := errorURI(err); uri != wamp.ErrNoSuchProcedure {
+		t.Errorf("expected %s for a 404, got %v", wamp.ErrNoSuchProcedure, err)
+	}
+}
+
+func TestProxyInjection(t *testing.T) {
+	received := make(chan proxyRequest, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req proxyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- req
+		w.Write([]byte(`"ok"`))
+	}))
+	defer backend.Close()
+
+	url := startTestRouter(t)
+	for _, m := range []*proxyMapping{
+		{Procedure: "svc.fixed", URL: backend.URL, Method: http.MethodPost, timeout: time.Second,
+			Args: wamp.List{"tenant-1"}, Kwargs: wamp.Dict{"api_key": "secret"}},
+		{Procedure: "svc.default", URL: backend.URL, Method: http.MethodPost, timeout: time.Second,
+			Kwargs: wamp.Dict{"region": "eu"}, Override: true},
+	} {
+		if err := createProxyCallee(getLocalClient(), m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := connectTestClient(t, url, realm)
+
+	if _, err := testCall(c, "svc.fixed", wamp.List{"x"}, wamp.Dict{"k": "v"}); err != nil {
+		t.Fatal(err)
+	}
+	req := <-received
+	if len(req.Args) != 2 || req.Args[0] != "tenant-1" || req.Args[1] != "x" {
+		t.Errorf("expected the injected arg first, got %v", req.Args)
+	}
+	if req.Kwargs["api_key"] != "secret" || req.Kwargs["k"] != "v" {
+		t.Errorf("expected the injected and the caller kwargs, got %v", req.Kwargs)
+	}
+
+	_, err := testCall(c, "svc.fixed", nil, wamp.Dict{"api_key": "mine"})
+	if uri := errorURI(err); uri != wamp.ErrInvalidArgument {
+		t.Errorf("expected %s when overriding a forbidden kwarg, got %v", wamp.ErrInvalidArgument, err)
+	}
+	select {
+	case req = <-received:
+		t.Errorf("rejected call reached the backend with %v", req)
+	default:
+	}
+
+	if _, err = testCall(c, "svc.default", nil, wamp.Dict{"region": "us"}); err != nil {
+		t.Fatal(err)
+	}
+	if req = <-received; req.Kwargs["region"] != "us" {
+		t.Errorf("expected the caller to override region, got %v", req.Kwargs)
+	}
+}
+
+func TestCheckProxyEndpoints(t *testing.T) {
+	startTestRouter(t)
+	warnings := &countingWriter{match: "warning: optional proxy endpoint of svc.down"}
+	logger = log.New(warnings, "", 0)
+	backend := httptest.NewServer(http.NotFoundHandler())
+	defer backend.Close()
+	// Nothing listens on the port of a closed server.
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	up := &proxyMapping{Procedure: "svc.up", URL: backend.URL + "/a", Check: checkRequired, timeout: time.Second}
+	down := &proxyMapping{Procedure: "svc.down", URL: closed.URL + "/b", Check: checkOptional, timeout: time.Second}
+	unchecked := &proxyMapping{Procedure: "svc.unchecked", URL: closed.URL, timeout: time.Second}
+	if err := checkProxyEndpoints([]*proxyMapping{up, down, unchecked}); err != nil {
+		t.Fatalf("expected an unreachable optional endpoint not to fail startup, got %v", err)
+	}
+	if atomic.LoadInt32(&warnings.count) != 1 {
+		t.Errorf("expected a warning for the optional endpoint, got %d", warnings.count)
+	}
+	status := healthStatus()
+	if status["proxy:svc.up"] != "ok" || status["proxy:svc.down"] == "ok" || status["proxy:svc.down"] == "" {
+		t.Errorf("unexpected health status %v", status)
+	}
+	if _, ok := status["proxy:svc.unchecked"]; ok {
+		t.Errorf("unchecked endpoint in the health status %v", status)
+	}
+
+	down.Check = checkRequired
+	if err := checkProxyEndpoints([]*proxyMapping{up, down}); err == nil || !strings.Contains(err.Error(), "svc.down") {
+		t.Errorf("expected an unreachable required endpoint to fail startup, got %v", err)
+	}
+}
+
+func TestProxyTransform(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"echo": body}})
+	}))
+	defer backend.Close()
+
+	url := startTestRouter(t)
+	transform := proxyTransform{Rename: map[string]string{"user": "user_id"}, Wrap: "request", Unwrap: "data.echo"}
+	for _, m := range []*proxyMapping{
+		{Procedure: "svc.reshaped", URL: backend.URL, Method: http.MethodPost, timeout: time.Second, Transform: transform},
+		{Procedure: "svc.missing", URL: backend.URL, Method: http.MethodPost, timeout: time.Second, Transform: proxyTransform{Unwrap: "data.result"}},
+	} {
+		if err := createProxyCallee(getLocalClient(), m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := connectTestClient(t, url, realm)
+
+	res, err := testCall(c, "svc.reshaped", wamp.List{"x"}, wamp.Dict{"user": "bob", "k": "v"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The result is the body the backend received, unwrapped from its response.
+	got, _ := wamp.AsDict(res.Arguments[0])
+	request, _ := wamp.AsDict(got["request"])
+	kwargs, _ := wamp.AsDict(request["kwargs"])
+	args, _ := wamp.AsList(request["args"])
+	if len(got) != 1 || len(args) != 1 || args[0] != "x" || kwargs["user_id"] != "bob" || kwargs["k"] != "v" || len(kwargs) != 2 {
+		t.Errorf("unexpected reshaped request %v", got)
+	}
+
+	_, err = testCall(c, "svc.missing", nil, nil)
+	if uri := errorURI(err); uri != errInternal {
+		t.Errorf("expected %s for a response without the unwrap path, got %v", errInternal, err)
+	}
+
+	if err = (&proxyTransform{Unwrap: "data..echo"}).check(); err == nil {
+		t.Error("expected an error for an empty unwrap key")
+	}
+}
+
+func TestOutboundProxy(t *testing.T) {
+	var proxied atomic.Value
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Store(r.URL.String())
+		json.NewEncoder(w).Encode(map[string]string{"via": "proxy"})
+	}))
+	defer proxy.Close()
+	saved := httpClient
+	defer func() { httpClient = saved }()
+	var err error
+	if httpClient, err = newHTTPClient(proxy.URL, "internal.example", "", false); err != nil {
+		t.Fatal(err)
+	}
+
+	url := startTestRouter(t)
+	m := &proxyMapping{Procedure: "svc.remote", URL: "http://backend.invalid/echo", Method: http.MethodPost, timeout: time.Second}
+	if err := createProxyCallee(getLocalClient(), m); err != nil {
+		t.Fatal(err)
+	}
+	res, err := testCall(connectTestClient(t, url, realm), "svc.remote", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := wamp.AsDict(res.Arguments[0]); body["via"] != "proxy" || proxied.Load() != m.URL {
+		t.Errorf("expected the request to go through the proxy, got %v for %v", body, proxied.Load())
+	}
+	// The endpoint check connects to the proxy rather than the endpoint.
+	if err := dialEndpoint(m.URL, time.Second); err != nil {
+		t.Errorf("expected the check to reach the proxy, got %s", err)
+	}
+
+	for _, tc := range []struct {
+		url    string
+		direct bool
+	}{
+		{"http://backend.invalid/", false},
+		{"http://internal.example/", true},
+		{"https://api.internal.example/", true},
+		{"http://notinternal.example/", false},
+		{"http://127.0.0.1:8080/", true},
+		{"http://localhost/", true},
+	} {
+		if p, _ := outboundProxy(mustParseURL(t, tc.url)); (p == nil) != tc.direct {
+			t.Errorf("%s: expected direct %t, got proxy %v", tc.url, tc.direct, p)
+		}
+	}
+	if _, err := newHTTPClient("proxy:3128", "", "", false); err == nil {
+		t.Error("expected an error for a proxy without scheme")
+	}
+}
+
+func TestBypassProxy(t *testing.T) {
+	rules := strings.Split("10.0.0.0/8, .corp.example, svc.example:8443", ",")
+	for _, tc := range []struct {
+		url    string
+		bypass bool
+	}{
+		{"http://10.1.2.3/", true},
+		{"http://11.1.2.3/", false},
+		{"http://corp.example/", true},
+		{"http://a.corp.example/", true},
+		{"https://svc.example:8443/", true},
+		{"https://svc.example/", false},
+	} {
+		if got := bypassProxy(mustParseURL(t, tc.url), rules); got != tc.bypass {
+			t.Errorf("%s: expected bypass %t, got %t", tc.url, tc.bypass, got)
+		}
+	}
+	if !bypassProxy(mustParseURL(t, "http://anything/"), []string{"*"}) {
+		t.Error("expected * to bypass every host")
+	}
+}
+
+func TestOutboundTLS(t *testing.T) {
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	backend.Config.ErrorLog = log.New(io.Discard, "", 0)
+	backend.StartTLS()
+	defer backend.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: backend.Certificate().Raw})
+	if err := os.WriteFile(caFile, cert, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		caFile     string
+		skipVerify bool
+		ok         bool
+	}{
+		{"", false, false},
+		{caFile, false, true},
+		{"", true, true},
+	} {
+		c, err := newHTTPClient("", "", tc.caFile, tc.skipVerify)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := c.Get(backend.URL)
+		if err == nil {
+			res.Body.Close()
+		}
+		if (err == nil) != tc.ok {
+			t.Errorf("ca file %q, skip verify %t: expected success %t, got %v", tc.caFile, tc.skipVerify, tc.ok, err)
+		}
+	}
+}
+
+func mustParseURL(t *testing.T, s string) *neturl.URL {
+	t.Helper()
+	u, err := neturl.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
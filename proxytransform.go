@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// proxyTransform reshapes the requests and responses of a proxy mapping for
+// endpoints expecting other payloads than the {args, kwargs} envelope.
+type proxyTransform struct {
+	// Rename maps kwargs to the keys they are sent as.
+	Rename map[string]string `json:"rename"`
+	// Wrap sends the request body as the value of this key.
+	Wrap string `json:"wrap"`
+	// Unwrap is the dot separated path of the part of the response body
+	// returned as the call result.
+	Unwrap string `json:"unwrap"`
+}
+
+// check returns an error for a transform that cannot be applied.
+func (t *proxyTransform) check() error {
+	for from, to := range t.Rename {
+		if from == "" || to == "" {
+			return fmt.Errorf("invalid rename %q to %q", from, to)
+		}
+	}
+	if t.Unwrap != "" {
+		for _, key := range strings.Split(t.Unwrap, ".") {
+			if key == "" {
+				return fmt.Errorf("invalid unwrap path %q", t.Unwrap)
+			}
+		}
+	}
+	return nil
+}
+
+// request returns the body sent for the args and kwargs of a call.
+func (t *proxyTransform) request(args wamp.List, kwargs wamp.Dict) interface{} {
+	if len(t.Rename) != 0 && len(kwargs) != 0 {
+		renamed := make(wamp.Dict, len(kwargs))
+		for k, v := range kwargs {
+			if to, ok := t.Rename[k]; ok {
+				k = to
+			}
+			renamed[k] = v
+		}
+		kwargs = renamed
+	}
+	var body interface{} = proxyRequest{Args: args, Kwargs: kwargs}
+	if t.Wrap != "" {
+		body = map[string]interface{}{t.Wrap: body}
+	}
+	return body
+}
+
+// response returns the part of the decoded response body at the Unwrap path.
+func (t *proxyTransform) response(payload interface{}) (interface{}, error) {
+	if t.Unwrap == "" {
+		return payload, nil
+	}
+	for _, key := range strings.Split(t.Unwrap, ".") {
+		dict, _ := wamp.AsDict(payload)
+		v, ok := dict[key]
+		if !ok {
+			return nil, fmt.Errorf("response has no %q to unwrap", t.Unwrap)
+		}
+		payload = v
+	}
+	return payload, nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gammazero/nexus/v3/router"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// ackBuckets are the upper bounds in milliseconds of the ack latency
+// histogram buckets, the last bucket holding the slower acks.
+var ackBuckets = []int{1, 5, 10, 50, 100, 500, 1000}
+
+var (
+	acksMu sync.Mutex
+	// ackHist counts the acks per bucket of ackBuckets.
+	ackHist = make([]uint64, len(ackBuckets)+1)
+	// ackCount and ackFailures count the PUBLISHED and ERROR replies to
+	// acknowledged publishes.
+	ackCount    uint64
+	ackFailures uint64
+	ackTotal    time.Duration
+	ackMax      time.Duration
+)
+
+// pendingAck is an acknowledged publish waiting for its reply.
+type pendingAck struct {
+	topic wamp.URI
+	at    time.Time
+}
+
+// ackRouter times the replies to the acknowledged publishes of the clients
+// attached to the router, with -count-publish-acks or -log-publish-acks.  It
+// overrides Attach as the RawSocket server calls it instead of AttachClient.
+type ackRouter struct {
+	router.Router
+}
+
+func (r ackRouter) Attach(client wamp.Peer) error {
+	return r.Router.Attach(newAckPeer(client))
+}
+
+func (r ackRouter) AttachClient(client wamp.Peer, transportDetails wamp.Dict) error {
+	return r.Router.AttachClient(newAckPeer(client), transportDetails)
+}
+
+// ackPeer records when the peer sends a publish asking for acknowledgment
+// and observes the reply of the router to it.
+type ackPeer struct {
+	wamp.Peer
+	mu        sync.Mutex
+	session   wamp.ID
+	pending   map[wamp.ID]pendingAck
+	recv      chan wamp.Message
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newAckPeer(p wamp.Peer) *ackPeer {
+	ap := &ackPeer{Peer: p, pending: map[wamp.ID]pendingAck{}, recv: make(chan wamp.Message), done: make(chan struct{})}
+	go func() {
+		defer close(ap.recv)
+		for msg := range p.Recv() {
+			if pub, ok := msg.(*wamp.Publish); ok {
+				if ack, _ := pub.Options[wamp.OptAcknowledge].(bool); ack {
+					ap.mu.Lock()
+					ap.pending[pub.Request] = pendingAck{topic: pub.Topic, at: time.Now()}
+					ap.mu.Unlock()
+				}
+			}
+			select {
+			case ap.recv <- msg:
+			case <-ap.done:
+				return
+			}
+		}
+	}()
+	return ap
+}
+
+func (p *ackPeer) Send(msg wamp.Message) error {
+	if welcome, ok := msg.(*wamp.Welcome); ok {
+		p.mu.Lock()
+		p.session = welcome.ID
+		p.mu.Unlock()
+	}
+	return p.Peer.Send(msg)
+}
+
+// TrySend is how the broker and the authorizer reply to publishes.
+func (p *ackPeer) TrySend(msg wamp.Message) error {
+	switch msg := msg.(type) {
+	case *wamp.Published:
+		p.observe(msg.Request, "")
+	case *wamp.Error:
+		if msg.Type == wamp.PUBLISH {
+			p.observe(msg.Request, msg.Error)
+		}
+	}
+	return p.Peer.TrySend(msg)
+}
+
+// observe records the reply to the acknowledged publish of the request, a
+// failure if errURI is set.  The router also sends errors to publishes
+// without acknowledge, which are not recorded.
+func (p *ackPeer) observe(request wamp.ID, errURI wamp.URI) {
+	p.mu.Lock()
+	ack, ok := p.pending[request]
+	delete(p.pending, request)
+	sid := p.session
+	p.mu.Unlock()
+	if !ok {
+		return
+	}
+	latency := time.Since(ack.at)
+	recordAck(latency, errURI != "")
+	if !logAcks {
+		return
+	}
+	if errURI != "" {
+		logger.Printf("publish ack: session %d publish %d to %s failed after %s: %s\n", sid, request, ack.topic, latency, errURI)
+	} else {
+		logger.Printf("publish ack: session %d publish %d to %s acknowledged after %s\n", sid, request, ack.topic, latency)
+	}
+}
+
+func (p *ackPeer) Recv() <-chan wamp.Message {
+	return p.recv
+}
+
+func (p *ackPeer) Close() {
+	p.closeOnce.Do(func() { close(p.done) })
+	p.Peer.Close()
+}
+
+// recordAck adds the latency of a reply to an acknowledged publish to the
+// histogram.
+func recordAck(latency time.Duration, failed bool) {
+	i := 0
+	for i < len(ackBuckets) && latency > time.Duration(ackBuckets[i])*time.Millisecond {
+		i++
+	}
+	acksMu.Lock()
+	defer acksMu.Unlock()
+	ackHist[i]++
+	if failed {
+		ackFailures++
+	} else {
+		ackCount++
+	}
+	ackTotal += latency
+	if latency > ackMax {
+		ackMax = latency
+	}
+}
+
+// ackStats returns the ack latency histogram and failures as published in the
+// stats.  Latencies are in seconds, bucket keys are the upper bounds in
+// milliseconds, and inf for the last one.
+func ackStats() wamp.Dict {
+	acksMu.Lock()
+	defer acksMu.Unlock()
+	buckets := wamp.Dict{}
+	for i, n := range ackHist {
+		key := "inf"
+		if i < len(ackBuckets) {
+			key = strconv.Itoa(ackBuckets[i])
+		}
+		buckets[key] = n
+	}
+	avg := 0.0
+	if n := ackCount + ackFailures; n > 0 {
+		avg = ackTotal.Seconds() / float64(n)
+	}
+	return wamp.Dict{
+		"acknowledged": ackCount,
+		"failed":       ackFailures,
+		"avg_latency":  avg,
+		"max_latency":  ackMax.Seconds(),
+		"buckets":      buckets,
+	}
+}
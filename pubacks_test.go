@@ -0,0 +1,81 @@
+package main
+
+import (
+	"log"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// resetAcks clears the recorded acks.
+func resetAcks() {
+	acksMu.Lock()
+	defer acksMu.Unlock()
+	ackHist = make([]uint64, len(ackBuckets)+1)
+	ackCount, ackFailures, ackTotal, ackMax = 0, 0, 0, 0
+}
+
+func TestPublishAcks(t *testing.T) {
+	resetAcks()
+	defer resetAcks()
+	saved := logAcks
+	defer func() { logAcks = saved }()
+	logAcks = true
+
+	startTestRouter(t)
+	acks := &countingWriter{match: "publish ack: "}
+	logger = log.New(acks, "", 0)
+	server := httptest.NewServer(newWebsocketServer(ackRouter{wsRouter}))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	pub := connectTestClient(t, url, realm)
+	if err := pub.Publish("test.acked", wamp.Dict{wamp.OptAcknowledge: true}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := pub.Publish("bad..topic", wamp.Dict{wamp.OptAcknowledge: true}, nil, nil); err == nil {
+		t.Fatal("expected the publish to an invalid topic to fail")
+	}
+	// Publishes without acknowledge are not timed.
+	if err := pub.Publish("test.unacked", nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := ackStats()
+	if stats["acknowledged"] != uint64(1) || stats["failed"] != uint64(1) {
+		t.Errorf("expected one acknowledged and one failed publish, got %v", stats)
+	}
+	buckets, _ := wamp.AsDict(stats["buckets"])
+	var observed uint64
+	for _, n := range buckets {
+		observed += n.(uint64)
+	}
+	if observed != 2 {
+		t.Errorf("expected 2 latency observations, got %v", buckets)
+	}
+	if max, _ := stats["max_latency"].(float64); max <= 0 || max > 5 {
+		t.Errorf("unexpected max latency %v", stats["max_latency"])
+	}
+	// The replies are logged before they are sent.
+	if n := atomic.LoadInt32(&acks.count); n != 2 {
+		t.Errorf("expected 2 logged acks, got %d", n)
+	}
+
+	// Publishes over RawSocket are timed too.
+	rs, err := newRawSocketServer(ackRouter{wsRouter}).ListenAndServe("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+	rsPub := connectTestClient(t, "tcp://"+rs.(net.Listener).Addr().String(), realm)
+	if err = rsPub.Publish("test.acked", wamp.Dict{wamp.OptAcknowledge: true}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if stats = ackStats(); stats["acknowledged"] != uint64(2) {
+		t.Errorf("expected the RawSocket publish to be acknowledged, got %v", stats)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"sync/atomic"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// localPublication is a publish of an internal publisher waiting to be sent
+// by the local client.
+type localPublication struct {
+	topic  string
+	args   wamp.List
+	kwargs wamp.Dict
+}
+
+var (
+	// publishQueue buffers the publishes of the internal publishers, so a
+	// congested router does not stall them.
+	publishQueue chan localPublication
+	// droppedPublishes is the number of publishes dropped as the queue was
+	// full.
+	droppedPublishes uint64
+)
+
+// startPublishQueue creates the publish queue of -publish-buffer publishes
+// and sends them with the local client until quit is closed.
+func startPublishQueue(size int, quit <-chan struct{}) {
+	q := make(chan localPublication, size)
+	publishQueue = q
+	go func() {
+		for {
+			select {
+			case p := <-q:
+				if err := getLocalClient().Publish(p.topic, nil, p.args, p.kwargs); err != nil {
+					logger.Printf("failed to publish on %s: %s\n", p.topic, err)
+				}
+			case <-quit:
+				return
+			}
+		}
+	}()
+}
+
+// localPublish queues a publish of an internal publisher without blocking.
+// It is dropped and counted when the queue is full.
+func localPublish(topic string, args wamp.List, kwargs wamp.Dict) {
+	select {
+	case publishQueue <- localPublication{topic, args, kwargs}:
+	default:
+		atomic.AddUint64(&droppedPublishes, 1)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestLocalPublishDrops(t *testing.T) {
+	saved := publishQueue
+	defer func() { publishQueue = saved }()
+	// A queue nobody sends from stands for a local client stuck on a
+	// congested router.
+	publishQueue = make(chan localPublication, 2)
+	dropped := atomic.LoadUint64(&droppedPublishes)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 5; i++ {
+			localPublish("test.topic", wamp.List{i}, nil)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publishes blocked on a full queue")
+	}
+	if n := atomic.LoadUint64(&droppedPublishes) - dropped; n != 3 {
+		t.Errorf("expected 3 dropped publishes, got %d", n)
+	}
+}
+
+func TestLocalPublish(t *testing.T) {
+	url := startTestRouter(t)
+	c := connectTestClient(t, url, realm)
+	received := make(chan *wamp.Event, 1)
+	if err := c.SubscribeChan("test.topic", received, nil); err != nil {
+		t.Fatal(err)
+	}
+	localPublish("test.topic", wamp.List{"hi"}, nil)
+	select {
+	case event := <-received:
+		if len(event.Arguments) != 1 || event.Arguments[0] != "hi" {
+			t.Errorf("unexpected event %v", event.Arguments)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("queued publish not sent")
+	}
+}
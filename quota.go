@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// quotas maps realms to the number of calls and publishes their remote
+// sessions may send per -quota-window.
+var quotas = map[wamp.URI]quotaLimit{}
+
+// quotaLimit is the number of calls and publishes allowed per window.  Zero
+// means unlimited.
+type quotaLimit struct {
+	calls, publishes int
+}
+
+// parseRealmQuotas merges a comma separated list of realm=calls:publishes
+// entries into quotas.
+func parseRealmQuotas(s string) error {
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		uri, limits, ok := strings.Cut(entry, "=")
+		if !ok || !wamp.URI(uri).ValidURI(false, "") {
+			return fmt.Errorf("invalid realm quota %q, expected realm=calls:publishes", entry)
+		}
+		calls, publishes, ok := strings.Cut(limits, ":")
+		if !ok {
+			return fmt.Errorf("invalid realm quota %q, expected realm=calls:publishes", entry)
+		}
+		var limit quotaLimit
+		var err error
+		if limit.calls, err = strconv.Atoi(calls); err != nil || limit.calls < 0 {
+			return fmt.Errorf("invalid call quota in %q", entry)
+		}
+		if limit.publishes, err = strconv.Atoi(publishes); err != nil || limit.publishes < 0 {
+			return fmt.Errorf("invalid publish quota in %q", entry)
+		}
+		quotas[wamp.URI(uri)] = limit
+	}
+	return nil
+}
+
+// realmQuota counts the calls and publishes of a realm in the current window.
+// The counts are reset when a message arrives after the window ended.
+type realmQuota struct {
+	realm  wamp.URI
+	limit  quotaLimit
+	window time.Duration
+
+	mu        sync.Mutex
+	start     time.Time
+	calls     int
+	publishes int
+}
+
+// newRealmQuota returns the quota of the realm, or nil if it has none.
+func newRealmQuota(uri wamp.URI, window time.Duration) *realmQuota {
+	limit, ok := quotas[uri]
+	if !ok {
+		return nil
+	}
+	return &realmQuota{realm: uri, limit: limit, window: window, start: time.Now()}
+}
+
+// admit counts a call or publish, or returns an error if the quota of the
+// window is used up.  Other messages pass.  A nil quota admits everything.
+func (q *realmQuota) admit(msg wamp.Message) error {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if now := time.Now(); now.Sub(q.start) >= q.window {
+		q.start, q.calls, q.publishes = now, 0, 0
+	}
+	var count *int
+	var limit int
+	var what string
+	switch msg.(type) {
+	case *wamp.Call:
+		count, limit, what = &q.calls, q.limit.calls, "calls"
+	case *wamp.Publish:
+		count, limit, what = &q.publishes, q.limit.publishes, "publishes"
+	default:
+		return nil
+	}
+	if limit > 0 && *count >= limit {
+		retry := q.window - time.Since(q.start)
+		return fmt.Errorf("quota_exceeded: realm %s is limited to %d %s per %s, retry after %s",
+			q.realm, limit, what, q.window, retry.Round(time.Millisecond))
+	}
+	*count++
+	return nil
+}
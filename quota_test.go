@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestParseRealmQuotas(t *testing.T) {
+	defer func() { quotas = map[wamp.URI]quotaLimit{} }()
+	if err := parseRealmQuotas("default=10:100, tenant=0:5"); err != nil {
+		t.Fatal(err)
+	}
+	if quotas["default"] != (quotaLimit{10, 100}) || quotas["tenant"] != (quotaLimit{0, 5}) {
+		t.Errorf("unexpected quotas %v", quotas)
+	}
+	for _, s := range []string{"default", "default=10", "default=x:1", "default=1:-1", "not a realm=1:1"} {
+		if err := parseRealmQuotas(s); err == nil {
+			t.Errorf("expected an error for %q", s)
+		}
+	}
+}
+
+func TestRealmQuota(t *testing.T) {
+	savedWindow := quotaWindow
+	defer func() {
+		quotas = map[wamp.URI]quotaLimit{}
+		quotaWindow = savedWindow
+	}()
+	quotas = map[wamp.URI]quotaLimit{"tenant": {calls: 2}}
+	quotaWindow = 200 * time.Millisecond
+
+	url := startTestRouter(t, "tenant")
+	c := connectTestClient(t, url, "tenant")
+	call := func() error {
+		_, err := testCall(c, "wamp.session.count", nil, nil)
+		return err
+	}
+	// Calls of other realms are not limited.
+	other := connectTestClient(t, url, realm)
+	for i := 0; i < 3; i++ {
+		if _, err := testCall(other, "wamp.session.count", nil, nil); err != nil {
+			t.Fatalf("call %d in the default realm failed: %s", i, err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := call(); err != nil {
+			t.Fatalf("call %d within the quota failed: %s", i, err)
+		}
+	}
+	err := call()
+	if uri := errorURI(err); uri != wamp.ErrAuthorizationFailed || !strings.Contains(err.Error(), "quota_exceeded") {
+		t.Fatalf("expected a quota error, got %v", err)
+	}
+	if err = c.Publish("test.topic", wamp.Dict{wamp.OptAcknowledge: true}, nil, nil); err != nil {
+		t.Errorf("publish without a quota failed: %s", err)
+	}
+
+	time.Sleep(quotaWindow)
+	if err = call(); err != nil {
+		t.Errorf("call after the window reset failed: %s", err)
+	}
+}
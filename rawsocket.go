@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gammazero/nexus/v3/router"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// rawSocketListener is a network and address to serve RawSocket on.
+type rawSocketListener struct {
+	network, address string
+}
+
+// parseRawSocketListener parses an -rs-listen value given as network:address,
+// such as tcp:0.0.0.0:8953 or unix:/run/nexus.sock.
+func parseRawSocketListener(s string) (rawSocketListener, error) {
+	network, address, _ := strings.Cut(s, ":")
+	switch network {
+	case "tcp", "tcp4", "tcp6", "unix", "unixpacket":
+	default:
+		return rawSocketListener{}, fmt.Errorf("invalid RawSocket listener %q, expected tcp, tcp4, tcp6, unix or unixpacket network", s)
+	}
+	if address == "" {
+		return rawSocketListener{}, fmt.Errorf("invalid RawSocket listener %q, missing address", s)
+	}
+	return rawSocketListener{network, address}, nil
+}
+
+// acceptLimiter spaces out accepted connections to at most a rate per second.
+type acceptLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newAcceptLimiter(rate int) *acceptLimiter {
+	return &acceptLimiter{interval: time.Second / time.Duration(rate)}
+}
+
+// wait blocks until the next connection may be accepted.  Connections beyond
+// the rate queue up, each waiting one interval after the one before it.
+func (l *acceptLimiter) wait() {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+	time.Sleep(delay)
+}
+
+// throttledRouter attaches RawSocket clients to the router at the rate of its
+// limiter, set with -rs-accept-rate.  Connections are still accepted and
+// handshaked by nexus as they come, as its accept loop cannot be wrapped, so
+// it is the join of queued clients that waits.
+type throttledRouter struct {
+	router.Router
+	limiter *acceptLimiter
+}
+
+func (r throttledRouter) Attach(client wamp.Peer) error {
+	r.limiter.wait()
+	return r.Router.Attach(client)
+}
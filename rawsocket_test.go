@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+)
+
+func TestParseRawSocketListener(t *testing.T) {
+	l, err := parseRawSocketListener("tcp:127.0.0.1:9000")
+	if err != nil || l.network != "tcp" || l.address != "127.0.0.1:9000" {
+		t.Errorf("unexpected listener %+v: %v", l, err)
+	}
+	for _, s := range []string{"udp:127.0.0.1:9000", "unix:", "127.0.0.1"} {
+		if _, err = parseRawSocketListener(s); err == nil {
+			t.Errorf("expected an error for %q", s)
+		}
+	}
+}
+
+func TestRawSocketListeners(t *testing.T) {
+	startTestRouter(t)
+	path := filepath.Join(t.TempDir(), "rs.sock")
+	server := newRawSocketServer(wsRouter)
+	urls := map[string]string{}
+	for _, l := range []rawSocketListener{{"tcp", "127.0.0.1:0"}, {"unix", path}} {
+		closer, url, err := startRawSocket(server, l)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer closer.Close()
+		urls[l.network] = url
+	}
+	if strings.HasSuffix(urls["tcp"], ":0") {
+		t.Errorf("expected the bound port, got %s", urls["tcp"])
+	}
+
+	for network, url := range urls {
+		c, err := client.ConnectNet(context.Background(), url, client.Config{Realm: realm, Logger: logger})
+		if err != nil {
+			t.Fatalf("%s: %s", network, err)
+		}
+		if _, err = testCall(c, "wamp.session.count", nil, nil); err != nil {
+			t.Errorf("%s: %s", network, err)
+		}
+		c.Close()
+	}
+}
+
+func TestRawSocketAcceptRate(t *testing.T) {
+	startTestRouter(t)
+	const rate, clients = 20, 5
+	server := newRawSocketServer(throttledRouter{wsRouter, newAcceptLimiter(rate)})
+	closer, url, err := startRawSocket(server, rawSocketListener{"tcp", "127.0.0.1:0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, err := client.ConnectNet(context.Background(), url, client.Config{Realm: realm, Logger: logger})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			c.Close()
+		}()
+	}
+	wg.Wait()
+	// The first client joins at once and each other one interval later.
+	if elapsed, want := time.Since(start), (clients-1)*time.Second/rate; elapsed < want {
+		t.Errorf("expected %d clients to take at least %s to join at %d per second, took %s", clients, want, rate, elapsed)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/gammazero/nexus/v3/router"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// normalizeRealm lowercases a realm URI and trims surrounding spaces and dots.
+func normalizeRealm(uri string) string {
+	return strings.ToLower(strings.Trim(strings.TrimSpace(uri), "."))
+}
+
+// normalizeKeys returns the per realm settings with their realm URIs
+// normalized.
+func normalizeKeys[V any](m map[wamp.URI]V) map[wamp.URI]V {
+	normalized := make(map[wamp.URI]V, len(m))
+	for uri, v := range m {
+		normalized[wamp.URI(normalizeRealm(string(uri)))] = v
+	}
+	return normalized
+}
+
+// normalizeRealmList normalizes -add-realm values, given as uri or
+// uri=prefix, dropping those that normalize to the default realm or to a realm
+// given before.
+func normalizeRealmList(values []string) []string {
+	seen := map[string]bool{realm: true}
+	var list []string
+	for _, s := range values {
+		uri, prefix, hasPrefix := strings.Cut(s, "=")
+		uri = normalizeRealm(uri)
+		if seen[uri] {
+			logger.Printf("realm %q is the same as realm %s, ignored\n", s, uri)
+			continue
+		}
+		seen[uri] = true
+		if hasPrefix {
+			uri += "=" + prefix
+		}
+		list = append(list, uri)
+	}
+	return list
+}
+
+// normalizingRouter normalizes the realm of the HELLO of each client attached
+// to the router, so clients can join regardless of the case of the realm.
+type normalizingRouter struct {
+	router.Router
+}
+
+func (r normalizingRouter) Attach(client wamp.Peer) error {
+	return r.Router.Attach(newNormalizingPeer(client))
+}
+
+func (r normalizingRouter) AttachClient(client wamp.Peer, transportDetails wamp.Dict) error {
+	return r.Router.AttachClient(newNormalizingPeer(client), transportDetails)
+}
+
+// normalizingPeer forwards the messages of a peer, normalizing the realm of
+// HELLO messages.
+type normalizingPeer struct {
+	wamp.Peer
+	recv      chan wamp.Message
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newNormalizingPeer(p wamp.Peer) *normalizingPeer {
+	np := &normalizingPeer{Peer: p, recv: make(chan wamp.Message), done: make(chan struct{})}
+	go func() {
+		defer close(np.recv)
+		for msg := range p.Recv() {
+			if hello, ok := msg.(*wamp.Hello); ok {
+				hello.Realm = wamp.URI(normalizeRealm(string(hello.Realm)))
+			}
+			select {
+			case np.recv <- msg:
+			case <-np.done:
+				return
+			}
+		}
+	}()
+	return np
+}
+
+func (p *normalizingPeer) Recv() <-chan wamp.Message {
+	return p.recv
+}
+
+func (p *normalizingPeer) Close() {
+	p.closeOnce.Do(func() { close(p.done) })
+	p.Peer.Close()
+}
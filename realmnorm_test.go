@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestNormalizeRealm(t *testing.T) {
+	logger = log.New(io.Discard, "", 0)
+	for in, want := range map[string]string{"Default": "default", " App.Tenant. ": "app.tenant", "a.b": "a.b"} {
+		if got := normalizeRealm(in); got != want {
+			t.Errorf("normalizeRealm(%q) = %q, want %q", in, got, want)
+		}
+	}
+	list := normalizeRealmList([]string{"Tenant=svc", "tenant.", "DEFAULT", "other"})
+	if strings.Join(list, ",") != "tenant=svc,other" {
+		t.Errorf("unexpected realms %v", list)
+	}
+	modes := normalizeKeys(map[wamp.URI]string{"Tenant.": "forbid", "other": "allow"})
+	if len(modes) != 2 || modes["tenant"] != "forbid" || modes["other"] != "allow" {
+		t.Errorf("unexpected normalized settings %v", modes)
+	}
+}
+
+func TestNormalizingRouter(t *testing.T) {
+	url := startTestRouter(t)
+	server := httptest.NewServer(newWebsocketServer(normalizingRouter{wsRouter}))
+	defer server.Close()
+	normURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	if c, err := client.ConnectNet(context.Background(), url, client.Config{Realm: "Default.", Logger: logger}); err == nil {
+		c.Close()
+		t.Fatal("expected joining Default. to fail without normalization")
+	}
+	var ids []wamp.ID
+	for _, uri := range []string{"Default.", "DEFAULT", "default"} {
+		ids = append(ids, connectTestClient(t, normURL, uri).ID())
+	}
+	res, err := testCall(getLocalClient(), string(wamp.MetaProcSessionList), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	joined := map[wamp.ID]bool{}
+	list, _ := wamp.AsList(res.Arguments[0])
+	for _, v := range list {
+		id, _ := wamp.AsID(v)
+		joined[id] = true
+	}
+	for _, id := range ids {
+		if !joined[id] {
+			t.Errorf("session %d is not in the %s realm", id, realm)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// Presence events published on -reconnect-topic.
+const (
+	presenceJoined      = "joined"
+	presenceReconnected = "reconnected"
+	presenceLeft        = "left"
+)
+
+// leftSession is a session that left less than -reconnect-window ago, whose
+// left event is published when timer fires.
+type leftSession struct {
+	session wamp.ID
+	at      time.Time
+	timer   *time.Timer
+}
+
+var (
+	presenceMu sync.Mutex
+	// presenceIDs maps the remote sessions of the default realm to their
+	// authids.
+	presenceIDs = map[wamp.ID]string{}
+	// recentLeaves holds the last session that left of each authid, until it
+	// reconnects or the window passes.
+	recentLeaves = map[string]*leftSession{}
+)
+
+// watchReconnects publishes presence events for the remote sessions of the
+// default realm on -reconnect-topic.  A session leaving is only announced
+// once -reconnect-window passes without a session of the same authid
+// joining, which is announced as reconnected instead of joined.
+func watchReconnects() error {
+	if err := onMetaEvent(wamp.MetaEventSessionOnJoin, presenceJoin); err != nil {
+		return err
+	}
+	return onMetaEvent(wamp.MetaEventSessionOnLeave, presenceLeave)
+}
+
+// presenceJoin handles the on_join meta event.
+func presenceJoin(event *wamp.Event) {
+	if len(event.Arguments) == 0 {
+		return
+	}
+	details, _ := wamp.AsDict(event.Arguments[0])
+	sid, _ := wamp.AsID(details["session"])
+	authid, _ := wamp.AsString(details["authid"])
+	if sid == 0 || authid == "" || details["authmethod"] == "local" {
+		return
+	}
+	presenceMu.Lock()
+	defer presenceMu.Unlock()
+	presenceIDs[sid] = authid
+	kwargs := wamp.Dict{"authid": authid, "session": sid}
+	phase := presenceJoined
+	// A stopped timer has not published the left event yet.
+	if left := recentLeaves[authid]; left != nil && left.timer.Stop() {
+		delete(recentLeaves, authid)
+		phase = presenceReconnected
+		kwargs["previous_session"] = left.session
+		kwargs["offline"] = time.Since(left.at).Seconds()
+	}
+	publishPresence(phase, kwargs)
+}
+
+// presenceLeave handles the on_leave meta event.
+func presenceLeave(event *wamp.Event) {
+	if len(event.Arguments) == 0 {
+		return
+	}
+	sid, _ := wamp.AsID(event.Arguments[0])
+	presenceMu.Lock()
+	defer presenceMu.Unlock()
+	authid, ok := presenceIDs[sid]
+	if !ok {
+		return
+	}
+	delete(presenceIDs, sid)
+	// Only the last session of an authid to leave is kept, the left events
+	// of earlier ones are published at once.
+	if prev := recentLeaves[authid]; prev != nil && prev.timer.Stop() {
+		publishPresence(presenceLeft, wamp.Dict{"authid": authid, "session": prev.session})
+	}
+	left := &leftSession{session: sid, at: time.Now()}
+	left.timer = time.AfterFunc(reconWindow, func() {
+		presenceMu.Lock()
+		defer presenceMu.Unlock()
+		if recentLeaves[authid] == left {
+			delete(recentLeaves, authid)
+		}
+		publishPresence(presenceLeft, wamp.Dict{"authid": authid, "session": sid})
+	})
+	recentLeaves[authid] = left
+}
+
+// publishPresence publishes a presence event with the phase added to kwargs.
+func publishPresence(phase string, kwargs wamp.Dict) {
+	kwargs["event"] = phase
+	if err := getLocalClient().Publish(reconTopic, nil, nil, kwargs); err != nil {
+		logger.Printf("reconnect: failed to publish %s event of %v: %s\n", phase, kwargs["authid"], err)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestReconnects(t *testing.T) {
+	savedTopic, savedWindow, savedTickets := reconTopic, reconWindow, tickets
+	defer func() { reconTopic, reconWindow, tickets = savedTopic, savedWindow, savedTickets }()
+	reconTopic, reconWindow = "test.presence", 200*time.Millisecond
+	tickets = ticketStore{"alice": {Ticket: "a", Role: "user"}}
+
+	url := startTestRouter(t)
+	presenceIDs, recentLeaves = map[wamp.ID]string{}, map[string]*leftSession{}
+	if err := watchReconnects(); err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan *wamp.Event, 10)
+	if err := connectTestClient(t, url, realm).SubscribeChan(reconTopic, events, nil); err != nil {
+		t.Fatal(err)
+	}
+	join := func() *client.Client {
+		c, err := client.ConnectNet(context.Background(), url, client.Config{
+			Realm:        realm,
+			Logger:       logger,
+			HelloDetails: wamp.Dict{"authid": "alice"},
+			AuthHandlers: map[string]client.AuthFunc{
+				"ticket": func(*wamp.Challenge) (string, wamp.Dict) { return "a", wamp.Dict{} },
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	// next returns the next presence event of alice, skipping those of the
+	// subscriber.
+	next := func(phase string, sid wamp.ID) wamp.Dict {
+		t.Helper()
+		for {
+			select {
+			case event := <-events:
+				if event.ArgumentsKw["authid"] != "alice" {
+					continue
+				}
+				if event.ArgumentsKw["event"] != phase || !sameID(event.ArgumentsKw["session"], sid) {
+					t.Fatalf("expected %s of session %d, got %v", phase, sid, event.ArgumentsKw)
+				}
+				return event.ArgumentsKw
+			case <-time.After(5 * time.Second):
+				t.Fatalf("expected %s of session %d", phase, sid)
+			}
+		}
+	}
+
+	first := join()
+	next(presenceJoined, first.ID())
+	first.Close()
+	second := join()
+	if kwargs := next(presenceReconnected, second.ID()); !sameID(kwargs["previous_session"], first.ID()) {
+		t.Errorf("expected the previous session %d, got %v", first.ID(), kwargs)
+	}
+
+	// A join after the window is a normal join.
+	second.Close()
+	next(presenceLeft, second.ID())
+	third := join()
+	next(presenceJoined, third.ID())
+	third.Close()
+	next(presenceLeft, third.ID())
+}
+
+// sameID reports whether v, as decoded from a message, is the ID.
+func sameID(v interface{}, id wamp.ID) bool {
+	got, ok := wamp.AsID(v)
+	return ok && got == id
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// registrySession is a remote session recorded in the session registry.
+type registrySession struct {
+	Realm    wamp.URI `json:"realm"`
+	Session  wamp.ID  `json:"session"`
+	AuthID   string   `json:"authid"`
+	AuthRole string   `json:"authrole"`
+}
+
+// sessionRegistry is the content of the -session-registry-file.
+type sessionRegistry struct {
+	Time     string            `json:"time"`
+	Sessions []registrySession `json:"sessions"`
+}
+
+// writeSessionRegistry records the remote sessions of the realms in path.  The
+// file is replaced atomically, so a failed write leaves the previous one.
+func writeSessionRegistry(ctx context.Context, path string, realms []wamp.URI) error {
+	registry := sessionRegistry{Time: time.Now().Format(time.RFC3339), Sessions: []registrySession{}}
+	for _, uri := range realms {
+		ids, err := realmSessionIDs(ctx, uri)
+		if err != nil {
+			logger.Printf("session registry: failed to list sessions of %s: %s\n", uri, err)
+			continue
+		}
+		c, _ := realmClient(uri)
+		for id := range ids {
+			res, err := c.Call(ctx, string(wamp.MetaProcSessionGet), nil, wamp.List{id}, nil, nil)
+			if err != nil {
+				continue
+			}
+			details, _ := wamp.AsDict(res.Arguments[0])
+			if details["authmethod"] == "local" {
+				continue
+			}
+			authid, _ := wamp.AsString(details["authid"])
+			authrole, _ := wamp.AsString(details["authrole"])
+			registry.Sessions = append(registry.Sessions, registrySession{uri, id, authid, authrole})
+		}
+	}
+	data, err := json.MarshalIndent(registry, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// adminRegistry handles <admin-prefix>.registry.  It returns the session
+// registry written when the router last shut down.
+func adminRegistry(ctx context.Context, inv *wamp.Invocation) client.InvokeResult {
+	data, err := os.ReadFile(sessRegFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return client.InvokeResult{Err: errInternal, Args: wamp.List{"no session registry written yet"}}
+	}
+	if err != nil {
+		return client.InvokeResult{Err: errInternal, Args: wamp.List{err.Error()}}
+	}
+	var registry wamp.Dict
+	if err = json.Unmarshal(data, &registry); err != nil {
+		return client.InvokeResult{Err: errInternal, Args: wamp.List{"invalid session registry: " + err.Error()}}
+	}
+	return client.InvokeResult{Args: wamp.List{registry}}
+}
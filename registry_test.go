@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestSessionRegistry(t *testing.T) {
+	savedFile, savedID, savedRole := sessRegFile, anonAuthID, anonRole
+	defer func() { sessRegFile, anonAuthID, anonRole = savedFile, savedID, savedRole }()
+	sessRegFile = filepath.Join(t.TempDir(), "sessions.json")
+	anonAuthID, anonRole = "sensor-1", adminRole
+
+	var registry sessionRegistry
+	t.Run("shutdown", func(t *testing.T) { testRegistryShutdown(t, &registry) })
+	if t.Failed() {
+		return
+	}
+
+	// After a restart the registry of the previous run is returned.
+	url := startTestRouter(t)
+	if err := createLocalCallee(getLocalClient(), adminPrefix+".registry", adminRegistry); err != nil {
+		t.Fatal(err)
+	}
+	res, err := testCall(connectTestClient(t, url, realm), adminPrefix+".registry", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := wamp.AsDict(res.Arguments[0])
+	sessions, _ := wamp.AsList(got["sessions"])
+	if len(sessions) != 1 || got["time"] != registry.Time {
+		t.Errorf("unexpected registry %v", got)
+	}
+}
+
+// testRegistryShutdown shuts a router down with a remote session and reads
+// the registry it writes.
+func testRegistryShutdown(t *testing.T, registry *sessionRegistry) {
+	url := startTestRouter(t, "other")
+	c := connectTestClient(t, url, "other")
+	steps := shutdownSteps([]wamp.URI{wamp.URI(realm), "other"}, []io.Closer{})
+	stopRouter(steps, 5*time.Second)
+	wsRouter = nil
+	select {
+	case <-c.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("session not disconnected on shutdown")
+	}
+
+	data, err := os.ReadFile(sessRegFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(data, registry); err != nil {
+		t.Fatal(err)
+	}
+	if len(registry.Sessions) != 1 {
+		t.Fatalf("expected the remote session only, got %+v", registry.Sessions)
+	}
+	if s := registry.Sessions[0]; s.Realm != "other" || s.AuthID != "sensor-1" || s.AuthRole != adminRole {
+		t.Errorf("unexpected session %+v", s)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// selfCheckProc returns the procedure the local client calls on itself with
+// -self-check-interval.  It is an admin procedure, so only admins can call it
+// remotely.
+func selfCheckProc() string {
+	return adminPrefix + ".selfcheck"
+}
+
+// adminSelfCheck handles <admin-prefix>.selfcheck, answering with nothing.
+func adminSelfCheck(ctx context.Context, inv *wamp.Invocation) client.InvokeResult {
+	return client.InvokeResult{}
+}
+
+// runSelfCheck calls <admin-prefix>.selfcheck every interval until quit is
+// closed, reporting the outcome as self_check in the health status.
+func runSelfCheck(interval time.Duration, quit <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			selfCheck(interval)
+		case <-quit:
+			return
+		}
+	}
+}
+
+// selfCheck routes a call from the local client back to itself through the
+// dealer within timeout, and records the outcome as self_check.
+func selfCheck(timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	_, err := localCall(ctx, wamp.URI(selfCheckProc()), nil, nil)
+	if err != nil {
+		logger.Printf("self check failed: %s\n", err)
+	}
+	setHealth("self_check", err)
+}
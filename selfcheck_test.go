@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSelfCheck(t *testing.T) {
+	startTestRouter(t)
+	if err := createLocalCallee(getLocalClient(), selfCheckProc(), adminSelfCheck); err != nil {
+		t.Fatal(err)
+	}
+	selfCheck(time.Second)
+	if got := healthStatus()["self_check"]; got != "ok" {
+		t.Fatalf("expected the self check to pass, got %q", got)
+	}
+
+	// Without the procedure the round trip through the dealer fails.
+	if err := getLocalClient().Unregister(selfCheckProc()); err != nil {
+		t.Fatal(err)
+	}
+	selfCheck(time.Second)
+	if got := healthStatus()["self_check"]; got == "ok" || got == "" {
+		t.Errorf("expected the self check to fail, got %q", got)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gammazero/nexus/v3/transport/serialize"
+	"github.com/gammazero/nexus/v3/wamp"
+	"github.com/gorilla/websocket"
+)
+
+// wsSubprotocols maps serializer names to their WebSocket subprotocols and
+// serializers, in the nexus default order.
+var wsSubprotocols = []struct {
+	name, subprotocol string
+	serializer        serialize.Serializer
+}{
+	{"json", "wamp.2.json", &serialize.JSONSerializer{}},
+	{"msgpack", "wamp.2.msgpack", &serialize.MessagePackSerializer{}},
+	{"cbor", "wamp.2.cbor", &serialize.CBORSerializer{}},
+}
+
+// serializerSizes maps serializer names to the maximum size of the calls and
+// publishes of WebSocket sessions using them, set with -serializer-max-sizes.
+var serializerSizes = map[string]int{}
+
+// realmSerializers maps realms to the serializers their WebSocket sessions may
+// use, set with -realm-serializers.  Realms missing from it allow all of them.
+var realmSerializers = map[wamp.URI]map[string]bool{}
+
+// parseRealmSerializers merges a comma separated list of
+// realm=serializer+serializer entries into realmSerializers.
+func parseRealmSerializers(s string) error {
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		uri, list, ok := strings.Cut(entry, "=")
+		if !ok || !wamp.URI(uri).ValidURI(false, "") || list == "" {
+			return fmt.Errorf("invalid realm serializers %q, expected realm=serializer+serializer", entry)
+		}
+		allowed := map[string]bool{}
+		for _, name := range strings.Split(list, "+") {
+			if _, err := subprotocolOrder(name); err != nil || name == "" {
+				return fmt.Errorf("invalid serializer %q in %q, expected json, msgpack or cbor", name, entry)
+			}
+			allowed[name] = true
+		}
+		realmSerializers[wamp.URI(uri)] = allowed
+	}
+	return nil
+}
+
+// checkRealmSerializer rejects WebSocket sessions joining the realm with a
+// serializer it does not allow.  RawSocket sessions pass, as nexus does not
+// tell the router which serializer they negotiated.
+func checkRealmSerializer(uri wamp.URI, details wamp.Dict) error {
+	allowed, ok := realmSerializers[uri]
+	if !ok {
+		return nil
+	}
+	req, _ := wamp.DictChild(wamp.DictChild(details, "transport"), "auth")["request"].(*http.Request)
+	if req == nil {
+		return nil
+	}
+	subprotocol := negotiatedSubprotocol(req)
+	for _, p := range wsSubprotocols {
+		if p.subprotocol == subprotocol && !allowed[p.name] {
+			return &abortError{abortSerializer, fmt.Errorf("serializer_not_allowed: realm %s does not allow the %s serializer", uri, p.name)}
+		}
+	}
+	return nil
+}
+
+// parseSerializerSizes merges a comma separated list of serializer=bytes
+// pairs into serializerSizes.
+func parseSerializerSizes(s string) error {
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		size, err := strconv.Atoi(value)
+		if !ok || err != nil || size <= 0 {
+			return fmt.Errorf("invalid serializer size %q, expected serializer=bytes", pair)
+		}
+		if _, err = subprotocolOrder(name); err != nil {
+			return err
+		}
+		serializerSizes[name] = size
+	}
+	return nil
+}
+
+// checkSerializerSize rejects calls and publishes larger than the limit of
+// the session's serializer.  The size is that of the message encoded with
+// the serializer again, as the router only gets it decoded.
+func checkSerializerSize(sess *wamp.Session, msg wamp.Message) error {
+	if len(serializerSizes) == 0 {
+		return nil
+	}
+	switch msg.(type) {
+	case *wamp.Call, *wamp.Publish:
+	default:
+		return nil
+	}
+	req, _ := wamp.DictChild(wamp.DictChild(sess.Details, "transport"), "auth")["request"].(*http.Request)
+	if req == nil {
+		return nil
+	}
+	subprotocol := negotiatedSubprotocol(req)
+	for _, p := range wsSubprotocols {
+		if p.subprotocol != subprotocol {
+			continue
+		}
+		limit, ok := serializerSizes[p.name]
+		if !ok {
+			return nil
+		}
+		data, err := p.serializer.Serialize(msg)
+		if err == nil && len(data) > limit {
+			return fmt.Errorf("message_too_large: %s message of %d bytes is over the %d byte limit of %s sessions", msg.MessageType(), len(data), limit, p.name)
+		}
+		return nil
+	}
+	return nil
+}
+
+// negotiatedSubprotocol returns the subprotocol gorilla picked for the upgrade
+// request: the first of the -serializer-preference offered by the client.
+func negotiatedSubprotocol(req *http.Request) string {
+	order, _ := subprotocolOrder(serializers)
+	offered := websocket.Subprotocols(req)
+	for _, subprotocol := range order {
+		for _, o := range offered {
+			if o == subprotocol {
+				return subprotocol
+			}
+		}
+	}
+	return ""
+}
+
+// subprotocolOrder returns the WebSocket subprotocols ordered by a comma
+// separated serializer preference.  Serializers missing from the preference
+// follow in the default order.
+func subprotocolOrder(pref string) ([]string, error) {
+	var order []string
+	seen := map[string]bool{}
+	for _, name := range strings.Split(pref, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, p := range wsSubprotocols {
+			if p.name == name {
+				found = true
+				if !seen[name] {
+					order = append(order, p.subprotocol)
+					seen[name] = true
+				}
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown serializer %q", name)
+		}
+	}
+	for _, p := range wsSubprotocols {
+		if !seen[p.name] {
+			order = append(order, p.subprotocol)
+		}
+	}
+	return order, nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/transport/serialize"
+	"github.com/gammazero/nexus/v3/wamp"
+	"github.com/gorilla/websocket"
+)
+
+func TestSubprotocolOrder(t *testing.T) {
+	order, err := subprotocolOrder("cbor, msgpack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"wamp.2.cbor", "wamp.2.msgpack", "wamp.2.json"}
+	for i := range want {
+		if i >= len(order) || order[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, order)
+		}
+	}
+	if _, err = subprotocolOrder("json,xml"); err == nil {
+		t.Error("expected an error for an unknown serializer")
+	}
+}
+
+func TestSerializerPreference(t *testing.T) {
+	saved := serializers
+	defer func() { serializers = saved }()
+
+	for pref, want := range map[string]string{
+		"msgpack,json": "wamp.2.msgpack",
+		"json,msgpack": "wamp.2.json",
+	} {
+		serializers = pref
+		url := startTestRouter(t)
+		dialer := websocket.Dialer{Subprotocols: []string{"wamp.2.json", "wamp.2.msgpack"}}
+		conn, _, err := dialer.Dial(url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := conn.Subprotocol(); got != want {
+			t.Errorf("preference %s: expected %s, got %s", pref, want, got)
+		}
+		conn.Close()
+	}
+}
+
+func TestSerializerParam(t *testing.T) {
+	startTestRouter(t)
+	server := httptest.NewServer(serializerParam(newWebsocketServer(wsRouter), "serializer"))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	for _, tc := range []struct {
+		query, offered, want string
+	}{
+		{"?serializer=msgpack", "", "wamp.2.msgpack"},
+		{"?serializer=msgpack", "wamp.2.json", "wamp.2.json"},
+		{"", "", ""},
+	} {
+		dialer := websocket.Dialer{}
+		if tc.offered != "" {
+			dialer.Subprotocols = []string{tc.offered}
+		}
+		conn, _, err := dialer.Dial(url+tc.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := conn.Subprotocol(); got != tc.want {
+			t.Errorf("%q offering %q: expected %q, got %q", tc.query, tc.offered, tc.want, got)
+		}
+		conn.Close()
+	}
+
+	// The negotiated serializer is used for the session.
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?serializer=json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err = conn.WriteMessage(websocket.TextMessage, []byte(`[1, "`+realm+`", {"roles": {"caller": {}}}]`)); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, msg, err := conn.ReadMessage(); err != nil || !strings.HasPrefix(string(msg), "[2,") {
+		t.Errorf("expected WELCOME, got %s %v", msg, err)
+	}
+}
+
+func TestSerializerMaxSizes(t *testing.T) {
+	saved := serializerSizes
+	defer func() { serializerSizes = saved }()
+	serializerSizes = map[string]int{}
+	if err := parseSerializerSizes("json=512,msgpack=4096"); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"json", "json=0", "xml=10", "json=big"} {
+		if err := parseSerializerSizes(s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+
+	url := startTestRouter(t)
+	err := createLocalCallee(getLocalClient(), "test.echo", func(_ context.Context, inv *wamp.Invocation) client.InvokeResult {
+		return client.InvokeResult{Args: inv.Arguments}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := wamp.List{strings.Repeat("x", 1024)}
+	for _, tc := range []struct {
+		serialization serialize.Serialization
+		allowed       bool
+	}{
+		{serialize.JSON, false},
+		{serialize.MSGPACK, true},
+	} {
+		c, err := client.ConnectNet(context.Background(), url, client.Config{Realm: realm, Serialization: tc.serialization, Logger: logger})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		_, err = testCall(c, "test.echo", payload, nil)
+		if tc.allowed && err != nil {
+			t.Errorf("serialization %d: expected the call to pass, got %s", tc.serialization, err)
+		}
+		if !tc.allowed && (errorURI(err) != wamp.ErrAuthorizationFailed || !strings.Contains(err.Error(), "message_too_large")) {
+			t.Errorf("serialization %d: expected the call to be rejected as too large, got %v", tc.serialization, err)
+		}
+	}
+}
+
+func TestRealmSerializers(t *testing.T) {
+	saved := realmSerializers
+	defer func() { realmSerializers = saved }()
+	realmSerializers = map[wamp.URI]map[string]bool{}
+	if err := parseRealmSerializers("backend=msgpack+cbor"); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"backend", "backend=", "backend=xml", "backend=json+", "=json"} {
+		if err := parseRealmSerializers(s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+
+	url := startTestRouter(t, "backend")
+	for _, tc := range []struct {
+		realm         string
+		serialization serialize.Serialization
+		allowed       bool
+	}{
+		{"backend", serialize.JSON, false},
+		{"backend", serialize.MSGPACK, true},
+		{realm, serialize.JSON, true},
+	} {
+		c, err := client.ConnectNet(context.Background(), url, client.Config{Realm: tc.realm, Serialization: tc.serialization, Logger: logger})
+		if tc.allowed && err != nil {
+			t.Errorf("%s with serialization %d: expected the join to pass, got %s", tc.realm, tc.serialization, err)
+		}
+		if !tc.allowed && (err == nil || !strings.Contains(err.Error(), "serializer_not_allowed")) {
+			t.Errorf("%s with serialization %d: expected the join to be refused, got %v", tc.realm, tc.serialization, err)
+		}
+		if err == nil {
+			c.Close()
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// sessionLimit caps the number of subscriptions or registrations of each
+// session.  The authorizer admits each request against the limit, and the
+// meta events of the realms keep the count to what the router accepted.
+// Admitted requests count as pending until their meta event arrives, so
+// requests sent without waiting for replies cannot get past the limit.
+// Pending requests that the router refused, or that were duplicates, stop
+// counting after admitGrace.
+type sessionLimit struct {
+	name string
+	max  int
+
+	mu      sync.Mutex
+	ids     map[wamp.ID]map[wamp.ID]struct{}
+	pending map[wamp.ID][]time.Time
+}
+
+func newSessionLimit(name string, max int) *sessionLimit {
+	return &sessionLimit{
+		name:    name,
+		max:     max,
+		ids:     map[wamp.ID]map[wamp.ID]struct{}{},
+		pending: map[wamp.ID][]time.Time{},
+	}
+}
+
+// admit counts a request of the session, or returns an error if the session
+// is at the limit.
+func (l *sessionLimit) admit(sid wamp.ID) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	pending := l.pending[sid]
+	for len(pending) > 0 && time.Since(pending[0]) > admitGrace {
+		pending = pending[1:]
+	}
+	l.pending[sid] = pending
+	if len(l.ids[sid])+len(pending) >= l.max {
+		return fmt.Errorf("session %d is at its limit of %d %s", sid, l.max, l.name)
+	}
+	l.pending[sid] = append(pending, time.Now())
+	return nil
+}
+
+// add records that the router accepted a request of the session.
+func (l *sessionLimit) add(sid, id wamp.ID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ids := l.ids[sid]
+	if ids == nil {
+		ids = map[wamp.ID]struct{}{}
+		l.ids[sid] = ids
+	}
+	ids[id] = struct{}{}
+	if pending := l.pending[sid]; len(pending) > 0 {
+		l.pending[sid] = pending[1:]
+	}
+}
+
+// remove frees the slot of a subscription or registration of the session.
+func (l *sessionLimit) remove(sid, id wamp.ID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.ids[sid], id)
+}
+
+// forget drops the counts of a session that left.
+func (l *sessionLimit) forget(sid wamp.ID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.ids, sid)
+	delete(l.pending, sid)
+}
+
+// watch keeps the counts of the realm's sessions up to date from the meta
+// events announcing added and removed subscriptions or registrations, whose
+// arguments are the session and subscription or registration IDs.
+func (l *sessionLimit) watch(uri, added, removed wamp.URI) error {
+	handler := func(update func(sid, id wamp.ID)) func(*wamp.Event) {
+		return func(event *wamp.Event) {
+			if len(event.Arguments) < 2 {
+				return
+			}
+			sid, _ := wamp.AsID(event.Arguments[0])
+			id, _ := wamp.AsID(event.Arguments[1])
+			update(sid, id)
+		}
+	}
+	if err := onRealmMetaEvent(uri, added, handler(l.add)); err != nil {
+		return err
+	}
+	if err := onRealmMetaEvent(uri, removed, handler(l.remove)); err != nil {
+		return err
+	}
+	return onRealmMetaEvent(uri, wamp.MetaEventSessionOnLeave, func(event *wamp.Event) {
+		if len(event.Arguments) > 0 {
+			if sid, ok := wamp.AsID(event.Arguments[0]); ok {
+				l.forget(sid)
+			}
+		}
+	})
+}
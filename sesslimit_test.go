@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestMaxSubsPerSession(t *testing.T) {
+	defer func() { subsLimit = nil }()
+	subsLimit = newSessionLimit("subscriptions", 2)
+
+	url := startTestRouter(t, "other")
+	for _, uri := range []wamp.URI{wamp.URI(realm), "other"} {
+		if err := subsLimit.watch(uri, wamp.MetaEventSubOnSubscribe, wamp.MetaEventSubOnUnsubscribe); err != nil {
+			t.Fatal(err)
+		}
+	}
+	handler := func(*wamp.Event) {}
+
+	for _, uri := range []string{realm, "other"} {
+		c := connectTestClient(t, url, uri)
+		for _, topic := range []string{"test.a", "test.b"} {
+			if err := c.Subscribe(topic, handler, nil); err != nil {
+				t.Fatalf("%s: %s", uri, err)
+			}
+		}
+		err := c.Subscribe("test.c", handler, nil)
+		if err == nil || !strings.Contains(err.Error(), string(wamp.ErrAuthorizationFailed)) {
+			t.Fatalf("%s: expected %s over the limit, got %v", uri, wamp.ErrAuthorizationFailed, err)
+		}
+
+		if err = c.Unsubscribe("test.a"); err != nil {
+			t.Fatal(err)
+		}
+		if !waitFor(t, 5*time.Second, func() bool { return c.Subscribe("test.c", handler, nil) == nil }) {
+			t.Errorf("%s: unsubscribing did not free a slot", uri)
+		}
+	}
+}
+
+func TestMaxRegsPerSession(t *testing.T) {
+	defer func() { regsLimit = nil }()
+	regsLimit = newSessionLimit("registrations", 2)
+
+	url := startTestRouter(t)
+	if err := regsLimit.watch(wamp.URI(realm), wamp.MetaEventRegOnRegister, wamp.MetaEventRegOnUnregister); err != nil {
+		t.Fatal(err)
+	}
+	handler := func(context.Context, *wamp.Invocation) client.InvokeResult { return client.InvokeResult{} }
+
+	c := connectTestClient(t, url, realm)
+	for _, procedure := range []string{"test.a", "test.b"} {
+		if err := c.Register(procedure, handler, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := c.Register("test.c", handler, nil)
+	if err == nil || !strings.Contains(err.Error(), string(wamp.ErrAuthorizationFailed)) {
+		t.Fatalf("expected %s over the limit, got %v", wamp.ErrAuthorizationFailed, err)
+	}
+	// Other sessions have their own limit.
+	if err = connectTestClient(t, url, realm).Register("test.c", handler, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = c.Unregister("test.a"); err != nil {
+		t.Fatal(err)
+	}
+	if !waitFor(t, 5*time.Second, func() bool { return c.Register("test.d", handler, nil) == nil }) {
+		t.Error("unregistering did not free a slot")
+	}
+}
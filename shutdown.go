@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// shutdownStep is one step of the router teardown.
+type shutdownStep struct {
+	name string
+	run  func(ctx context.Context)
+}
+
+// shutdownSteps returns the router teardown in order: stop the publishers,
+// stop accepting connections, record the remote sessions with
+// -session-registry-file, disconnect the remote sessions of each realm, close
+// the local clients and finally close the router.
+func shutdownSteps(realms []wamp.URI, listeners []io.Closer) []shutdownStep {
+	steps := []shutdownStep{
+		{"stop publishers", func(ctx context.Context) {
+			close(publishersQuit)
+			for _, p := range publishers {
+				select {
+				case <-p.done:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}},
+		{"stop listeners", func(ctx context.Context) {
+			for _, l := range listeners {
+				l.Close()
+			}
+		}},
+	}
+	if sessRegFile != "" {
+		steps = append(steps, shutdownStep{"write session registry", func(ctx context.Context) {
+			if err := writeSessionRegistry(ctx, sessRegFile, realms); err != nil {
+				logger.Printf("shutdown: failed to write session registry: %s\n", err)
+			}
+		}})
+	}
+	return append(steps, []shutdownStep{
+		{"drain sessions", func(ctx context.Context) {
+			for _, uri := range realms {
+				c, err := realmClient(uri)
+				if err != nil {
+					continue
+				}
+				_, err = c.Call(ctx, string(wamp.MetaProcSessionKillAll), nil, nil, wamp.Dict{
+					"reason": wamp.CloseSystemShutdown,
+				}, nil)
+				if err != nil {
+					logger.Printf("shutdown: failed to disconnect sessions of %s: %s\n", uri, err)
+				}
+			}
+		}},
+		{"close local clients", func(ctx context.Context) {
+			realmClientsMu.Lock()
+			for _, c := range realmClients {
+				c.Close()
+			}
+			realmClientsMu.Unlock()
+			closeExtraLocalClients()
+			getLocalClient().Close()
+		}},
+		{"close router", func(ctx context.Context) {
+			wsRouter.Close()
+		}},
+	}...)
+}
+
+// stopRouter runs the shutdown steps in order.  Each step is given up to timeout, after
+// which it is left running in the background and the next step starts.  The
+// draining and stopped lifecycle events surround the steps.
+func stopRouter(steps []shutdownStep, timeout time.Duration) {
+	emitLifecycle(lifecycleDraining, nil)
+	for _, step := range steps {
+		logger.Printf("shutdown: %s\n", step.name)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		done := make(chan struct{})
+		go func(step shutdownStep) {
+			defer close(done)
+			step.run(ctx)
+		}(step)
+		select {
+		case <-done:
+		case <-ctx.Done():
+			logger.Printf("shutdown: %s timed out after %s\n", step.name, timeout)
+		}
+		cancel()
+	}
+	emitLifecycle(lifecycleStopped, nil)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestShutdownOrder(t *testing.T) {
+	url := startTestRouter(t, "other")
+	sessions := []*client.Client{connectTestClient(t, url, realm), connectTestClient(t, url, "other")}
+	publisherDone := false
+	startPublisher("test", func(quit <-chan struct{}) {
+		<-quit
+		publisherDone = true
+	})
+	listener, err := newRawSocketServer(wsRouter).ListenAndServe("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.(net.Listener).Addr().String()
+
+	// Each check runs right after its step and verifies that step is done
+	// while the following ones have not run yet.
+	checks := map[string]func(){
+		"stop publishers": func() {
+			if !publisherDone {
+				t.Error("publisher still running")
+			}
+			if _, err := net.Dial("tcp", addr); err != nil {
+				t.Error("listener closed before the publishers stopped")
+			}
+		},
+		"stop listeners": func() {
+			if conn, err := net.Dial("tcp", addr); err == nil {
+				conn.Close()
+				t.Error("listener still accepting")
+			}
+			for _, s := range sessions {
+				if !s.Connected() {
+					t.Error("session disconnected before draining")
+				}
+			}
+		},
+		"drain sessions": func() {
+			for _, s := range sessions {
+				select {
+				case <-s.Done():
+				case <-time.After(5 * time.Second):
+					t.Error("session not disconnected")
+				}
+			}
+			if !getLocalClient().Connected() {
+				t.Error("local client closed before the sessions were drained")
+			}
+		},
+		"close local clients": func() {
+			if getLocalClient().Connected() {
+				t.Error("local client still connected")
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if _, err := realmSessionIDs(ctx, "other"); err != nil {
+				t.Errorf("router closed before the local clients: %s", err)
+			}
+		},
+		"close router": func() {},
+	}
+
+	var order []string
+	steps := shutdownSteps([]wamp.URI{wamp.URI(realm), "other"}, []io.Closer{listener})
+	for i := range steps {
+		step := steps[i]
+		steps[i].run = func(ctx context.Context) {
+			step.run(ctx)
+			order = append(order, step.name)
+			checks[step.name]()
+		}
+	}
+	stopRouter(steps, 5*time.Second)
+	wsRouter = nil
+
+	want := []string{"stop publishers", "stop listeners", "drain sessions", "close local clients", "close router"}
+	if len(order) != len(want) {
+		t.Fatalf("expected steps %v, got %v", want, order)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Errorf("step %d: expected %s, got %s", i, want[i], order[i])
+		}
+	}
+}
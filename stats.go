@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+var startTime = time.Now()
+
+// publishStats publishes router health stats on topic every interval until
+// quit is closed.  Sessions are those of the default realm, not counting the
+// local client, while messages are counted across all realms, with the calls
+// and publishes of each realm under realms.
+func publishStats(topic string, interval time.Duration, quit <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	lastCount := atomic.LoadUint64(&messageCount)
+	lastTime := time.Now()
+	for {
+		select {
+		case now := <-ticker.C:
+			count := atomic.LoadUint64(&messageCount)
+			rate := float64(count-lastCount) / now.Sub(lastTime).Seconds()
+			lastCount, lastTime = count, now
+
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			sessions, err := realmSessionCount(ctx, wamp.URI(realm))
+			if err != nil {
+				logger.Printf("stats: failed to count sessions: %s\n", err)
+			}
+			headroom := headroomStats(ctx)
+			cancel()
+			stats := wamp.Dict{
+				"sessions":            sessions,
+				"messages":            count,
+				"large_messages":      atomic.LoadUint64(&largeMessageCount),
+				"dropped_publishes":   atomic.LoadUint64(&droppedPublishes),
+				"duplicate_publishes": atomic.LoadUint64(&duplicatePublishes),
+				"auth":                authStats(),
+				"transport_errors":    transportErrorStats(),
+				"realms":              realmStats(),
+				"headroom":            headroom,
+				"message_rate":        rate,
+				"uptime":              now.Sub(startTime).Seconds(),
+				"time":                formatTime(now),
+			}
+			if countFanout {
+				stats["fanout"] = fanoutStats()
+			}
+			if countConns {
+				stats["connections"] = connStats()
+			}
+			if topoEvery > 0 {
+				stats["topology"] = topologyStats()
+			}
+			if countAcks {
+				stats["publish_acks"] = ackStats()
+			}
+			localPublish(topic, wamp.List{stats}, nil)
+		case <-quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestPublishStats(t *testing.T) {
+	url := startTestRouter(t)
+	c := connectTestClient(t, url, realm)
+	events := make(chan *wamp.Event, 1)
+	if err := c.SubscribeChan("test.stats", events, nil); err != nil {
+		t.Fatal(err)
+	}
+	runUntilEnd(t, func(quit <-chan struct{}) { publishStats("test.stats", 50*time.Millisecond, quit) })
+
+	select {
+	case event := <-events:
+		stats, _ := wamp.AsDict(event.Arguments[0])
+		if sessions, _ := wamp.AsInt64(stats["sessions"]); sessions != 1 {
+			t.Errorf("expected 1 session without the local client, got %v", stats["sessions"])
+		}
+		if messages, _ := wamp.AsInt64(stats["messages"]); messages < 1 {
+			t.Errorf("expected the subscribe to be counted, got %v", stats["messages"])
+		}
+		for _, key := range []string{"message_rate", "auth", "realms", "uptime", "time"} {
+			if _, ok := stats[key]; !ok {
+				t.Errorf("missing %s in stats %v", key, stats)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no stats published")
+	}
+}
+
+func TestRealmStats(t *testing.T) {
+	url := startTestRouter(t, "busy", "quiet")
+	busy := connectTestClient(t, url, "busy")
+	connectTestClient(t, url, "quiet")
+	for i := 0; i < 3; i++ {
+		if err := busy.Publish("test.topic", wamp.Dict{wamp.OptAcknowledge: true}, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats := realmStats()
+	for uri, want := range map[string]int64{"busy": 3, "quiet": 0, realm: 0} {
+		counts, _ := wamp.AsDict(stats[uri])
+		if publishes, _ := wamp.AsInt64(counts["publishes"]); publishes != want {
+			t.Errorf("expected %d publishes in %s, got %v", want, uri, counts)
+		}
+	}
+}
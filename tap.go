@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// startTaps subscribes the local client to each -tap topic of the default
+// realm, logging the events it receives.  Meta topics share the
+// subscriptions of the meta event handlers.
+func startTaps(topics []string) error {
+	for _, topic := range topics {
+		handler := tapHandler(topic)
+		var err error
+		if strings.HasPrefix(topic, "wamp.") {
+			err = onMetaEvent(wamp.URI(topic), handler)
+		} else {
+			err = createLocalSubscriber(topic, handler, nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tapHandler logs the events of a tapped topic with their publication ID and
+// payload, formatted as at trace level.
+func tapHandler(topic string) func(*wamp.Event) {
+	return func(event *wamp.Event) {
+		logger.Printf("tap: %s publication %d %s\n", topic, event.Publication, formatPayload(event.Arguments, event.ArgumentsKw))
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// lockedBuffer collects log output written from several goroutines.
+type lockedBuffer struct {
+	mu sync.Mutex
+	sb strings.Builder
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.sb.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.sb.String()
+}
+
+func TestTap(t *testing.T) {
+	saved := traceRedacted
+	defer func() { traceRedacted = saved }()
+	traceRedacted = map[string]bool{"password": true}
+
+	url := startTestRouter(t)
+	out := &lockedBuffer{}
+	logger = log.New(out, "", 0)
+	if err := startTaps([]string{"test.audit", string(wamp.MetaEventSessionOnJoin)}); err != nil {
+		t.Fatal(err)
+	}
+	pub := connectTestClient(t, url, realm)
+	err := pub.Publish("test.audit", wamp.Dict{wamp.OptAcknowledge: true}, wamp.List{"login"}, wamp.Dict{"password": "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tapped := waitFor(t, 5*time.Second, func() bool {
+		return strings.Contains(out.String(), "tap: test.audit publication") && strings.Contains(out.String(), "tap: wamp.session.on_join")
+	})
+	if !tapped {
+		t.Fatalf("expected the events of both taps to be logged, got %q", out.String())
+	}
+	if log := out.String(); !strings.Contains(log, `{"args":["login"],"kwargs":{"password":"[redacted]"}}`) || strings.Contains(log, "secret") {
+		t.Errorf("expected the redacted event payload to be logged, got %q", log)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gammazero/nexus/v3/router/auth"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// ticketTimeout bounds the wait for the AUTHENTICATE answering a ticket
+// challenge.
+const ticketTimeout = 10 * time.Second
+
+// ticketEntry is the ticket and authrole of an authid in the -ticket-file.
+type ticketEntry struct {
+	Ticket string `json:"ticket"`
+	Role   string `json:"role"`
+}
+
+// ticketStore maps authids to their ticket, as the nexus key store of the
+// ticket authenticator.
+type ticketStore map[string]ticketEntry
+
+// tickets holds the -ticket-file entries, or nil without ticket
+// authentication.
+var tickets ticketStore
+
+// loadTickets reads a JSON object mapping authids to their ticket and role.
+func loadTickets(path string) (ticketStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var store ticketStore
+	if err = json.Unmarshal(data, &store); err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %s", path, err)
+	}
+	for authid, entry := range store {
+		if authid == "" || entry.Ticket == "" || entry.Role == "" {
+			return nil, fmt.Errorf("ticket of %q requires an authid, ticket and role", authid)
+		}
+	}
+	return store, nil
+}
+
+func (s ticketStore) AuthKey(authid, authmethod string) ([]byte, error) {
+	entry, ok := s[authid]
+	if !ok || authmethod != "ticket" {
+		return nil, errors.New("no ticket")
+	}
+	return []byte(entry.Ticket), nil
+}
+
+func (s ticketStore) PasswordInfo(string) (string, int, int) {
+	return "", 0, 0
+}
+
+func (s ticketStore) AuthRole(authid string) (string, error) {
+	entry, ok := s[authid]
+	if !ok {
+		return "", errors.New("no ticket")
+	}
+	return entry.Role, nil
+}
+
+func (s ticketStore) Provider() string {
+	return "ticket-file"
+}
+
+// ticketAuth authenticates sessions with the ticket of their authid in the
+// -ticket-file, giving them its role.  It applies the same join checks and
+// WELCOME details as anonymousAuth, which keeps serving sessions of the realm
+// asking for no other method.
+type ticketAuth struct {
+	*auth.TicketAuthenticator
+	realm wamp.URI
+	agent string
+	extra wamp.Dict
+}
+
+func newTicketAuth(uri wamp.URI, store ticketStore, agent string, extra wamp.Dict) *ticketAuth {
+	return &ticketAuth{auth.NewTicketAuthenticator(store, ticketTimeout), uri, agent, extra}
+}
+
+func (a *ticketAuth) Authenticate(sid wamp.ID, details wamp.Dict, client wamp.Peer) (*wamp.Welcome, error) {
+	filterAuthExtra(details)
+	err := checkJoin(a.realm, sid, details)
+	var welcome *wamp.Welcome
+	if err == nil {
+		welcome, err = a.TicketAuthenticator.Authenticate(sid, details, client)
+	}
+	var authid string
+	if err == nil {
+		authid, _ = wamp.AsString(welcome.Details["authid"])
+		err = admitUnique(a.realm, sid, authid)
+	}
+	countAuth(a.AuthMethod(), err)
+	if err != nil {
+		return nil, err
+	}
+	expireSession(a.realm, sid)
+	authrole, _ := wamp.AsString(welcome.Details["authrole"])
+	provider, _ := wamp.AsString(welcome.Details["authprovider"])
+	return newWelcome(authid, authrole, provider, a.AuthMethod(), details, a.agent, a.extra), nil
+}
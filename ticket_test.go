@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestLoadTickets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tickets.json")
+	if err := os.WriteFile(path, []byte(`{"alice": {"ticket": "secret", "role": "user"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store, err := loadTickets(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store["alice"] != (ticketEntry{"secret", "user"}) {
+		t.Errorf("unexpected tickets %v", store)
+	}
+	if err = os.WriteFile(path, []byte(`{"bob": {"ticket": "secret"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = loadTickets(path); err == nil {
+		t.Error("expected an error for a ticket without role")
+	}
+}
+
+func TestTicketAuth(t *testing.T) {
+	savedTickets, savedRole := tickets, anonRole
+	defer func() { tickets, anonRole = savedTickets, savedRole }()
+	tickets = ticketStore{"alice": {Ticket: "secret", Role: "user"}}
+	anonRole = "guest"
+	url := startTestRouter(t)
+
+	// join connects with the ticket as alice, or anonymously without one.
+	join := func(ticket string) (*client.Client, error) {
+		cfg := client.Config{Realm: realm, Logger: logger}
+		if ticket != "" {
+			cfg.HelloDetails = wamp.Dict{"authid": "alice"}
+			cfg.AuthHandlers = map[string]client.AuthFunc{
+				"ticket": func(*wamp.Challenge) (string, wamp.Dict) { return ticket, wamp.Dict{} },
+			}
+		}
+		c, err := client.ConnectNet(context.Background(), url, cfg)
+		if err == nil {
+			t.Cleanup(func() { c.Close() })
+		}
+		return c, err
+	}
+
+	for ticket, want := range map[string]string{"": "guest", "secret": "user"} {
+		c, err := join(ticket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := testCall(getLocalClient(), string(wamp.MetaProcSessionGet), wamp.List{c.ID()}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		details, _ := wamp.AsDict(res.Arguments[0])
+		if details["authrole"] != want {
+			t.Errorf("ticket %q: expected authrole %s, got %v", ticket, want, details)
+		}
+		if ticket != "" && (details["authid"] != "alice" || details["authmethod"] != "ticket" || details["transport_type"] != "websocket") {
+			t.Errorf("unexpected ticket session details %v", details)
+		}
+	}
+
+	if _, err := join("wrong"); err == nil {
+		t.Error("expected a wrong ticket to be rejected")
+	}
+	if failures := authStats()["ticket"].(wamp.Dict)["failures"]; failures != uint64(1) {
+		t.Errorf("expected one ticket failure, got %v", failures)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// Formats of the times published on dev.time and in the stats, set with
+// -dtime-format.
+const (
+	timeRFC3339   = "rfc3339"
+	timeUnix      = "unix"
+	timeUnixMilli = "unixmilli"
+	timeDict      = "dict"
+)
+
+// checkTimeFormat returns an error for an unknown -dtime-format.
+func checkTimeFormat(format string) error {
+	switch format {
+	case timeRFC3339, timeUnix, timeUnixMilli, timeDict:
+		return nil
+	}
+	return fmt.Errorf("invalid time format %q, expected rfc3339, unix, unixmilli or dict", format)
+}
+
+// formatTime returns t as published with the -dtime-format: an RFC 3339
+// string, seconds or milliseconds since the epoch, or a dict of its parts in
+// the local time zone.
+func formatTime(t time.Time) interface{} {
+	switch dtimeFormat {
+	case timeUnix:
+		return t.Unix()
+	case timeUnixMilli:
+		return t.UnixMilli()
+	case timeDict:
+		zone, offset := t.Zone()
+		return wamp.Dict{
+			"year":        t.Year(),
+			"month":       int(t.Month()),
+			"day":         t.Day(),
+			"hour":        t.Hour(),
+			"minute":      t.Minute(),
+			"second":      t.Second(),
+			"millisecond": t.Nanosecond() / int(time.Millisecond),
+			"zone":        zone,
+			"offset":      offset,
+		}
+	}
+	return t.Format(time.RFC3339)
+}
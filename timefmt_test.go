@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestFormatTime(t *testing.T) {
+	saved := dtimeFormat
+	defer func() { dtimeFormat = saved }()
+	now := time.Date(2024, 5, 6, 7, 8, 9, 250*int(time.Millisecond), time.UTC)
+
+	dtimeFormat = timeRFC3339
+	if got, ok := formatTime(now).(string); !ok || got != "2024-05-06T07:08:09Z" {
+		t.Errorf("rfc3339: unexpected %#v", formatTime(now))
+	}
+	dtimeFormat = timeUnix
+	if got, ok := formatTime(now).(int64); !ok || got != now.Unix() {
+		t.Errorf("unix: unexpected %#v", formatTime(now))
+	}
+	dtimeFormat = timeUnixMilli
+	if got, ok := formatTime(now).(int64); !ok || got != now.Unix()*1000+250 {
+		t.Errorf("unixmilli: unexpected %#v", formatTime(now))
+	}
+	dtimeFormat = timeDict
+	got, ok := formatTime(now).(wamp.Dict)
+	if !ok || got["year"] != 2024 || got["month"] != 5 || got["second"] != 9 ||
+		got["millisecond"] != 250 || got["zone"] != "UTC" || got["offset"] != 0 {
+		t.Errorf("dict: unexpected %#v", formatTime(now))
+	}
+
+	if err := checkTimeFormat("iso"); err == nil {
+		t.Error("expected an invalid format to be rejected")
+	}
+	for _, format := range []string{timeRFC3339, timeUnix, timeUnixMilli, timeDict} {
+		if err := checkTimeFormat(format); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+var (
+	topologyMu sync.Mutex
+	// topology holds the last snapshot of runTopology, added to the stats.
+	topology = wamp.Dict{}
+)
+
+// uriPrefixOf returns the first depth components of the URI.
+func uriPrefixOf(uri string, depth int) string {
+	parts := strings.SplitN(uri, ".", depth+1)
+	if len(parts) > depth {
+		parts = parts[:depth]
+	}
+	return strings.Join(parts, ".")
+}
+
+// realmTopology returns the subscriptions, their subscribers and the
+// registrations of the realm, counted per URI prefix of depth components.
+// Subscriptions and registrations of the local clients are counted too.
+func realmTopology(ctx context.Context, uri wamp.URI, depth int) (map[string]wamp.Dict, error) {
+	c, err := realmClient(uri)
+	if err != nil {
+		return nil, err
+	}
+	prefixes := map[string]wamp.Dict{}
+	count := func(uri, key string, n int64) {
+		prefix := uriPrefixOf(uri, depth)
+		counts := prefixes[prefix]
+		if counts == nil {
+			counts = wamp.Dict{"subscriptions": int64(0), "subscribers": int64(0), "registrations": int64(0)}
+			prefixes[prefix] = counts
+		}
+		counts[key] = counts[key].(int64) + n
+	}
+	subs, err := metaIDs(ctx, c, wamp.MetaProcSubList)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range subs {
+		res, err := c.Call(ctx, string(wamp.MetaProcSubGet), nil, wamp.List{id}, nil, nil)
+		if err != nil {
+			continue
+		}
+		details, _ := wamp.AsDict(res.Arguments[0])
+		topic, _ := wamp.AsString(details["uri"])
+		if res, err = c.Call(ctx, string(wamp.MetaProcSubCountSubscribers), nil, wamp.List{id}, nil, nil); err != nil {
+			continue
+		}
+		subscribers, _ := wamp.AsInt64(res.Arguments[0])
+		count(topic, "subscriptions", 1)
+		count(topic, "subscribers", subscribers)
+	}
+	regs, err := metaIDs(ctx, c, wamp.MetaProcRegList)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range regs {
+		res, err := c.Call(ctx, string(wamp.MetaProcRegGet), nil, wamp.List{id}, nil, nil)
+		if err != nil {
+			continue
+		}
+		details, _ := wamp.AsDict(res.Arguments[0])
+		proc, _ := wamp.AsString(details["uri"])
+		count(proc, "registrations", 1)
+	}
+	return prefixes, nil
+}
+
+// metaIDs returns the IDs of the subscription or registration list meta
+// procedure, whatever their match policy.
+func metaIDs(ctx context.Context, c *client.Client, list wamp.URI) ([]wamp.ID, error) {
+	res, err := c.Call(ctx, string(list), nil, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	var ids []wamp.ID
+	byMatch, _ := wamp.AsDict(res.Arguments[0])
+	for _, v := range byMatch {
+		v, _ := wamp.AsList(v)
+		for _, id := range v {
+			if id, ok := wamp.AsID(id); ok {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// runTopology logs the topology of the realms every interval until quit is
+// closed, one line per realm, and keeps it for the stats.
+func runTopology(realms []wamp.URI, interval time.Duration, depth int, quit <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			snapshotTopology(ctx, realms, depth)
+			cancel()
+		case <-quit:
+			return
+		}
+	}
+}
+
+// snapshotTopology replaces the kept topology with the current one of the
+// realms and logs it.  Realms that cannot be asked, such as closed ones, are
+// left out.
+func snapshotTopology(ctx context.Context, realms []wamp.URI, depth int) {
+	snapshot := wamp.Dict{}
+	for _, uri := range realms {
+		prefixes, err := realmTopology(ctx, uri, depth)
+		if err != nil {
+			logger.Printf("topology: failed to list %s: %s\n", uri, err)
+			continue
+		}
+		names := make([]string, 0, len(prefixes))
+		counts := wamp.Dict{}
+		for prefix, c := range prefixes {
+			names = append(names, prefix)
+			counts[prefix] = c
+		}
+		sort.Strings(names)
+		lines := []string{}
+		for _, prefix := range names {
+			c := prefixes[prefix]
+			lines = append(lines, fmt.Sprintf("%s subscriptions=%d subscribers=%d registrations=%d", prefix, c["subscriptions"], c["subscribers"], c["registrations"]))
+		}
+		if len(lines) == 0 {
+			lines = append(lines, "none")
+		}
+		logger.Printf("topology of %s: %s\n", uri, strings.Join(lines, ", "))
+		snapshot[string(uri)] = counts
+	}
+	topologyMu.Lock()
+	topology = snapshot
+	topologyMu.Unlock()
+}
+
+// topologyStats returns the last topology snapshot, per realm and URI prefix.
+func topologyStats() wamp.Dict {
+	topologyMu.Lock()
+	defer topologyMu.Unlock()
+	return topology
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestURIPrefixOf(t *testing.T) {
+	for _, tc := range []struct {
+		uri   string
+		depth int
+		want  string
+	}{
+		{"app.chat.room1", 2, "app.chat"},
+		{"app.chat", 2, "app.chat"},
+		{"app", 2, "app"},
+		{"app.chat.room1", 1, "app"},
+		{"dev..tick", 2, "dev."},
+	} {
+		if got := uriPrefixOf(tc.uri, tc.depth); got != tc.want {
+			t.Errorf("%s at depth %d: expected %q, got %q", tc.uri, tc.depth, tc.want, got)
+		}
+	}
+}
+
+func TestTopologySnapshot(t *testing.T) {
+	url := startTestRouter(t, "other")
+	a := connectTestClient(t, url, realm)
+	b := connectTestClient(t, url, realm)
+	handler := func(*wamp.Event) {}
+	for _, sub := range []struct {
+		c     *client.Client
+		topic string
+	}{{a, "app.chat.room1"}, {b, "app.chat.room1"}, {a, "app.chat.room2"}, {a, "app.news"}} {
+		if err := sub.c.Subscribe(sub.topic, handler, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Register("app.chat.post", func(context.Context, *wamp.Invocation) client.InvokeResult {
+		return client.InvokeResult{}
+	}, nil); err != nil {
+		t.Fatal(err)
+	}
+	other := connectTestClient(t, url, "other")
+	if err := other.Subscribe("app.chat.room1", handler, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// prefixCounts takes a snapshot and returns the counts of the prefix.
+	prefixCounts := func(uri wamp.URI, prefix string) [3]int64 {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		snapshotTopology(ctx, []wamp.URI{wamp.URI(realm), "other"}, 2)
+		realmCounts, _ := wamp.AsDict(topologyStats()[string(uri)])
+		counts, _ := wamp.AsDict(realmCounts[prefix])
+		var got [3]int64
+		for i, key := range []string{"subscriptions", "subscribers", "registrations"} {
+			got[i], _ = wamp.AsInt64(counts[key])
+		}
+		return got
+	}
+
+	if got := prefixCounts(wamp.URI(realm), "app.chat"); got != [3]int64{2, 3, 1} {
+		t.Errorf("expected 2 subscriptions, 3 subscribers and 1 registration under app.chat, got %v", got)
+	}
+	if got := prefixCounts(wamp.URI(realm), "app.news"); got != [3]int64{1, 1, 0} {
+		t.Errorf("expected 1 subscription under app.news, got %v", got)
+	}
+	if got := prefixCounts("other", "app.chat"); got != [3]int64{1, 1, 0} {
+		t.Errorf("expected the other realm to be counted apart, got %v", got)
+	}
+
+	if err := a.Unsubscribe("app.chat.room2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Unsubscribe("app.chat.room1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := prefixCounts(wamp.URI(realm), "app.chat"); got != [3]int64{1, 1, 1} {
+		t.Errorf("expected the unsubscribes to be reflected, got %v", got)
+	}
+}
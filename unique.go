@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// -unique-authid modes.
+const (
+	uniqueRejectNew = "reject-new"
+	uniqueKickOld   = "kick-old"
+)
+
+var (
+	uniqueMu sync.Mutex
+	// uniqueSessions maps the authids of each realm to their session with
+	// -unique-authid.
+	uniqueSessions = map[wamp.URI]map[string]wamp.ID{}
+)
+
+// checkUniqueMode validates the -unique-authid mode.
+func checkUniqueMode(mode string) error {
+	switch mode {
+	case "", uniqueRejectNew, uniqueKickOld:
+		return nil
+	}
+	return fmt.Errorf("unknown unique authid mode %q, expected %s or %s", mode, uniqueRejectNew, uniqueKickOld)
+}
+
+// admitUnique admits the session of authid to the realm like admitSession,
+// keeping one session per authid with -unique-authid: reject-new fails the
+// join while the previous session is still in the realm, kick-old kills the
+// previous session once the new one is admitted.  The session takes the
+// authid under uniqueMu once the check passes, so concurrent joins of an
+// authid cannot both pass, but the meta calls of the check and of
+// admitSession, which can take seconds, are made without the lock.
+func admitUnique(uri wamp.URI, sid wamp.ID, authid string) error {
+	mode := uniqueMode
+	if mode == "" {
+		return admitSession(uri, sid)
+	}
+	uniqueMu.Lock()
+	sessions := uniqueSessions[uri]
+	if sessions == nil {
+		sessions = map[string]wamp.ID{}
+		uniqueSessions[uri] = sessions
+	}
+	old, found := sessions[authid]
+	for found && mode == uniqueRejectNew {
+		uniqueMu.Unlock()
+		exists := sessionExists(uri, old)
+		uniqueMu.Lock()
+		// Another session of authid may have joined or left meanwhile.
+		if cur, ok := sessions[authid]; !ok || cur != old {
+			old, found = cur, ok
+			continue
+		}
+		if exists {
+			uniqueMu.Unlock()
+			return &abortError{abortAuthIDInUse, fmt.Errorf("authid %s already has a session in %s", authid, uri)}
+		}
+		break
+	}
+	sessions[authid] = sid
+	uniqueMu.Unlock()
+	if err := admitSession(uri, sid); err != nil {
+		uniqueMu.Lock()
+		if sessions[authid] == sid {
+			if found {
+				sessions[authid] = old
+			} else {
+				delete(sessions, authid)
+			}
+		}
+		uniqueMu.Unlock()
+		return err
+	}
+	if found && mode == uniqueKickOld {
+		kickReplaced(uri, old, authid)
+	}
+	return nil
+}
+
+// sessionExists reports whether the session is still in the realm.  It is
+// assumed to be when the realm cannot be asked.
+func sessionExists(uri wamp.URI, sid wamp.ID) bool {
+	c, err := realmClient(uri)
+	if err != nil {
+		return true
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = c.Call(ctx, string(wamp.MetaProcSessionGet), nil, wamp.List{sid}, nil, nil)
+	var rpcErr client.RPCError
+	return !errors.As(err, &rpcErr) || rpcErr.Err.Error != wamp.ErrNoSuchSession
+}
+
+// kickReplaced kills the previous session of authid.  Sessions that left
+// already are not found and ignored.
+func kickReplaced(uri wamp.URI, sid wamp.ID, authid string) {
+	killed, err := killSession(uri, sid, closeReplaced, fmt.Sprintf("replaced by a new session of authid %s", authid))
+	if err != nil {
+		logger.Printf("failed to replace session %d of %s: %s\n", sid, uri, err)
+	} else if killed {
+		logger.Printf("replaced session %d of authid %s in %s\n", sid, authid, uri)
+	}
+}
+
+// watchUniqueLeaves forgets the sessions of the realm that leave, from the
+// session and authid arguments of their on_leave event.
+func watchUniqueLeaves(uri wamp.URI) error {
+	return onRealmMetaEvent(uri, wamp.MetaEventSessionOnLeave, func(event *wamp.Event) {
+		if len(event.Arguments) < 2 {
+			return
+		}
+		sid, _ := wamp.AsID(event.Arguments[0])
+		authid, _ := wamp.AsString(event.Arguments[1])
+		uniqueMu.Lock()
+		defer uniqueMu.Unlock()
+		if uniqueSessions[uri][authid] == sid {
+			delete(uniqueSessions[uri], authid)
+		}
+	})
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestUniqueAuthID(t *testing.T) {
+	savedMode, savedTickets := uniqueMode, tickets
+	defer func() { uniqueMode, tickets = savedMode, savedTickets }()
+	tickets = ticketStore{"alice": {Ticket: "a", Role: "user"}}
+	if err := checkUniqueMode("kick-new"); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+
+	for _, mode := range []string{uniqueRejectNew, uniqueKickOld} {
+		t.Run(mode, func(t *testing.T) { testUniqueAuthID(t, mode) })
+	}
+}
+
+// testUniqueAuthID joins alice twice with the mode.
+func testUniqueAuthID(t *testing.T, mode string) {
+	uniqueMode = mode
+	url := startTestRouter(t)
+	// Leaves of the previous router may still be forgotten.
+	uniqueMu.Lock()
+	uniqueSessions = map[wamp.URI]map[string]wamp.ID{}
+	uniqueMu.Unlock()
+	if err := watchUniqueLeaves(wamp.URI(realm)); err != nil {
+		t.Fatal(err)
+	}
+	join := func() (*client.Client, error) {
+		c, err := client.ConnectNet(context.Background(), url, client.Config{
+			Realm:        realm,
+			Logger:       logger,
+			HelloDetails: wamp.Dict{"authid": "alice"},
+			AuthHandlers: map[string]client.AuthFunc{
+				"ticket": func(*wamp.Challenge) (string, wamp.Dict) { return "a", wamp.Dict{} },
+			},
+		})
+		if err == nil {
+			t.Cleanup(func() { c.Close() })
+		}
+		return c, err
+	}
+	first, err := join()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Anonymous sessions are exempt, whatever their authid.
+	connectTestClient(t, url, realm)
+	connectTestClient(t, url, realm)
+
+	second, err := join()
+	switch mode {
+	case uniqueRejectNew:
+		if err == nil {
+			t.Fatal("expected the second session of alice to be rejected")
+		}
+		// Once the first session left, alice can join again.
+		first.Close()
+		if !waitFor(t, 5*time.Second, func() bool { _, err := join(); return err == nil }) {
+			t.Error("expected alice to join after the first session left")
+		}
+	case uniqueKickOld:
+		if err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-first.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the first session of alice to be kicked")
+		}
+		if goodbye := first.RouterGoodbye(); goodbye == nil || goodbye.Reason != closeReplaced {
+			t.Errorf("expected the %s reason, got %v", closeReplaced, goodbye)
+		}
+		if _, err = testCall(second, string(wamp.MetaProcSessionCount), nil, nil); err != nil {
+			t.Errorf("expected the new session to stay, got %v", err)
+		}
+	}
+}
+
+func TestUniqueAdmitRefused(t *testing.T) {
+	savedMode, savedMax := uniqueMode, maxSessions
+	defer func() { uniqueMode, maxSessions = savedMode, savedMax }()
+	uniqueMode, maxSessions = uniqueKickOld, 1
+
+	startTestRouter(t)
+	uri := wamp.URI(realm)
+	uniqueMu.Lock()
+	uniqueSessions = map[wamp.URI]map[string]wamp.ID{uri: {"alice": 1}}
+	uniqueMu.Unlock()
+	// The realm is full with a session admitted too recently to be dropped.
+	sessionsMu.Lock()
+	realmSessions[uri] = map[wamp.ID]time.Time{1: time.Now()}
+	sessionsMu.Unlock()
+
+	if err := admitUnique(uri, 2, "alice"); err == nil {
+		t.Fatal("expected the join to be refused at the session limit")
+	}
+	uniqueMu.Lock()
+	defer uniqueMu.Unlock()
+	if sid := uniqueSessions[uri]["alice"]; sid != 1 {
+		t.Errorf("expected the refused session to give the authid back, got session %d", sid)
+	}
+}
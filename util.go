@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// multiPublish handles nexus.util.multipublish.  It takes a list of topics,
+// optionally followed by the args list and kwargs dict to publish on each, and
+// returns the number of successful publications along with a result per topic,
+// in the order the topics were given.
+func multiPublish(ctx context.Context, inv *wamp.Invocation) client.InvokeResult {
+	if len(inv.Arguments) == 0 {
+		return client.InvokeResult{Err: wamp.ErrInvalidArgument, Args: wamp.List{"missing topic list"}}
+	}
+	topics, ok := wamp.AsList(inv.Arguments[0])
+	if !ok {
+		return client.InvokeResult{Err: wamp.ErrInvalidArgument, Args: wamp.List{"topics must be a list"}}
+	}
+	var args wamp.List
+	var kwargs wamp.Dict
+	if len(inv.Arguments) > 1 {
+		if args, ok = wamp.AsList(inv.Arguments[1]); !ok {
+			return client.InvokeResult{Err: wamp.ErrInvalidArgument, Args: wamp.List{"args must be a list"}}
+		}
+	}
+	if len(inv.Arguments) > 2 {
+		if kwargs, ok = wamp.AsDict(inv.Arguments[2]); !ok {
+			return client.InvokeResult{Err: wamp.ErrInvalidArgument, Args: wamp.List{"kwargs must be a dict"}}
+		}
+	}
+
+	count := 0
+	results := make(wamp.List, len(topics))
+	for i, t := range topics {
+		result := "ok"
+		topic, ok := wamp.AsString(t)
+		if !ok || !wamp.URI(topic).ValidURI(false, "") {
+			result = string(wamp.ErrInvalidURI)
+		} else if err := getLocalClient().Publish(topic, nil, args, kwargs); err != nil {
+			result = err.Error()
+		} else {
+			count++
+		}
+		results[i] = wamp.Dict{"topic": t, "result": result}
+	}
+	return client.InvokeResult{Args: wamp.List{count}, Kwargs: wamp.Dict{"results": results}}
+}
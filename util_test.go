@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestMultiPublish(t *testing.T) {
+	url := startTestRouter(t)
+	if err := createLocalCallee(getLocalClient(), "nexus.util.multipublish", multiPublish); err != nil {
+		t.Fatal(err)
+	}
+	sub := connectTestClient(t, url, realm)
+	events := map[string]chan *wamp.Event{}
+	for _, topic := range []string{"test.a", "test.b"} {
+		events[topic] = make(chan *wamp.Event, 1)
+		if err := sub.SubscribeChan(topic, events[topic], nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	topics := wamp.List{"test.a", 42, "test.b", true}
+	res, err := testCall(connectTestClient(t, url, realm), "nexus.util.multipublish", wamp.List{topics, wamp.List{"hello"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := wamp.AsInt64(res.Arguments[0]); count != 2 {
+		t.Errorf("expected 2 publications, got %v", res.Arguments[0])
+	}
+	results, _ := wamp.AsList(res.ArgumentsKw["results"])
+	if len(results) != len(topics) {
+		t.Fatalf("expected %d results, got %v", len(topics), results)
+	}
+	for i, want := range []string{"ok", string(wamp.ErrInvalidURI), "ok", string(wamp.ErrInvalidURI)} {
+		r, _ := wamp.AsDict(results[i])
+		if r["result"] != want {
+			t.Errorf("result %d: expected %s, got %v", i, want, r)
+		}
+	}
+
+	for topic, ch := range events {
+		select {
+		case event := <-ch:
+			if len(event.Arguments) != 1 || event.Arguments[0] != "hello" {
+				t.Errorf("%s: unexpected event args %v", topic, event.Arguments)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("no event on %s", topic)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// whoamiProc is the procedure returning the identity and permissions of the
+// calling session, registered with -whoami.
+const whoamiProc = "nexus.whoami"
+
+// nexusWhoami handles nexus.whoami.  The authorizer replaces the kwargs of
+// each call with the caller's identity and permissions, so it returns them.
+func nexusWhoami(ctx context.Context, inv *wamp.Invocation) client.InvokeResult {
+	return client.InvokeResult{Args: wamp.List{inv.ArgumentsKw}}
+}
+
+// whoami returns the identity of sess and a summary of what the authorizer
+// lets it do.  Restrictions that are not set are left out.
+func (a *authorizer) whoami(sess *wamp.Session) wamp.Dict {
+	authid, _ := wamp.AsString(sess.Details["authid"])
+	authrole, _ := wamp.AsString(sess.Details["authrole"])
+	writable := !inMaintenance.Load()
+	permissions := wamp.Dict{
+		"admin":     authrole == a.adminRole,
+		"publish":   writable,
+		"register":  writable,
+		"subscribe": true,
+		"call":      true,
+	}
+	if a.uriPrefix != "" {
+		permissions["uri_prefix"] = a.uriPrefix
+	}
+	if a.matches != nil {
+		var policies []string
+		for _, policy := range []string{wamp.MatchExact, wamp.MatchPrefix, wamp.MatchWildcard} {
+			if a.matches.allowed[policy] {
+				policies = append(policies, policy)
+			}
+		}
+		permissions["match_policies"] = policies
+	}
+	if a.subLimit != nil {
+		permissions["max_subscriptions"] = a.subLimit.max
+	}
+	if a.regLimit != nil {
+		permissions["max_registrations"] = a.regLimit.max
+	}
+	if a.quota != nil {
+		permissions["quota"] = wamp.Dict{
+			"calls":     a.quota.limit.calls,
+			"publishes": a.quota.limit.publishes,
+			"window":    a.quota.window.Seconds(),
+		}
+	}
+	if metaRoles != nil {
+		permissions["meta"] = metaRoles[authrole]
+	}
+	if denied := deniedProcedures(sess); len(denied) != 0 {
+		permissions["denied_procedures"] = denied
+	}
+	disabledMu.RLock()
+	if len(disabledTopics) != 0 {
+		permissions["disabled_topics"] = sortedURIs(disabledTopics)
+	}
+	if len(disabledProcs) != 0 {
+		permissions["disabled_procedures"] = sortedURIs(disabledProcs)
+	}
+	disabledMu.RUnlock()
+	return wamp.Dict{
+		"session":     sess.ID,
+		"authid":      authid,
+		"authrole":    authrole,
+		"authmethod":  sess.Details["authmethod"],
+		"permissions": permissions,
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestWhoami(t *testing.T) {
+	savedOn, savedID, savedRole := whoamiOn, anonAuthID, anonRole
+	t.Cleanup(func() {
+		whoamiOn, anonAuthID, anonRole = savedOn, savedID, savedRole
+		inMaintenance.Store(false)
+	})
+	whoamiOn, anonAuthID, anonRole = true, "sensor-1", adminRole
+
+	url := startTestRouter(t)
+	if err := createLocalCallee(getLocalClient(), whoamiProc, nexusWhoami); err != nil {
+		t.Fatal(err)
+	}
+	c := connectTestClient(t, url, realm)
+	whoami := func() (wamp.Dict, wamp.Dict) {
+		// The identity passed by the caller is replaced by its own.
+		res, err := testCall(c, whoamiProc, nil, wamp.Dict{"authid": "other", "authrole": "spoofed"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := wamp.AsDict(res.Arguments[0])
+		permissions, _ := wamp.AsDict(got["permissions"])
+		return got, permissions
+	}
+
+	got, permissions := whoami()
+	if got["authid"] != "sensor-1" || got["authrole"] != adminRole || got["authmethod"] != "anonymous" {
+		t.Errorf("unexpected identity %v", got)
+	}
+	if id, _ := wamp.AsID(got["session"]); id != c.ID() {
+		t.Errorf("expected session %d, got %v", c.ID(), got["session"])
+	}
+	if permissions["admin"] != true || permissions["publish"] != true {
+		t.Errorf("expected admin and publish permissions, got %v", permissions)
+	}
+
+	inMaintenance.Store(true)
+	if _, permissions = whoami(); permissions["publish"] != false || permissions["register"] != false || permissions["call"] != true {
+		t.Errorf("expected read-only permissions in maintenance mode, got %v", permissions)
+	}
+}